@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// a minimal solidity abi encoder / decoder for the contract arguments given on the command line, supports the elementary types
+// (address, bool, intN / uintN, bytesN, bytes, string) and arrays of the static elementary types.
+
+// an entry of the contract abi, a function, constructor or event.
+type abiEntry struct {
+	Type            string     `json:"type"`
+	Name            string     `json:"name"`
+	Inputs          []abiParam `json:"inputs"`
+	Outputs         []abiParam `json:"outputs"`
+	StateMutability string     `json:"stateMutability"`
+	Constant        bool       `json:"constant"`
+	Anonymous       bool       `json:"anonymous"`
+}
+
+type abiParam struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Indexed bool   `json:"indexed"`
+}
+
+func parseAbi(abi json.RawMessage) ([]abiEntry, error) {
+	var entries []abiEntry
+	if len(abi) == 0 {
+		return entries, nil
+	}
+	err := json.Unmarshal(abi, &entries)
+	return entries, err
+}
+
+// abiConstructor returns the constructor of the abi, a contract without a constructor takes no arguments.
+func abiConstructor(entries []abiEntry) abiEntry {
+	for _, entry := range entries {
+		if entry.Type == "constructor" {
+			return entry
+		}
+	}
+	return abiEntry{Type: "constructor"}
+}
+
+// abiEncodeArgs encodes the command line args (a JSON array for the array types) as the abi params, returns the hex
+// encoding without 0x.
+func abiEncodeArgs(params []abiParam, args []string) (string, error) {
+	if len(params) != len(args) {
+		return "", fmt.Errorf("expected %d argument(s) %s, got %d", len(params), abiSignatureTypes(params), len(args))
+	}
+	var values []interface{}
+	for i, param := range params {
+		var value interface{} = args[i]
+		if strings.HasSuffix(param.Type, "]") {
+			var elements []interface{}
+			// the numbers are kept as json.Number, as a float64 would lose the precision of a uint256.
+			decoder := json.NewDecoder(strings.NewReader(args[i]))
+			decoder.UseNumber()
+			if err := decoder.Decode(&elements); err != nil {
+				return "", fmt.Errorf("argument [%s] of type [%s] must be a JSON array: %v", args[i], param.Type, err)
+			}
+			value = elements
+		} else if param.Type == "string" && len(args[i]) > 1 && strings.HasPrefix(args[i], `"`) && strings.HasSuffix(args[i], `"`) {
+			// a quoted string, e.g. a string containing a comma in --args.
+			var unquoted string
+			if err := json.Unmarshal([]byte(args[i]), &unquoted); err != nil {
+				return "", fmt.Errorf("argument [%s] of type [%s] is not a valid quoted string: %v", args[i], param.Type, err)
+			}
+			value = unquoted
+		}
+		values = append(values, value)
+	}
+	return abiEncodeValues(abiTypes(params), values)
+}
+
+// the head / tail encoding of a tuple, the dynamic values are stored in the tail and referenced by offset.
+func abiEncodeValues(types []string, values []interface{}) (string, error) {
+	var heads, tails []string
+	headSize := 0
+	for _, t := range types {
+		headSize += 32 * abiStaticWords(t)
+	}
+	tailSize := 0
+	for i, t := range types {
+		encoded, err := abiEncodeValue(t, values[i])
+		if err != nil {
+			return "", err
+		}
+		if abiIsDynamic(t) {
+			heads = append(heads, abiEncodeUint(big.NewInt(int64(headSize+tailSize))))
+			tails = append(tails, encoded)
+			tailSize += len(encoded) / 2
+		} else {
+			heads = append(heads, encoded)
+		}
+	}
+	return strings.Join(heads, "") + strings.Join(tails, ""), nil
+}
+
+func abiEncodeValue(t string, value interface{}) (string, error) {
+	if i := strings.LastIndex(t, "["); i > 0 {
+		elementType, size := t[:i], strings.TrimSuffix(t[i+1:], "]")
+		if abiIsDynamic(elementType) {
+			return "", fmt.Errorf("unsupported type [%s], only arrays of static types are supported", t)
+		}
+		elements, ok := value.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("expected an array for type [%s]", t)
+		}
+		var types []string
+		for range elements {
+			types = append(types, elementType)
+		}
+		encoded, err := abiEncodeValues(types, elements)
+		if err != nil {
+			return "", err
+		}
+		if size == "" {
+			return abiEncodeUint(big.NewInt(int64(len(elements)))) + encoded, nil
+		}
+		if fixedSize, err := strconv.Atoi(size); err != nil || fixedSize != len(elements) {
+			return "", fmt.Errorf("expected %s elements for type [%s], got %d", size, t, len(elements))
+		}
+		return encoded, nil
+	}
+
+	s := fmt.Sprintf("%v", value)
+	switch {
+	case t == "address":
+		address := strings.TrimPrefix(strings.ToLower(s), "0x")
+		if _, err := hex.DecodeString(address); err != nil || len(address) != 40 {
+			return "", fmt.Errorf("invalid address [%s]", s)
+		}
+		return abiEncodeAddress(address), nil
+	case t == "bool":
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid bool [%s]", s)
+		}
+		if b {
+			return abiEncodeUint(big.NewInt(1)), nil
+		}
+		return abiEncodeUint(big.NewInt(0)), nil
+	case strings.HasPrefix(t, "uint") || strings.HasPrefix(t, "int"):
+		n, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return "", fmt.Errorf("invalid integer [%s] for type [%s]", s, t)
+		}
+		bits, err := abiIntBits(t)
+		if err != nil {
+			return "", err
+		}
+		// uintN is in [0, 2^N), intN in [-2^(N-1), 2^(N-1)).
+		min, max := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		if strings.HasPrefix(t, "int") {
+			max.Rsh(max, 1)
+			min.Neg(max)
+		}
+		if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+			return "", fmt.Errorf("integer [%s] out of range for type [%s]", s, t)
+		}
+		if n.Sign() < 0 {
+			// two's complement
+			n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return abiEncodeUint(n), nil
+	case t == "string" || t == "bytes":
+		data := []byte(s)
+		if t == "bytes" {
+			var err error
+			if data, err = hex.DecodeString(strings.TrimPrefix(s, "0x")); err != nil {
+				return "", fmt.Errorf("invalid hex bytes [%s]", s)
+			}
+		}
+		encoded := hex.EncodeToString(data)
+		if len(encoded)%64 != 0 {
+			encoded += strings.Repeat("0", 64-len(encoded)%64)
+		}
+		return abiEncodeUint(big.NewInt(int64(len(data)))) + encoded, nil
+	case strings.HasPrefix(t, "bytes"):
+		data, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		size, sizeErr := strconv.Atoi(strings.TrimPrefix(t, "bytes"))
+		if sizeErr != nil || size < 1 || size > 32 {
+			return "", fmt.Errorf("unsupported abi type [%s]", t)
+		}
+		if err != nil || len(data) > size {
+			return "", fmt.Errorf("invalid [%s] value [%s]", t, s)
+		}
+		encoded := hex.EncodeToString(data)
+		return encoded + strings.Repeat("0", 64-len(encoded)), nil
+	}
+	return "", fmt.Errorf("unsupported abi type [%s]", t)
+}
+
+// abiIntBits returns the bit size N of the uintN / intN type, uint and int are 256 bits.
+func abiIntBits(t string) (int, error) {
+	size := strings.TrimPrefix(strings.TrimPrefix(t, "u"), "int")
+	if size == "" {
+		return 256, nil
+	}
+	bits, err := strconv.Atoi(size)
+	if err != nil || bits < 8 || bits > 256 || bits%8 != 0 {
+		return 0, fmt.Errorf("unsupported abi type [%s]", t)
+	}
+	return bits, nil
+}
+
+// abiEncodeUint encodes the integer as a 32 byte word, the integer must be in [0, 2^256).
+func abiEncodeUint(n *big.Int) string {
+	encoded := n.Text(16)
+	return strings.Repeat("0", 64-len(encoded)) + encoded
+}
+
+// abiIsDynamic returns whether the type is stored in the tail of the encoding.
+func abiIsDynamic(t string) bool {
+	if t == "string" || t == "bytes" || strings.HasSuffix(t, "[]") {
+		return true
+	}
+	if i := strings.LastIndex(t, "["); i > 0 {
+		return abiIsDynamic(t[:i])
+	}
+	return false
+}
+
+// abiStaticWords returns the number of 32 byte words of the type in the head, fixed size arrays are stored in place.
+func abiStaticWords(t string) int {
+	if abiIsDynamic(t) {
+		return 1
+	}
+	if i := strings.LastIndex(t, "["); i > 0 {
+		size, _ := strconv.Atoi(strings.TrimSuffix(t[i+1:], "]"))
+		return size * abiStaticWords(t[:i])
+	}
+	return 1
+}
+
+// abiSignatureTypes returns the param types as in a signature, e.g. (address,uint256)
+func abiSignatureTypes(params []abiParam) string {
+	return "(" + strings.Join(abiTypes(params), ",") + ")"
+}
+
+// abiDecodeValues decodes the abi encoded tuple of the types, e.g. the return values of eth_call. The numbers are
+// returned as decimal strings, the addresses and bytes as 0x hex and the arrays as []interface{}.
+func abiDecodeValues(types []string, data []byte) ([]interface{}, error) {
+	var values []interface{}
+	offset := 0
+	for _, t := range types {
+		var value interface{}
+		var err error
+		if abiIsDynamic(t) {
+			var tailOffset *big.Int
+			if tailOffset, err = abiWord(data, offset); err != nil {
+				return nil, err
+			}
+			if !tailOffset.IsInt64() || tailOffset.Int64() > int64(len(data)) {
+				return nil, fmt.Errorf("invalid offset [%s] for type [%s]", tailOffset, t)
+			}
+			value, err = abiDecodeValue(t, data, int(tailOffset.Int64()))
+		} else {
+			value, err = abiDecodeValue(t, data, offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		offset += 32 * abiStaticWords(t)
+	}
+	return values, nil
+}
+
+// abiDecodeValue decodes the value of the type stored at the offset.
+func abiDecodeValue(t string, data []byte, offset int) (interface{}, error) {
+	if i := strings.LastIndex(t, "["); i > 0 {
+		elementType, size := t[:i], strings.TrimSuffix(t[i+1:], "]")
+		if abiIsDynamic(elementType) {
+			return nil, fmt.Errorf("unsupported type [%s], only arrays of static types are supported", t)
+		}
+		var length int
+		if size == "" {
+			n, err := abiWord(data, offset)
+			if err != nil {
+				return nil, err
+			}
+			if !n.IsInt64() || n.Int64() > int64(len(data)/32) {
+				return nil, fmt.Errorf("invalid length [%s] for type [%s]", n, t)
+			}
+			length = int(n.Int64())
+			offset += 32
+		} else {
+			length, _ = strconv.Atoi(size)
+		}
+		elements := []interface{}{}
+		for j := 0; j < length; j++ {
+			element, err := abiDecodeValue(elementType, data, offset+j*32*abiStaticWords(elementType))
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		return elements, nil
+	}
+
+	word, err := abiWord(data, offset)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case t == "address":
+		return "0x" + hex.EncodeToString(data[offset+12:offset+32]), nil
+	case t == "bool":
+		return word.Sign() != 0, nil
+	case strings.HasPrefix(t, "uint"):
+		return word.String(), nil
+	case strings.HasPrefix(t, "int"):
+		// two's complement
+		if word.Bit(255) == 1 {
+			word.Sub(word, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return word.String(), nil
+	case t == "string" || t == "bytes":
+		// compared before the conversion to int, a huge length would overflow the sum.
+		if word.Cmp(big.NewInt(int64(len(data)-offset-32))) > 0 {
+			return nil, fmt.Errorf("invalid length [%s] for type [%s]", word, t)
+		}
+		content := data[offset+32 : offset+32+int(word.Int64())]
+		if t == "string" {
+			return string(content), nil
+		}
+		return "0x" + hex.EncodeToString(content), nil
+	case strings.HasPrefix(t, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(t, "bytes"))
+		if err != nil || size > 32 {
+			return nil, fmt.Errorf("unsupported abi type [%s]", t)
+		}
+		return "0x" + hex.EncodeToString(data[offset:offset+size]), nil
+	}
+	return nil, fmt.Errorf("unsupported abi type [%s]", t)
+}
+
+// abiWord returns the 32 byte word at the offset as an unsigned integer.
+func abiWord(data []byte, offset int) (*big.Int, error) {
+	if offset < 0 || offset+32 > len(data) {
+		return nil, fmt.Errorf("the data is too short, %d bytes, for a value at offset %d", len(data), offset)
+	}
+	return new(big.Int).SetBytes(data[offset : offset+32]), nil
+}
+
+// abiFunction finds the function by name, or by signature e.g. set(uint256) when it is overloaded.
+func abiFunction(entries []abiEntry, method string, numArgs int) (abiEntry, error) {
+	var matches []abiEntry
+	for _, entry := range entries {
+		if entry.Type != "function" && entry.Type != "" {
+			continue
+		}
+		if entry.Name+abiSignatureTypes(entry.Inputs) == method {
+			return entry, nil
+		}
+		if entry.Name == method && len(entry.Inputs) == numArgs {
+			matches = append(matches, entry)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	if len(matches) > 1 {
+		return abiEntry{}, fmt.Errorf("function [%s] is overloaded, use the signature e.g. [%s%s]", method, method,
+			abiSignatureTypes(matches[0].Inputs))
+	}
+	return abiEntry{}, fmt.Errorf("no function [%s] with %d argument(s) in the abi", method, numArgs)
+}
+
+// the abi types of the params.
+func abiTypes(params []abiParam) []string {
+	var types []string
+	for _, param := range params {
+		types = append(types, param.Type)
+	}
+	return types
+}
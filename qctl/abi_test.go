@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// the examples from the solidity abi specification.
+func TestAbiEncodeArgs(t *testing.T) {
+	tests := []struct {
+		types    []string
+		args     []string
+		expected string
+	}{
+		{[]string{"uint32", "bool"}, []string{"69", "true"},
+			"0000000000000000000000000000000000000000000000000000000000000045" +
+				"0000000000000000000000000000000000000000000000000000000000000001"},
+		{[]string{"bytes3[2]"}, []string{`["0x616263","0x646566"]`},
+			"6162630000000000000000000000000000000000000000000000000000000000" +
+				"6465660000000000000000000000000000000000000000000000000000000000"},
+		{[]string{"bytes", "bool", "uint256[]"}, []string{"0x64617665", "true", "[1,2,3]"},
+			"0000000000000000000000000000000000000000000000000000000000000060" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"0000000000000000000000000000000000000000000000000000000000000004" +
+				"6461766500000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000003"},
+		{[]string{"int8", "address"}, []string{"-1", "0xCA35b7d915458EF540aDe6068dFe2F44E8fa733c"},
+			"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+				"000000000000000000000000ca35b7d915458ef540ade6068dfe2f44e8fa733c"},
+		// a quoted string, e.g. containing a comma in --args.
+		{[]string{"string"}, []string{`"a,b"`},
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"612c620000000000000000000000000000000000000000000000000000000000"},
+	}
+	for _, test := range tests {
+		var params []abiParam
+		for _, typ := range test.types {
+			params = append(params, abiParam{Type: typ})
+		}
+		encoded, err := abiEncodeArgs(params, test.args)
+		if err != nil {
+			t.Fatalf("%v %v: %v", test.types, test.args, err)
+		}
+		if encoded != test.expected {
+			t.Errorf("%v %v\nexpected %s\nactual   %s", test.types, test.args, test.expected, encoded)
+		}
+	}
+	if _, err := abiEncodeArgs([]abiParam{{Type: "uint256"}}, nil); err == nil {
+		t.Error("expected an error for the missing argument")
+	}
+	for _, invalid := range []struct{ t, arg string }{
+		{"uint8", "256"},
+		{"uint256", "0x10000000000000000000000000000000000000000000000000000000000000000"},
+		{"int8", "128"},
+		{"int8", "-129"},
+		{"int256", "-0x8000000000000000000000000000000000000000000000000000000000000001"},
+		{"uint7", "1"},
+		{"bytes33", "0x00"},
+	} {
+		if _, err := abiEncodeArgs([]abiParam{{Type: invalid.t}}, []string{invalid.arg}); err == nil {
+			t.Errorf("expected an error for [%s] of type [%s]", invalid.arg, invalid.t)
+		}
+	}
+	if _, err := abiEncodeArgs([]abiParam{{Type: "int8"}, {Type: "uint8"}}, []string{"-128", "255"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSplitFlagValues(t *testing.T) {
+	tests := []struct {
+		values, expected []string
+	}{
+		{[]string{"quorum-node2,quorum-node3"}, []string{"quorum-node2", "quorum-node3"}},
+		{[]string{"quorum-node2", "quorum-node3"}, []string{"quorum-node2", "quorum-node3"}},
+		{[]string{`[1,2],"a,b",3`}, []string{"[1,2]", `"a,b"`, "3"}},
+		{[]string{`[["a","b"],[1,2]]`}, []string{`[["a","b"],[1,2]]`}},
+		{nil, nil},
+	}
+	for _, test := range tests {
+		if split := splitFlagValues(test.values); !reflect.DeepEqual(split, test.expected) {
+			t.Errorf("splitFlagValues(%q) = %q, expected %q", test.values, split, test.expected)
+		}
+	}
+}
+
+func TestAbiDecodeValues(t *testing.T) {
+	types := []string{"int8", "string", "uint256[]", "address", "bool"}
+	params := []abiParam{{Type: "int8"}, {Type: "string"}, {Type: "uint256[]"}, {Type: "address"}, {Type: "bool"}}
+	encoded, err := abiEncodeArgs(params, []string{"-5", "hello", "[1,2]", "0xca35b7d915458ef540ade6068dfe2f44e8fa733c", "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := hex.DecodeString(encoded)
+	values, err := abiDecodeValues(types, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{"-5", "hello", []interface{}{"1", "2"}, "0xca35b7d915458ef540ade6068dfe2f44e8fa733c", true}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+	if _, err := abiDecodeValues([]string{"uint256"}, data[:16]); err == nil {
+		t.Error("expected an error for the short data")
+	}
+	// a string at offset 0x20 with a length of 2^63-1, overflowing the end of the content as an int.
+	huge, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000007fffffffffffffff")
+	if _, err := abiDecodeValues([]string{"string"}, huge); err == nil {
+		t.Error("expected an error for the invalid length")
+	}
+}
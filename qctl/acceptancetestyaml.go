@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 
@@ -21,16 +22,47 @@ var (
 
 type AcceptTestConfig struct {
 	Quorum struct {
-		Nodes []ATNodeEntry
+		Nodes ATNodes
 	}
 }
 
 type ATNodeEntry struct {
+	Name        string `yaml:"-"` // Node1..NodeN, the acceptance tests refer to the nodes by these names.
 	TmPublicKey string `yaml:"privacy-address"`
 	GethURL     string `yaml:"url"`
 	TmURL       string `yaml:"third-party-url"`
 }
 
+// the acceptance tests expect the nodes as a map keyed by the node name, the nodes are kept as a list so they are
+// written in order, Node1, Node2, ... Node10.
+type ATNodes []ATNodeEntry
+
+func (n ATNodes) MarshalYAML() (interface{}, error) {
+	nodes := yaml.MapSlice{}
+	for _, node := range n {
+		nodes = append(nodes, yaml.MapItem{Key: node.Name, Value: node})
+	}
+	return nodes, nil
+}
+
+func (n *ATNodes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var names yaml.MapSlice
+	if err := unmarshal(&names); err != nil {
+		return err
+	}
+	var nodes map[string]ATNodeEntry
+	if err := unmarshal(&nodes); err != nil {
+		return err
+	}
+	*n = nil
+	for _, name := range names {
+		node := nodes[fmt.Sprintf("%v", name.Key)]
+		node.Name = fmt.Sprintf("%v", name.Key)
+		*n = append(*n, node)
+	}
+	return nil
+}
+
 func LoadAcTYamlConfig(filename string) (AcceptTestConfig, error) {
 	config := AcceptTestConfig{}
 	fileBytes, err := ioutil.ReadFile(filename)
@@ -21,7 +21,7 @@ var (
 
 			var out bytes.Buffer
 			cmd.Stdout = &out
-			err := cmd.Run()
+			err := runner.Run(cmd)
 			if err != nil {
 				log.Fatal(err)
 			}
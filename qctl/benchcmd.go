@@ -0,0 +1,493 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the init code of an empty contract (PUSH1 0 PUSH1 0 RETURN), public and private transactions deploy it so they do the
+// same work, quorum does not allow private transactions without a payload.
+const benchTxData = "0x60006000f3"
+const benchTxGas = "0x15f90"
+
+// a transaction sent by the benchmark.
+type benchTx struct {
+	Node      string
+	Hash      string
+	Private   bool
+	Submitted time.Time
+}
+
+type benchLatency struct {
+	MinMs  float64 `json:"minMs"`
+	MeanMs float64 `json:"meanMs"`
+	P50Ms  float64 `json:"p50Ms"`
+	P90Ms  float64 `json:"p90Ms"`
+	P95Ms  float64 `json:"p95Ms"`
+	P99Ms  float64 `json:"p99Ms"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+type benchNodeResult struct {
+	Name      string `json:"name"`
+	Submitted int    `json:"submitted"`
+	Mined     int    `json:"mined"`
+	Errors    int    `json:"errors"`
+}
+
+// the result of a benchmark run, with the network settings so runs can be compared.
+type benchResult struct {
+	Start           time.Time         `json:"start"`
+	QuorumVersion   string            `json:"quorumVersion"`
+	TmVersion       string            `json:"tmVersion"`
+	Consensus       string            `json:"consensus"`
+	NetworkNodes    int               `json:"networkNodes"`
+	Nodes           []string          `json:"nodes"`
+	PrivateFor      []string          `json:"privateFor,omitempty"`
+	PrivateRatio    float64           `json:"privateRatio"`
+	Concurrency     int               `json:"concurrency"`
+	TargetRate      float64           `json:"targetRate"`
+	Submitted       int               `json:"submitted"`
+	SubmittedPublic int               `json:"submittedPublic"`
+	Mined           int               `json:"mined"`
+	Failed          int               `json:"failed"`  // mined with status 0x0
+	Pending         int               `json:"pending"` // no receipt before the timeout
+	Errors          int               `json:"errors"`  // the node rejected the transaction
+	ErrorMessages   map[string]int    `json:"errorMessages,omitempty"`
+	DurationSeconds float64           `json:"durationSeconds"`
+	SubmitTps       float64           `json:"submitTps"`
+	MinedTps        float64           `json:"minedTps"`
+	Latency         benchLatency      `json:"latency"`
+	PerNode         []benchNodeResult `json:"perNode"`
+}
+
+var (
+	// qctl bench --count=1000 --concurrency=8
+	// qctl bench --rate=20 --duration=2m --node=quorum-node1 --private-for=quorum-node2 --private-ratio=0.5 --output=json
+	benchCommand = cli.Command{
+		Name:  "bench",
+		Usage: "send public and private transactions at a target rate or concurrency and report the TPS and latency.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:  "node",
+				Usage: "the node(s) to send the transactions from, comma separated or repeated, defaults to all nodes.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "private-for",
+				Usage: "the node(s) the private transactions are sent to, comma separated or repeated.",
+			},
+			&cli.Float64Flag{
+				Name:  "private-ratio",
+				Usage: "the fraction (0-1) of the transactions that are private, requires --private-for.",
+			},
+			&cli.IntFlag{
+				Name:  "count",
+				Usage: "the number of transactions to send, defaults to 100, or to no limit when --duration is set.",
+			},
+			&cli.DurationFlag{
+				Name:  "duration",
+				Usage: "stop sending after the duration, 0 to only stop after --count transactions.",
+			},
+			&cli.Float64Flag{
+				Name:  "rate",
+				Usage: "the target transactions per second, 0 to send as fast as the --concurrency allows.",
+			},
+			&cli.IntFlag{
+				Name:  "concurrency",
+				Usage: "the number of transactions submitted in parallel.",
+				Value: 4,
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "how long to wait for the receipts after the last transaction was sent.",
+				Value: DefaultReceiptTimeout,
+			},
+			&cli.DurationFlag{
+				Name:  "poll",
+				Usage: "how often the nodes are polled for the receipts, the latency resolution.",
+				Value: 500 * time.Millisecond,
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "table | json",
+				Value: "table",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			count := c.Int("count")
+			duration := c.Duration("duration")
+			if !c.IsSet("count") && duration <= 0 {
+				count = 100
+			}
+			privateRatio := c.Float64("private-ratio")
+			privateForNodes := splitFlagValues(c.StringSlice("private-for"))
+			if count <= 0 && duration <= 0 {
+				return cli.Exit("either --count or --duration must be set.", 2)
+			}
+			if privateRatio < 0 || privateRatio > 1 {
+				return cli.Exit("--private-ratio must be between 0 and 1.", 2)
+			}
+			if len(privateForNodes) > 0 && !c.IsSet("private-ratio") {
+				privateRatio = 1
+			}
+			if privateRatio > 0 && len(privateForNodes) == 0 {
+				return cli.Exit("--private-for must be set to send private transactions.", 2)
+			}
+			if c.Int("concurrency") < 1 {
+				return cli.Exit("--concurrency must be at least 1.", 2)
+			}
+			nodeNames := splitFlagValues(c.StringSlice("node"))
+			if len(nodeNames) == 0 {
+				nodeNames = getNodeNames(configFileYaml)
+			}
+
+			// the pod and unlocked account of every sending node, and the tm public keys of the recipients.
+			pods := map[string]string{}
+			accounts := map[string]string{}
+			for _, nodeName := range nodeNames {
+				pods[nodeName] = podNameFromPrefix(nodeName, namespace)
+				account, err := nodeAccount(pods[nodeName], namespace)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("unable to get the account of node [%s]: %v", nodeName, err), 3)
+				}
+				accounts[nodeName] = account
+			}
+			var privateFor []string
+			for _, nodeName := range privateForNodes {
+				tmKey, err := getTmPublicKey(nodeName, namespace)
+				if err != nil || tmKey == "" {
+					return cli.Exit(fmt.Sprintf("unable to get the tessera public key of node [%s]: %v", nodeName, err), 3)
+				}
+				privateFor = append(privateFor, tmKey)
+			}
+
+			result := benchResult{Start: time.Now(), QuorumVersion: configFileYaml.Genesis.QuorumVersion,
+				TmVersion: configFileYaml.Genesis.TmVersion, Consensus: configFileYaml.Genesis.Consensus,
+				NetworkNodes: len(configFileYaml.Nodes), Nodes: nodeNames, PrivateFor: privateForNodes,
+				PrivateRatio: privateRatio, Concurrency: c.Int("concurrency"), TargetRate: c.Float64("rate"),
+				ErrorMessages: map[string]int{}}
+			if c.String("output") != "json" {
+				green.Println(fmt.Sprintf("  sending transactions from %v, concurrency [%d], rate [%v/s]", nodeNames,
+					result.Concurrency, result.TargetRate))
+			}
+			bench := newBenchCollector(pods, namespace, c.Duration("poll"))
+			go bench.collect()
+			runBench(bench, &result, nodeNames, pods, accounts, privateFor, count, duration)
+			submitEnd := time.Now()
+			bench.wait(c.Duration("timeout"))
+			bench.summarize(&result, submitEnd)
+
+			if c.String("output") == "json" {
+				out, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					return cli.Exit(err.Error(), 3)
+				}
+				fmt.Println(string(out))
+			} else {
+				displayBenchResult(result)
+			}
+			if result.Submitted == 0 {
+				return cli.Exit("no transactions were submitted.", 1)
+			}
+			return nil
+		},
+	}
+)
+
+// runBench submits the transactions from the workers, round robin over the nodes, until the count or duration is reached.
+func runBench(bench *benchCollector, result *benchResult, nodeNames []string, pods, accounts map[string]string,
+	privateFor []string, count int, duration time.Duration) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for w := 0; w < result.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				nodeName := nodeNames[i%len(nodeNames)]
+				private := result.PrivateRatio > 0 && rand.Float64() < result.PrivateRatio
+				tx := rpcTx{From: accounts[nodeName], Data: benchTxData, Gas: benchTxGas}
+				if private {
+					tx.PrivateFor = privateFor
+				}
+				submitted := time.Now()
+				txHash, err := sendTransaction(pods[nodeName], bench.namespace, tx)
+				mu.Lock()
+				if err != nil {
+					result.Errors++
+					result.ErrorMessages[err.Error()]++
+					bench.nodeError(nodeName)
+				} else {
+					result.Submitted++
+					if !private {
+						result.SubmittedPublic++
+					}
+				}
+				mu.Unlock()
+				if err == nil {
+					bench.add(&benchTx{Node: nodeName, Hash: txHash, Private: private, Submitted: submitted})
+				}
+			}
+		}()
+	}
+	var ticker *time.Ticker
+	if result.TargetRate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / result.TargetRate))
+		defer ticker.Stop()
+	}
+	deadline := time.Now().Add(duration)
+	for i := 0; count <= 0 || i < count; i++ {
+		if duration > 0 && time.Now().After(deadline) {
+			break
+		}
+		if ticker != nil {
+			<-ticker.C
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// benchCollector polls the sending nodes for the receipts of the pending transactions, one batch request per node.
+type benchCollector struct {
+	pods      map[string]string
+	namespace string
+	poll      time.Duration
+	mu        sync.Mutex
+	pending   map[string][]*benchTx
+	latencies []time.Duration
+	mined     map[string]int
+	errors    map[string]int
+	submitted map[string]int
+	failed    int
+	firstTx   time.Time
+	lastMined time.Time
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+func newBenchCollector(pods map[string]string, namespace string, poll time.Duration) *benchCollector {
+	return &benchCollector{pods: pods, namespace: namespace, poll: poll, pending: map[string][]*benchTx{},
+		mined: map[string]int{}, errors: map[string]int{}, submitted: map[string]int{}, done: make(chan struct{}),
+		stopped: make(chan struct{})}
+}
+
+func (b *benchCollector) add(tx *benchTx) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.firstTx.IsZero() || tx.Submitted.Before(b.firstTx) {
+		b.firstTx = tx.Submitted
+	}
+	b.pending[tx.Node] = append(b.pending[tx.Node], tx)
+	b.submitted[tx.Node]++
+}
+
+func (b *benchCollector) nodeError(nodeName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errors[nodeName]++
+}
+
+func (b *benchCollector) pendingCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	pending := 0
+	for _, txs := range b.pending {
+		pending += len(txs)
+	}
+	return pending
+}
+
+func (b *benchCollector) collect() {
+	defer close(b.stopped)
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-time.After(b.poll):
+		}
+		b.mu.Lock()
+		pending := map[string][]*benchTx{}
+		for nodeName, txs := range b.pending {
+			pending[nodeName] = append([]*benchTx{}, txs...)
+		}
+		b.mu.Unlock()
+		for nodeName, txs := range pending {
+			if len(txs) == 0 {
+				continue
+			}
+			var requests []rpcRequest
+			for _, tx := range txs {
+				requests = append(requests, rpcRequest{Method: "eth_getTransactionReceipt", Params: []interface{}{tx.Hash}})
+			}
+			responses, err := nodeRpcBatch(b.pods[nodeName], b.namespace, requests)
+			if err != nil {
+				continue
+			}
+			observed := time.Now()
+			minedTxs := map[*benchTx]bool{}
+			b.mu.Lock()
+			for i, res := range responses {
+				if res.Error != nil || len(res.Result) == 0 || string(res.Result) == "null" {
+					continue
+				}
+				var receipt rpcReceipt
+				if json.Unmarshal(res.Result, &receipt) != nil {
+					continue
+				}
+				minedTxs[txs[i]] = true
+				if receipt.Status == "0x0" {
+					b.failed++
+					continue
+				}
+				b.mined[nodeName]++
+				b.latencies = append(b.latencies, observed.Sub(txs[i].Submitted))
+				b.lastMined = observed
+			}
+			var stillPending []*benchTx
+			for _, tx := range b.pending[nodeName] {
+				if !minedTxs[tx] {
+					stillPending = append(stillPending, tx)
+				}
+			}
+			b.pending[nodeName] = stillPending
+			b.mu.Unlock()
+		}
+	}
+}
+
+// wait waits for the pending receipts up to the timeout, then stops collecting.
+func (b *benchCollector) wait(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for b.pendingCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(b.poll)
+	}
+	close(b.done)
+	<-b.stopped
+}
+
+func (b *benchCollector) summarize(result *benchResult, submitEnd time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, nodeName := range result.Nodes {
+		result.Mined += b.mined[nodeName]
+		result.Pending += len(b.pending[nodeName])
+		result.PerNode = append(result.PerNode, benchNodeResult{Name: nodeName, Submitted: b.submitted[nodeName],
+			Mined: b.mined[nodeName], Errors: b.errors[nodeName]})
+	}
+	result.Failed = b.failed
+	if !b.firstTx.IsZero() {
+		submitSeconds := submitEnd.Sub(b.firstTx).Seconds()
+		result.DurationSeconds = submitSeconds
+		if submitSeconds > 0 {
+			result.SubmitTps = float64(result.Submitted) / submitSeconds
+		}
+		if minedSeconds := b.lastMined.Sub(b.firstTx).Seconds(); minedSeconds > 0 {
+			result.MinedTps = float64(result.Mined) / minedSeconds
+		}
+	}
+	result.Latency = latencyPercentiles(b.latencies)
+	if len(result.ErrorMessages) == 0 {
+		result.ErrorMessages = nil
+	}
+}
+
+// latencyPercentiles uses the nearest rank percentile of the sorted latencies.
+func latencyPercentiles(latencies []time.Duration) benchLatency {
+	var latency benchLatency
+	if len(latencies) == 0 {
+		return latency
+	}
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	ms := func(d time.Duration) float64 {
+		return math.Round(float64(d)/float64(time.Millisecond)*10) / 10
+	}
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		return ms(sorted[rank])
+	}
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	latency.MinMs = ms(sorted[0])
+	latency.MeanMs = ms(total / time.Duration(len(sorted)))
+	latency.P50Ms = percentile(50)
+	latency.P90Ms = percentile(90)
+	latency.P95Ms = percentile(95)
+	latency.P99Ms = percentile(99)
+	latency.MaxMs = ms(sorted[len(sorted)-1])
+	return latency
+}
+
+func displayBenchResult(result benchResult) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, fmt.Sprintf("  network\t%s, quorum %s, %d nodes", result.Consensus, result.QuorumVersion, result.NetworkNodes))
+	fmt.Fprintln(w, fmt.Sprintf("  submitted\t%d (%d public, %d private)", result.Submitted, result.SubmittedPublic,
+		result.Submitted-result.SubmittedPublic))
+	fmt.Fprintln(w, fmt.Sprintf("  mined\t%d", result.Mined))
+	fmt.Fprintln(w, fmt.Sprintf("  failed\t%d", result.Failed))
+	fmt.Fprintln(w, fmt.Sprintf("  pending\t%d", result.Pending))
+	fmt.Fprintln(w, fmt.Sprintf("  errors\t%d", result.Errors))
+	fmt.Fprintln(w, fmt.Sprintf("  submit tps\t%.1f", result.SubmitTps))
+	fmt.Fprintln(w, fmt.Sprintf("  mined tps\t%.1f", result.MinedTps))
+	l := result.Latency
+	fmt.Fprintln(w, fmt.Sprintf("  latency (ms)\tmin %.0f  mean %.0f  p50 %.0f  p90 %.0f  p95 %.0f  p99 %.0f  max %.0f",
+		l.MinMs, l.MeanMs, l.P50Ms, l.P90Ms, l.P95Ms, l.P99Ms, l.MaxMs))
+	w.Flush()
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  NODE\tSUBMITTED\tMINED\tERRORS")
+	for _, node := range result.PerNode {
+		fmt.Fprintln(w, fmt.Sprintf("  %s\t%d\t%d\t%d", node.Name, node.Submitted, node.Mined, node.Errors))
+	}
+	w.Flush()
+	if len(result.ErrorMessages) > 0 {
+		fmt.Println()
+		red.Println("  errors:")
+		// the most frequent errors first.
+		var messages []string
+		for message := range result.ErrorMessages {
+			messages = append(messages, message)
+		}
+		sort.Slice(messages, func(i, j int) bool {
+			if result.ErrorMessages[messages[i]] != result.ErrorMessages[messages[j]] {
+				return result.ErrorMessages[messages[i]] > result.ErrorMessages[messages[j]]
+			}
+			return messages[i] < messages[j]
+		})
+		for _, message := range messages {
+			red.Println(fmt.Sprintf("    %dx %s", result.ErrorMessages[message], message))
+		}
+	}
+	fmt.Println()
+}
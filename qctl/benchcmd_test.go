@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyPercentiles(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	latency := latencyPercentiles(latencies)
+	expected := benchLatency{MinMs: 1, MeanMs: 50.5, P50Ms: 50, P90Ms: 90, P95Ms: 95, P99Ms: 99, MaxMs: 100}
+	if latency != expected {
+		t.Fatalf("expected %+v, got %+v", expected, latency)
+	}
+	if latencyPercentiles(nil) != (benchLatency{}) {
+		t.Fatal("expected no latencies without mined transactions")
+	}
+}
@@ -1,6 +1,8 @@
 #!/bin/bash
 
 # "linux/amd64 linux/386 darwin/amd64 darwin/386 windows/amd64 windows/386"
-gox -osarch="linux/amd64 darwin/amd64"   ../...
+# the version is shown by qctl --version and in the qctl debug bundle.
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+gox -osarch="linux/amd64 darwin/amd64" -ldflags "-X main.version=$VERSION"   ../...
 # build for all osarch
 #gox ../...
@@ -79,7 +79,7 @@ var (
 			}
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			if configFileYaml.Cakeshop.Version == "" {
 				configFileYaml.Cakeshop.Version = cakeVersion
@@ -91,7 +91,7 @@ var (
 			fmt.Println(fmt.Sprintf("Adding cakeshop version [%s]", cakeVersion))
 			// write file back
 			WriteYamlConfig(configFileYaml, configFile)
-			fmt.Println("cakeshop has been added to the config file [%s]", configFile)
+			fmt.Println(fmt.Sprintf("cakeshop has been added to the config file [%s]", configFile))
 			fmt.Println("Next, generate the additional resources for cakeshop on k8s:")
 			fmt.Println()
 			fmt.Println("**********************************************************************************************")
@@ -179,7 +179,7 @@ var (
 
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			// remove from yaml
 			configFileYaml.Cakeshop = Cakeshop{}
@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the label of the network policies created by the chaos experiments, so they can always be removed.
+const chaosPolicyLabel = "qctl-chaos"
+
+// a chaos experiment injects a fault into the target nodes, holds it for the duration, heals it, then checks the
+// network recovered: every node makes progress and all the nodes agree on the chain.
+type chaosExperiment struct {
+	Name     string
+	Targets  []string
+	Duration time.Duration
+	Inject   func() error
+	Heal     func() error
+}
+
+// the result of the checks after the experiment.
+type chaosResult struct {
+	ProgressDuringFault uint64 // blocks made by the remaining nodes while the fault was held.
+	RecoveryTime        time.Duration
+	Recovered           bool
+	ChainProblems       int
+}
+
+// the simplified K8s NetworkPolicy generated for the partitions, see templates/k8s/network-policy.yaml.erb
+type k8sNetworkPolicy struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		PodSelector k8sLabelSelector       `json:"podSelector"`
+		PolicyTypes []string               `json:"policyTypes"`
+		Ingress     []k8sNetworkPolicyRule `json:"ingress"`
+		Egress      []k8sNetworkPolicyRule `json:"egress"`
+	} `json:"spec"`
+}
+
+type k8sLabelSelector struct {
+	MatchLabels      map[string]string     `json:"matchLabels,omitempty"`
+	MatchExpressions []k8sLabelRequirement `json:"matchExpressions,omitempty"`
+}
+
+type k8sLabelRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+type k8sNetworkPolicyPeer struct {
+	PodSelector k8sLabelSelector `json:"podSelector"`
+}
+
+type k8sNetworkPolicyPort struct {
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`
+}
+
+type k8sNetworkPolicyRule struct {
+	From  []k8sNetworkPolicyPeer `json:"from,omitempty"`
+	To    []k8sNetworkPolicyPeer `json:"to,omitempty"`
+	Ports []k8sNetworkPolicyPort `json:"ports,omitempty"`
+}
+
+// the flags shared by all the experiments, selecting the targets and configuring the recovery checks.
+func chaosFlags(durationUsage string, flags ...cli.Flag) []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "config, c",
+			Usage:    "Load configuration from `FULL_PATH_FILE`",
+			EnvVars:  []string{"QUBE_CONFIG"},
+			Required: true,
+		},
+		&cli.StringSliceFlag{
+			Name:  "node",
+			Usage: "the node(s) to inject the fault into, comma separated or repeated.",
+		},
+		&cli.BoolFlag{
+			Name:  "raft-leader",
+			Usage: "target the current raft leader (minter), for raft networks.",
+		},
+		&cli.BoolFlag{
+			Name:  "faulty-validators",
+			Usage: "target the F = (N-1)/3 validators an istanbul / qbft network of N validators must tolerate.",
+		},
+		&cli.DurationFlag{
+			Name:  "duration",
+			Usage: durationUsage,
+			Value: 30 * time.Second,
+		},
+		&cli.BoolFlag{
+			Name:  "expect-progress",
+			Usage: "fail the experiment if the remaining nodes did not make blocks while the fault was held.",
+		},
+		&cli.Uint64Flag{
+			Name:  "blocks",
+			Usage: "the number of new blocks every node must reach after the fault is healed.",
+			Value: 3,
+		},
+		&cli.DurationFlag{
+			Name:  "recovery-timeout",
+			Usage: "how long to wait for the network to recover after the fault is healed.",
+			Value: 3 * time.Minute,
+		},
+	}, flags...)
+}
+
+var (
+	// qctl chaos kill --raft-leader --duration=1m
+	// qctl chaos kill --node=quorum-node2 --duration=0
+	chaosKillCommand = cli.Command{
+		Name:  "kill",
+		Usage: "stop the target nodes, scales the deployments to 0 for the duration, or only deletes the pods if 0.",
+		Flags: chaosFlags("how long the nodes are kept down, 0 deletes the pods and lets K8s restart them."),
+		Action: func(c *cli.Context) error {
+			return runChaosCommand(c, "kill", func(namespace string, targets []string, duration time.Duration) (func() error, func() error) {
+				inject := func() error {
+					for _, target := range targets {
+						var cmd *exec.Cmd
+						if duration == 0 {
+							cmd = exec.Command("kubectl", "--namespace="+namespace, "delete", "pod",
+								podNameFromPrefix(target, namespace), "--wait=false")
+						} else {
+							cmd = exec.Command("kubectl", "--namespace="+namespace, "scale", "deployment",
+								target+"-deployment", "--replicas=0")
+						}
+						fmt.Println(cmd.String())
+						if err := runCmdQuiet(cmd); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+				heal := func() error {
+					if duration == 0 {
+						return nil
+					}
+					return scaleDeployments(targets, namespace, 1)
+				}
+				return inject, heal
+			})
+		},
+	}
+	// qctl chaos pause --faulty-validators --duration=1m --expect-progress
+	chaosPauseCommand = cli.Command{
+		Name:  "pause",
+		Usage: "pause (SIGSTOP) the quorum or tm process of the target nodes, then resume (SIGCONT) it.",
+		Flags: chaosFlags("how long the processes are paused, a long pause can fail the liveness probes and restart the container.",
+			&cli.StringFlag{
+				Name:  "container",
+				Usage: "quorum | tm",
+				Value: "quorum",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			container, process := "quorum", "geth"
+			switch c.String("container") {
+			case "quorum":
+			case "tm":
+				container, process = DefaultTmName, "java"
+			default:
+				return cli.Exit(fmt.Sprintf("invalid container [%s], must be quorum or tm", c.String("container")), 2)
+			}
+			return runChaosCommand(c, "pause "+c.String("container"), func(namespace string, targets []string, duration time.Duration) (func() error, func() error) {
+				signalTargets := func(sig string) error {
+					for _, target := range targets {
+						cmd := exec.Command("kubectl", "--namespace="+namespace, "exec", podNameFromPrefix(target, namespace),
+							"-c", container, "--", "pkill", sig, "-x", process)
+						fmt.Println(cmd.String())
+						if err := runCmdQuiet(cmd); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+				// pkill is not in every image (e.g. the tessera image), it is checked before any process is stopped.
+				checkPkill := func() error {
+					for _, target := range targets {
+						cmd := exec.Command("kubectl", "--namespace="+namespace, "exec", podNameFromPrefix(target, namespace),
+							"-c", container, "--", "sh", "-c", "command -v pkill")
+						if err := runCmdQuiet(cmd); err != nil {
+							return fmt.Errorf("pkill is not available in the [%s] container of node [%s], its process can not be paused", container, target)
+						}
+					}
+					return nil
+				}
+				stopped := false
+				inject := func() error {
+					if err := checkPkill(); err != nil {
+						return err
+					}
+					stopped = true
+					return signalTargets("-STOP")
+				}
+				heal := func() error {
+					if !stopped {
+						return nil
+					}
+					return signalTargets("-CONT")
+				}
+				return inject, heal
+			})
+		},
+	}
+	// qctl chaos isolate --node=quorum-node1 --duration=2m
+	chaosIsolateCommand = cli.Command{
+		Name:  "isolate",
+		Usage: "cut the target nodes off from the rest of the network with generated network policies, requires a CNI that enforces them (e.g. calico).",
+		Flags: chaosFlags("how long the nodes are cut off."),
+		Action: func(c *cli.Context) error {
+			return runChaosCommand(c, "isolate", func(namespace string, targets []string, duration time.Duration) (func() error, func() error) {
+				var groups [][]string
+				for _, target := range targets {
+					groups = append(groups, []string{target})
+				}
+				return partitionFaults(namespace, groups)
+			})
+		},
+	}
+	// qctl chaos partition --group=quorum-node1:quorum-node2 --duration=2m
+	// the nodes not in any group form their own group.
+	chaosPartitionCommand = cli.Command{
+		Name:  "partition",
+		Usage: "split the network into groups that can only reach the nodes in the same group, requires a CNI that enforces network policies.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:     "group",
+				Usage:    "the nodes of a group separated by ':', the groups comma separated or repeated, e.g. --group=quorum-node1:quorum-node2,quorum-node3",
+				Required: true,
+			},
+			&cli.DurationFlag{
+				Name:  "duration",
+				Usage: "how long the network is partitioned.",
+				Value: 30 * time.Second,
+			},
+			&cli.BoolFlag{
+				Name:  "expect-progress",
+				Usage: "fail the experiment if no group made blocks while the network was partitioned.",
+			},
+			&cli.Uint64Flag{
+				Name:  "blocks",
+				Usage: "the number of new blocks every node must reach after the partition is healed.",
+				Value: 3,
+			},
+			&cli.DurationFlag{
+				Name:  "recovery-timeout",
+				Usage: "how long to wait for the network to recover after the partition is healed.",
+				Value: 3 * time.Minute,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			nodeNames := getNodeNames(configFileYaml)
+			grouped := map[string]bool{}
+			var groups [][]string
+			for _, group := range splitFlagValues(c.StringSlice("group")) {
+				var members []string
+				for _, nodeName := range strings.Split(group, ":") {
+					if !containsString(nodeNames, nodeName) {
+						return cli.Exit(fmt.Sprintf("node [%s] is not in the config file", nodeName), 2)
+					}
+					if grouped[nodeName] {
+						return cli.Exit(fmt.Sprintf("node [%s] is in more than one group", nodeName), 2)
+					}
+					grouped[nodeName] = true
+					members = append(members, nodeName)
+				}
+				groups = append(groups, members)
+			}
+			var rest []string
+			for _, nodeName := range nodeNames {
+				if !grouped[nodeName] {
+					rest = append(rest, nodeName)
+				}
+			}
+			if len(rest) > 0 {
+				groups = append(groups, rest)
+			}
+			if len(groups) < 2 {
+				return cli.Exit("the partition needs at least two groups.", 2)
+			}
+			inject, heal := partitionFaults(namespace, groups)
+			var description []string
+			for _, group := range groups {
+				description = append(description, "["+strings.Join(group, " ")+"]")
+			}
+			experiment := chaosExperiment{Name: "partition " + strings.Join(description, " | "), Targets: nodeNames,
+				Duration: c.Duration("duration"), Inject: inject, Heal: heal}
+			// while partitioned any group may be the one making progress.
+			result, err := runChaosExperiment(experiment, nodeNames, nil, namespace, configFileYaml.Genesis.Consensus,
+				c.Uint64("blocks"), c.Duration("recovery-timeout"))
+			return finishChaosExperiment(c, experiment, result, err)
+		},
+	}
+)
+
+// runChaosCommand selects the targets from the flags, then runs the experiment with the faults built by newFaults.
+func runChaosCommand(c *cli.Context, name string, newFaults func(namespace string, targets []string, duration time.Duration) (func() error, func() error)) error {
+	namespace := c.String("namespace")
+	configFile := c.String("config")
+	configFileYaml, err := LoadYamlConfig(configFile)
+	if err != nil {
+		log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+	}
+	nodeNames := getNodeNames(configFileYaml)
+	consensus := configFileYaml.Genesis.Consensus
+	targets, err := chaosTargets(c, nodeNames, namespace, consensus)
+	if err != nil {
+		return err
+	}
+	var remaining []string
+	for _, nodeName := range nodeNames {
+		if !containsString(targets, nodeName) {
+			remaining = append(remaining, nodeName)
+		}
+	}
+	inject, heal := newFaults(namespace, targets, c.Duration("duration"))
+	experiment := chaosExperiment{Name: name, Targets: targets, Duration: c.Duration("duration"), Inject: inject, Heal: heal}
+	result, err := runChaosExperiment(experiment, nodeNames, remaining, namespace, consensus, c.Uint64("blocks"),
+		c.Duration("recovery-timeout"))
+	return finishChaosExperiment(c, experiment, result, err)
+}
+
+// chaosTargets returns the nodes selected by --node, --raft-leader and --faulty-validators.
+func chaosTargets(c *cli.Context, nodeNames []string, namespace, consensus string) ([]string, error) {
+	var targets []string
+	for _, nodeName := range splitFlagValues(c.StringSlice("node")) {
+		if !containsString(nodeNames, nodeName) {
+			return nil, cli.Exit(fmt.Sprintf("node [%s] is not in the config file", nodeName), 2)
+		}
+		targets = append(targets, nodeName)
+	}
+	if c.Bool("raft-leader") {
+		if consensus != RaftConsensus {
+			return nil, cli.Exit(fmt.Sprintf("--raft-leader requires a raft network, the network is [%s]", consensus), 2)
+		}
+		leader := ""
+		for _, nodeName := range nodeNames {
+			if nodeConsensusRole(podNameFromPrefix(nodeName, namespace), namespace, consensus) == "minter" {
+				leader = nodeName
+				break
+			}
+		}
+		if leader == "" {
+			return nil, cli.Exit("unable to find the raft leader.", 3)
+		}
+		green.Println(fmt.Sprintf("  raft leader is [%s]", leader))
+		if !containsString(targets, leader) {
+			targets = append(targets, leader)
+		}
+	}
+	if c.Bool("faulty-validators") {
+		if consensus != IstanbulConsensus && consensus != QibftConsensus {
+			return nil, cli.Exit(fmt.Sprintf("--faulty-validators requires an istanbul or qbft network, the network is [%s]", consensus), 2)
+		}
+		var validators []string
+		for _, nodeName := range nodeNames {
+			if nodeConsensusRole(podNameFromPrefix(nodeName, namespace), namespace, consensus) == "validator" {
+				validators = append(validators, nodeName)
+			}
+		}
+		f := (len(validators) - 1) / 3
+		if f < 1 {
+			return nil, cli.Exit(fmt.Sprintf("the network has %d validators, at least 4 are needed to tolerate a faulty validator", len(validators)), 2)
+		}
+		sort.Strings(validators)
+		green.Println(fmt.Sprintf("  %d validators, targeting F=%d %v", len(validators), f, validators[:f]))
+		for _, validator := range validators[:f] {
+			if !containsString(targets, validator) {
+				targets = append(targets, validator)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil, cli.Exit("no target nodes, set --node, --raft-leader or --faulty-validators.", 2)
+	}
+	return targets, nil
+}
+
+// runChaosExperiment injects the fault, holds it, heals it and checks the network recovered. The fault is always
+// healed, also when qctl is interrupted while the fault is held.
+func runChaosExperiment(experiment chaosExperiment, nodeNames, remaining []string, namespace, consensus string, blocks uint64,
+	recoveryTimeout time.Duration) (chaosResult, error) {
+	var result chaosResult
+	if remaining == nil {
+		remaining = nodeNames
+	}
+	startHeight := maxBlockNumber(remaining, namespace)
+	green.Println(fmt.Sprintf("  injecting [%s] into %v for [%v], starting at block [%d]", experiment.Name,
+		experiment.Targets, experiment.Duration, startHeight))
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+
+	if err := experiment.Inject(); err != nil {
+		red.Println(fmt.Sprintf("  unable to inject the fault: %v", err))
+		if healErr := experiment.Heal(); healErr != nil {
+			red.Println(fmt.Sprintf("  unable to heal the fault: %v", healErr))
+		}
+		return result, cli.Exit("the fault could not be injected.", 3)
+	}
+	select {
+	case <-time.After(experiment.Duration):
+	case <-interrupted:
+		red.Println("  interrupted, healing the fault.")
+		if err := experiment.Heal(); err != nil {
+			return result, cli.Exit(fmt.Sprintf("unable to heal the fault: %v", err), 3)
+		}
+		return result, cli.Exit("the experiment was interrupted.", 1)
+	}
+	if height := maxBlockNumber(remaining, namespace); height > startHeight {
+		result.ProgressDuringFault = height - startHeight
+	}
+	green.Println(fmt.Sprintf("  healing the fault, the remaining nodes made [%d] blocks during the fault", result.ProgressDuringFault))
+	if err := experiment.Heal(); err != nil {
+		return result, cli.Exit(fmt.Sprintf("unable to heal the fault: %v", err), 3)
+	}
+
+	healed := time.Now()
+	target := maxBlockNumber(nodeNames, namespace) + blocks
+	green.Println(fmt.Sprintf("  waiting up to [%v] for all nodes to reach block [%d]", recoveryTimeout, target))
+	result.Recovered = waitForBlock(nodeNames, namespace, consensus, target, recoveryTimeout)
+	result.RecoveryTime = time.Since(healed)
+	if !result.Recovered {
+		return result, nil
+	}
+	problems, _, err := checkChain(nodeNames, namespace, nil)
+	if err != nil {
+		return result, err
+	}
+	result.ChainProblems = problems
+	return result, nil
+}
+
+func finishChaosExperiment(c *cli.Context, experiment chaosExperiment, result chaosResult, err error) error {
+	if err != nil {
+		return err
+	}
+	failed := false
+	if c.Bool("expect-progress") && result.ProgressDuringFault == 0 {
+		red.Println(fmt.Sprintf("  no blocks were made during [%s].", experiment.Name))
+		failed = true
+	}
+	if !result.Recovered {
+		red.Println(fmt.Sprintf("  the network did not recover within [%v].", c.Duration("recovery-timeout")))
+		failed = true
+	} else {
+		green.Println(fmt.Sprintf("  the network recovered in [%v].", result.RecoveryTime.Round(time.Second)))
+	}
+	if result.ChainProblems > 0 {
+		red.Println(fmt.Sprintf("  %d node(s) are not on the same chain as the network after the fault.", result.ChainProblems))
+		failed = true
+	}
+	fmt.Println()
+	if failed {
+		return cli.Exit(fmt.Sprintf("experiment [%s] failed.", experiment.Name), 1)
+	}
+	green.Println(fmt.Sprintf("  experiment [%s] passed.", experiment.Name))
+	return nil
+}
+
+// waitForBlock waits for all the nodes to reach the height. Raft only makes blocks for transactions, so a probe
+// transaction is sent while waiting on a raft network.
+func waitForBlock(nodeNames []string, namespace, consensus string, height uint64, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		reached := 0
+		for _, nodeName := range nodeNames {
+			blockNumber, err := nodeBlockNumber(podNameFromPrefix(nodeName, namespace), namespace)
+			if err == nil && blockNumber >= height {
+				reached++
+			}
+		}
+		if reached == len(nodeNames) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		if consensus == RaftConsensus {
+			sendProbeTransaction(nodeNames, namespace)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// sendProbeTransaction sends an empty contract creation from the first node that accepts it.
+func sendProbeTransaction(nodeNames []string, namespace string) {
+	for _, nodeName := range nodeNames {
+		podName := podNameFromPrefix(nodeName, namespace)
+		account, err := nodeAccount(podName, namespace)
+		if err != nil {
+			continue
+		}
+		if _, err := sendTransaction(podName, namespace, rpcTx{From: account, Data: benchTxData, Gas: benchTxGas}); err == nil {
+			return
+		}
+	}
+}
+
+// maxBlockNumber returns the highest block of the reachable nodes.
+func maxBlockNumber(nodeNames []string, namespace string) uint64 {
+	height := uint64(0)
+	for _, nodeName := range nodeNames {
+		blockNumber, err := nodeBlockNumber(podNameFromPrefix(nodeName, namespace), namespace)
+		if err == nil && blockNumber > height {
+			height = blockNumber
+		}
+	}
+	return height
+}
+
+func scaleDeployments(nodeNames []string, namespace string, replicas int) error {
+	for _, nodeName := range nodeNames {
+		cmd := exec.Command("kubectl", "--namespace="+namespace, "scale", "deployment", nodeName+"-deployment",
+			fmt.Sprintf("--replicas=%d", replicas))
+		fmt.Println(cmd.String())
+		if err := runCmdQuiet(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionFaults returns the fault that only allows the nodes in the same group to reach each other. Network
+// policies only add allowed traffic, so the policies of the network (network-policy.yaml.erb) are suspended while the
+// network is partitioned, and restored from a backup when it is healed.
+func partitionFaults(namespace string, groups [][]string) (func() error, func() error) {
+	backupFile := ""
+	inject := func() error {
+		var err error
+		backupFile, err = suspendNetworkPolicies(namespace)
+		if err != nil {
+			return err
+		}
+		for i, group := range groups {
+			policy := partitionNetworkPolicy(fmt.Sprintf("%s-group-%d", chaosPolicyLabel, i+1), group)
+			policyJson, err := json.Marshal(policy)
+			if err != nil {
+				return err
+			}
+			cmd := exec.Command("kubectl", "--namespace="+namespace, "apply", "-f", "-")
+			cmd.Stdin = bytes.NewReader(policyJson)
+			fmt.Println(fmt.Sprintf("%s  # %s %v", cmd.String(), policy.Metadata.Name, group))
+			if err := runCmdQuiet(cmd); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	heal := func() error {
+		cmd := exec.Command("kubectl", "--namespace="+namespace, "delete", "networkpolicy", "-l", chaosPolicyLabel+"=true")
+		fmt.Println(cmd.String())
+		if err := runCmdQuiet(cmd); err != nil {
+			return err
+		}
+		if backupFile == "" {
+			return nil
+		}
+		cmd = exec.Command("kubectl", "--namespace="+namespace, "apply", "-f", backupFile)
+		fmt.Println(cmd.String())
+		if err := runCmdQuiet(cmd); err != nil {
+			return fmt.Errorf("unable to restore the network policies from [%s]: %v", backupFile, err)
+		}
+		os.Remove(backupFile)
+		return nil
+	}
+	return inject, heal
+}
+
+// partitionNetworkPolicy allows the nodes of the group to reach each other, and DNS so the services still resolve.
+func partitionNetworkPolicy(name string, group []string) k8sNetworkPolicy {
+	var selector k8sLabelSelector
+	var deployments []string
+	for _, nodeName := range group {
+		deployments = append(deployments, nodeName+"-deployment")
+	}
+	selector.MatchExpressions = []k8sLabelRequirement{{Key: "name", Operator: "In", Values: deployments}}
+
+	var policy k8sNetworkPolicy
+	policy.ApiVersion = "networking.k8s.io/v1"
+	policy.Kind = "NetworkPolicy"
+	policy.Metadata.Name = name
+	policy.Metadata.Labels = map[string]string{chaosPolicyLabel: "true"}
+	policy.Spec.PodSelector = selector
+	policy.Spec.PolicyTypes = []string{"Ingress", "Egress"}
+	policy.Spec.Ingress = []k8sNetworkPolicyRule{{From: []k8sNetworkPolicyPeer{{PodSelector: selector}}}}
+	policy.Spec.Egress = []k8sNetworkPolicyRule{
+		{To: []k8sNetworkPolicyPeer{{PodSelector: selector}}},
+		{Ports: []k8sNetworkPolicyPort{{Protocol: "UDP", Port: 53}, {Protocol: "TCP", Port: 53}}},
+	}
+	return policy
+}
+
+// suspendNetworkPolicies deletes the network policies not created by qctl chaos, after saving them to a backup file.
+// Returns the backup file, or "" if the namespace has no network policies.
+func suspendNetworkPolicies(namespace string) (string, error) {
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "get", "networkpolicy", "-l", "!"+chaosPolicyLabel, "-o", "json")
+	out, err := runCmd(cmd)
+	if err != nil {
+		return "", fmt.Errorf("unable to get the network policies: %v", err)
+	}
+	var policies struct {
+		ApiVersion string                   `json:"apiVersion"`
+		Kind       string                   `json:"kind"`
+		Items      []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &policies); err != nil {
+		return "", err
+	}
+	if len(policies.Items) == 0 {
+		return "", nil
+	}
+	var names []string
+	for _, item := range policies.Items {
+		// the server set fields would fail the apply of the restored policies.
+		if metadata, ok := item["metadata"].(map[string]interface{}); ok {
+			names = append(names, fmt.Sprintf("%v", metadata["name"]))
+			for _, field := range []string{"resourceVersion", "uid", "creationTimestamp", "generation", "managedFields", "selfLink"} {
+				delete(metadata, field)
+			}
+		}
+		delete(item, "status")
+	}
+	backup, err := json.MarshalIndent(policies, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	backupFile, err := ioutil.TempFile("", "qctl-chaos-networkpolicies-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer backupFile.Close()
+	if _, err := backupFile.Write(backup); err != nil {
+		return "", err
+	}
+	red.Println(fmt.Sprintf("  suspending the network policies %v, backed up to [%s]", names, backupFile.Name()))
+	cmd = exec.Command("kubectl", append([]string{"--namespace=" + namespace, "delete", "networkpolicy"}, names...)...)
+	fmt.Println(cmd.String())
+	if err := runCmdQuiet(cmd); err != nil {
+		return "", err
+	}
+	return backupFile.Name(), nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
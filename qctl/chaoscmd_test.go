@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPartitionNetworkPolicy(t *testing.T) {
+	dns := k8sNetworkPolicyRule{Ports: []k8sNetworkPolicyPort{{Protocol: "UDP", Port: 53}, {Protocol: "TCP", Port: 53}}}
+	tests := []struct {
+		name        string
+		group       []string
+		deployments []string
+	}{
+		{"qctl-chaos-isolate-0", []string{"quorum-node1"}, []string{"quorum-node1-deployment"}},
+		{"qctl-chaos-partition-1", []string{"quorum-node2", "quorum-node3"},
+			[]string{"quorum-node2-deployment", "quorum-node3-deployment"}},
+	}
+	for _, test := range tests {
+		policy := partitionNetworkPolicy(test.name, test.group)
+		selector := k8sLabelSelector{MatchExpressions: []k8sLabelRequirement{{Key: "name", Operator: "In", Values: test.deployments}}}
+		if policy.Metadata.Name != test.name || policy.Metadata.Labels[chaosPolicyLabel] != "true" {
+			t.Errorf("%s: unexpected metadata %+v", test.name, policy.Metadata)
+		}
+		if !reflect.DeepEqual(policy.Spec.PodSelector, selector) {
+			t.Errorf("%s: pod selector %+v, expected %+v", test.name, policy.Spec.PodSelector, selector)
+		}
+		if !reflect.DeepEqual(policy.Spec.PolicyTypes, []string{"Ingress", "Egress"}) {
+			t.Errorf("%s: policy types %v", test.name, policy.Spec.PolicyTypes)
+		}
+		// only the group can reach the nodes, and the nodes can only reach the group and DNS.
+		ingress := []k8sNetworkPolicyRule{{From: []k8sNetworkPolicyPeer{{PodSelector: selector}}}}
+		if !reflect.DeepEqual(policy.Spec.Ingress, ingress) {
+			t.Errorf("%s: ingress %+v, expected %+v", test.name, policy.Spec.Ingress, ingress)
+		}
+		egress := []k8sNetworkPolicyRule{{To: []k8sNetworkPolicyPeer{{PodSelector: selector}}}, dns}
+		if !reflect.DeepEqual(policy.Spec.Egress, egress) {
+			t.Errorf("%s: egress %+v, expected %+v", test.name, policy.Spec.Egress, egress)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the view of the chain from a single node.
+type nodeChainView struct {
+	Name        string
+	PodName     string
+	Height      uint64
+	GenesisHash string
+	ChainId     string
+	ChainIdRpc  string   // eth_chainId, or net_version on older quorum versions, the values are not comparable.
+	Block       rpcBlock // the block at the common height.
+	Err         error
+}
+
+var (
+	// qctl check chain
+	// qctl check chain --height=1000
+	checkChainCommand = cli.Command{
+		Name:  "chain",
+		Usage: "check all nodes are on the same chain, compares the genesis, chain id and block at a common height.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.Uint64Flag{
+				Name:  "height",
+				Usage: "the height to compare the nodes at, defaults to the lowest height of the running nodes.",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			var height *uint64
+			if c.IsSet("height") {
+				flagHeight := c.Uint64("height")
+				height = &flagHeight
+			}
+			problems, commonHeight, err := checkChain(getNodeNames(configFileYaml), namespace, height)
+			if err != nil {
+				return err
+			}
+			if problems > 0 {
+				return cli.Exit(fmt.Sprintf("%d node(s) are not on the same chain as the network.", problems), 1)
+			}
+			green.Println(fmt.Sprintf("  All nodes agree on the chain at height [%d].", commonHeight))
+			fmt.Println()
+			return nil
+		},
+	}
+)
+
+// checkChain compares the nodes at the given height, or at the lowest height of the running nodes if nil, and returns
+// the number of nodes that are not on the majority chain.
+func checkChain(nodeNames []string, namespace string, height *uint64) (int, uint64, error) {
+	views := nodeChainViews(nodeNames, namespace)
+	commonHeight := uint64(0)
+	first := true
+	for _, view := range views {
+		if view.Err == nil && (first || view.Height < commonHeight) {
+			commonHeight = view.Height
+			first = false
+		}
+	}
+	if first {
+		return 0, 0, cli.Exit("unable to reach any node in the network.", 3)
+	}
+	if height != nil {
+		if *height > commonHeight {
+			return 0, 0, cli.Exit(fmt.Sprintf("height [%d] is above the lowest node height [%d]", *height, commonHeight), 2)
+		}
+		commonHeight = *height
+	}
+	for i := range views {
+		if views[i].Err != nil {
+			continue
+		}
+		views[i].Block, views[i].Err = nodeBlockByNumber(views[i].PodName, namespace, commonHeight)
+	}
+	problems, err := checkChainViews(views, commonHeight, namespace)
+	return problems, commonHeight, err
+}
+
+// nodeChainViews gets the height, genesis hash and chain id of every node.
+func nodeChainViews(nodeNames []string, namespace string) []nodeChainView {
+	var views []nodeChainView
+	for _, nodeName := range nodeNames {
+		view := nodeChainView{Name: nodeName, PodName: podNameFromPrefix(nodeName, namespace)}
+		view.Height, view.Err = nodeBlockNumber(view.PodName, namespace)
+		if view.Err == nil {
+			var genesis rpcBlock
+			genesis, view.Err = nodeBlockByNumber(view.PodName, namespace, 0)
+			view.GenesisHash = genesis.Hash
+		}
+		if view.Err == nil {
+			// eth_chainId is not available on older versions of quorum, fallback to the network id.
+			view.ChainIdRpc = "eth_chainId"
+			if err := nodeRpcInto(view.PodName, namespace, &view.ChainId, "eth_chainId"); err != nil {
+				view.ChainIdRpc = "net_version"
+				view.Err = nodeRpcInto(view.PodName, namespace, &view.ChainId, "net_version")
+			} else if chainId, err := hexToUint64(view.ChainId); err == nil {
+				view.ChainId = fmt.Sprintf("%d", chainId)
+			}
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// checkChainViews compares the nodes against the majority view of the chain, and for every node that has diverged,
+// searches for the first height it disagrees with the majority. Returns the number of nodes with problems.
+func checkChainViews(views []nodeChainView, commonHeight uint64, namespace string) (int, error) {
+	// the majority (genesis, block hash, state root) is taken as the canonical chain.
+	viewKey := func(v nodeChainView) string {
+		return v.GenesisHash + v.Block.Hash + v.Block.StateRoot
+	}
+	majority, ok := majorityKey(views, func(v nodeChainView) (string, bool) { return viewKey(v), v.Err == nil })
+	if !ok {
+		return 0, cli.Exit(fmt.Sprintf("no node could return the block at height [%d].", commonHeight), 3)
+	}
+	var reference nodeChainView
+	for _, view := range views {
+		if view.Err == nil && viewKey(view) == majority {
+			reference = view
+			break
+		}
+	}
+	// the chain id of the majority chain, per rpc, a node is only compared to the nodes that returned it the same way.
+	chainIds := map[string]string{}
+	for _, rpc := range []string{"eth_chainId", "net_version"} {
+		if chainId, ok := majorityKey(views, func(v nodeChainView) (string, bool) {
+			return v.ChainId, v.Err == nil && viewKey(v) == majority && v.ChainIdRpc == rpc
+		}); ok {
+			chainIds[rpc] = chainId
+		}
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, fmt.Sprintf("  NODE\tHEIGHT\tCHAIN ID\tGENESIS\tHASH @%d\tSTATE ROOT @%d\tSTATUS", commonHeight, commonHeight))
+	problems := 0
+	for _, view := range views {
+		if view.Err != nil {
+			problems++
+			fmt.Fprintln(w, fmt.Sprintf("  %s\t-\t-\t-\t-\t-\t%v", view.Name, view.Err))
+			continue
+		}
+		status := "ok"
+		chainId, chainIdKnown := chainIds[view.ChainIdRpc]
+		if viewKey(view) != majority {
+			problems++
+			switch {
+			case view.GenesisHash != reference.GenesisHash:
+				status = "different genesis"
+			case chainIdKnown && view.ChainId != chainId:
+				status = "different chain id"
+			default:
+				forkHeight, err := firstDivergentHeight(reference.PodName, view.PodName, namespace, commonHeight)
+				if err != nil {
+					status = fmt.Sprintf("diverged, unable to find the fork height: %v", err)
+				} else {
+					status = fmt.Sprintf("diverged at block %d", forkHeight)
+				}
+			}
+		} else if chainIdKnown && view.ChainId != chainId {
+			problems++
+			status = "different chain id"
+		}
+		fmt.Fprintln(w, fmt.Sprintf("  %s\t%d\t%s\t%s\t%s\t%s\t%s", view.Name, view.Height, view.ChainId,
+			shortHash(view.GenesisHash), shortHash(view.Block.Hash), shortHash(view.Block.StateRoot), status))
+	}
+	w.Flush()
+	fmt.Println()
+	return problems, nil
+}
+
+// majorityKey returns the key shared by the most views, the views not included are skipped. Ties go to the key of
+// the first node by name, so the result does not depend on the map order.
+func majorityKey(views []nodeChainView, key func(nodeChainView) (string, bool)) (string, bool) {
+	counts := map[string]int{}
+	firstNode := map[string]string{}
+	for _, view := range views {
+		k, included := key(view)
+		if !included {
+			continue
+		}
+		counts[k]++
+		if name, ok := firstNode[k]; !ok || view.Name < name {
+			firstNode[k] = view.Name
+		}
+	}
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return "", false
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return firstNode[keys[i]] < firstNode[keys[j]]
+	})
+	return keys[0], true
+}
+
+// firstDivergentHeight binary searches for the first block the two nodes disagree on, once the chains fork every
+// later block hash differs as it includes the parent hash.
+func firstDivergentHeight(referencePod, divergedPod, namespace string, commonHeight uint64) (uint64, error) {
+	low, high := uint64(0), commonHeight
+	for low < high {
+		mid := low + (high-low)/2
+		referenceBlock, err := nodeBlockByNumber(referencePod, namespace, mid)
+		if err != nil {
+			return 0, err
+		}
+		divergedBlock, err := nodeBlockByNumber(divergedPod, namespace, mid)
+		if err != nil {
+			return 0, err
+		}
+		if referenceBlock.Hash == divergedBlock.Hash && referenceBlock.StateRoot == divergedBlock.StateRoot {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	return low, nil
+}
+
+// shortHash abbreviates the hash for display, e.g. 0x1234ab...cdef01
+func shortHash(hash string) string {
+	if len(hash) <= 16 {
+		return hash
+	}
+	return hash[:8] + "..." + hash[len(hash)-6:]
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMajorityKey(t *testing.T) {
+	all := func(v nodeChainView) (string, bool) { return v.ChainId, v.Err == nil }
+	tests := []struct {
+		views    []nodeChainView
+		expected string
+	}{
+		{[]nodeChainView{{Name: "quorum-node1", ChainId: "a"}, {Name: "quorum-node2", ChainId: "b"},
+			{Name: "quorum-node3", ChainId: "b"}}, "b"},
+		// ties go to the first node by name, whatever the order of the views.
+		{[]nodeChainView{{Name: "quorum-node2", ChainId: "b"}, {Name: "quorum-node1", ChainId: "a"}}, "a"},
+		{[]nodeChainView{{Name: "quorum-node1", ChainId: "a", Err: errors.New("down")},
+			{Name: "quorum-node2", ChainId: "b"}}, "b"},
+	}
+	for _, test := range tests {
+		for i := 0; i < 10; i++ {
+			if key, ok := majorityKey(test.views, all); !ok || key != test.expected {
+				t.Fatalf("expected majority [%s], got [%s]", test.expected, key)
+			}
+		}
+	}
+	if _, ok := majorityKey([]nodeChainView{{Name: "quorum-node1", Err: errors.New("down")}}, all); ok {
+		t.Fatal("expected no majority without a reachable node")
+	}
+}
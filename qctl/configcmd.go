@@ -159,7 +159,7 @@ var (
 			configYaml.Genesis.QuorumVersion = quorumVersion
 			configYaml.Genesis.TmVersion = tmVersion
 			configYaml.Genesis.Consensus = consensus
-			if consensus == "qibft" { // if --qibftblock=BLOCK_NUM not specified set to default block 0, else the user can specify --qibftblock=BLOCK_NUM
+			if consensus == QibftConsensus { // if --qibftblock=BLOCK_NUM not specified set to default block 0, else the user can specify --qibftblock=BLOCK_NUM
 				configYaml.Genesis.QibftBlock = qibftblock
 			}
 			configYaml.Genesis.Chain_Id = chainId
@@ -181,7 +181,7 @@ var (
 			// try to write the generated file out to disk this file will be used to initialize the network.
 			err := ioutil.WriteFile(configFile, configBytes, 0644)
 			if err != nil {
-				log.Fatal("error writing configFile to [%v]. err: [%v]", configFile, err)
+				log.Fatalf("error writing configFile to [%v]. err: [%v]", configFile, err)
 			}
 			// TODO: check the config file was properly generated
 			// Set the configfile to the full path
@@ -285,7 +285,7 @@ var (
 			}
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			//TODO: get the global or passed in k8s dir.
 			fmt.Println()
@@ -65,6 +65,25 @@ type Prometheus struct {
 	//monitorParamsGeth string `yaml:"monitor_params_geth"`
 	NodePort string `yaml:"nodePort_prom,omitempty"`
 	Enabled  bool   `yaml:"enabled,omitempty"`
+	// host:port of the qctl exporter (qctl exporter --listen :9700) to add as a scrape target.
+	Exporter string `yaml:"exporter,omitempty"`
+	// deploy grafana with the prometheus datasource and the bundled dashboards.
+	Grafana         bool   `yaml:"grafana,omitempty"`
+	NodePortGrafana string `yaml:"nodePort_grafana,omitempty"`
+	// alertmanager is deployed when there is at least one receiver.
+	Alerting Alerting `yaml:"alerting,omitempty"`
+}
+
+type Alerting struct {
+	NodePort  string          `yaml:"nodePort_alertmanager,omitempty"`
+	Receivers []AlertReceiver `yaml:"receivers,omitempty"`
+}
+
+// a webhook receiver the alerts are sent to.
+type AlertReceiver struct {
+	Name         string `yaml:"name"`
+	Url          string `yaml:"url"`
+	SendResolved *bool  `yaml:"send_resolved,omitempty"`
 }
 
 type Cakeshop struct {
@@ -81,13 +100,47 @@ type Ingress struct {
 	Host     string `yaml:"Host,omitempty"`
 }
 
+// GoQuorum smart contract permissioning, the contracts are deployed to the running network by
+// `qctl generate permissioning`, which sets the contract addresses used to generate the permission-config.json.
+type Permissioning struct {
+	Enabled       bool                `yaml:"enabled,omitempty"`
+	NwAdminOrg    string              `yaml:"nwAdminOrg,omitempty"`
+	NwAdminRole   string              `yaml:"nwAdminRole,omitempty"`
+	OrgAdminRole  string              `yaml:"orgAdminRole,omitempty"`
+	Accounts      []string            `yaml:"accounts,omitempty"`
+	SubOrgBreadth int                 `yaml:"subOrgBreadth,omitempty"`
+	SubOrgDepth   int                 `yaml:"subOrgDepth,omitempty"`
+	Contracts     PermissionContracts `yaml:"contracts,omitempty"`
+}
+
+type PermissionContracts struct {
+	Upgradable string `yaml:"upgradable,omitempty"`
+	Interface  string `yaml:"interface,omitempty"`
+	Impl       string `yaml:"impl,omitempty"`
+	NodeMgr    string `yaml:"nodeMgr,omitempty"`
+	AccountMgr string `yaml:"accountMgr,omitempty"`
+	RoleMgr    string `yaml:"roleMgr,omitempty"`
+	VoterMgr   string `yaml:"voterMgr,omitempty"`
+	OrgMgr     string `yaml:"orgMgr,omitempty"`
+}
+
 type K8s struct {
-	Service struct {
+	// the namespace the resources are generated in, see templates/k8s/*.yaml.erb.
+	Namespace string `yaml:"namespace,omitempty"`
+	Service   struct {
 		Type    string  `yaml:"type,omitempty"`
 		Ingress Ingress `yaml:"Ingress,omitempty"`
 	}
 }
 
+// the paths are relative to the qubernetes dir, see docs/qubernetes-config.md.
+type Config struct {
+	Key_Dir_Base            string `yaml:"Key_Dir_Base,omitempty"`
+	Permissioned_Nodes_File string `yaml:"Permissioned_Nodes_File,omitempty"`
+	Genesis_File            string `yaml:"Genesis_File,omitempty"`
+	Tessera_Config_Dir      string `yaml:"Tessera_Config_Dir,omitempty"`
+}
+
 type QConfig struct {
 	Genesis struct {
 		Consensus     string `yaml:"consensus"`
@@ -99,6 +152,9 @@ type QConfig struct {
 	Prometheus Prometheus `yaml:"prometheus,omitempty"`
 	Cakeshop   Cakeshop   `yaml:"cakeshop,omitempty"`
 	K8s        K8s        `yaml:"k8s,omitempty"`
+	Config     Config     `yaml:"config,omitempty"`
+
+	Permissioning Permissioning `yaml:"permissioning,omitempty"`
 
 	Nodes         []NodeEntry
 	ExternalNodes []ExternalNodeEntry `yaml:"external_nodes,omitempty"`
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// whether the deployed contract code is visible on a node, and whether it should be.
+type contractVisibility struct {
+	Name     string
+	Visible  bool
+	Expected bool
+	Err      error
+}
+
+var (
+	// qctl contract deploy --artifact=build/contracts/SimpleStorage.json --args=42 --node=quorum-node1
+	// qctl contract deploy --artifact=SimpleStorage.bin --node=quorum-node1 --private-for=quorum-node2,quorum-node3
+	contractDeployCommand = cli.Command{
+		Name:  "deploy",
+		Usage: "deploy a compiled contract (truffle / solc json, or .bin with the .abi next to it) from a node.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "artifact",
+				Usage:    "the compiled contract, Name.json or Name.bin",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:  "args",
+				Usage: "the constructor arguments, comma separated or repeated, arrays as JSON e.g. --args='[1,2],\"a,b\"'",
+			},
+			&cli.StringFlag{
+				Name:     "node",
+				Usage:    "the node to deploy the contract from.",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:  "private-for",
+				Usage: "the node(s) the private contract is shared with, comma separated or repeated, the contract is public if not set.",
+			},
+			&cli.StringFlag{
+				Name:  "gas",
+				Usage: "the gas limit (hex or decimal), estimated if not set.",
+			},
+			&cli.StringFlag{
+				Name:  "name",
+				Usage: "the name the contract is registered as, defaults to the contract name of the artifact.",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			registry, err := loadContractRegistry(configFile)
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			nodeNames := getNodeNames(configFileYaml)
+			nodeName := c.String("node")
+			privateForNodes := splitFlagValues(c.StringSlice("private-for"))
+			for _, name := range append([]string{nodeName}, privateForNodes...) {
+				if !containsString(nodeNames, name) {
+					return cli.Exit(fmt.Sprintf("node [%s] is not in the config file", name), 2)
+				}
+			}
+			artifact, err := loadContractArtifact(c.String("artifact"))
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			abi, err := parseAbi(artifact.Abi)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("invalid abi in [%s]: %v", c.String("artifact"), err), 2)
+			}
+			args := splitFlagValues(c.StringSlice("args"))
+			if len(artifact.Abi) == 0 && len(args) > 0 {
+				return cli.Exit(fmt.Sprintf("the artifact [%s] has no abi to encode the constructor arguments", c.String("artifact")), 2)
+			}
+			encodedArgs, err := abiEncodeArgs(abiConstructor(abi).Inputs, args)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("invalid constructor arguments: %v", err), 2)
+			}
+			gas := ""
+			if c.String("gas") != "" {
+				gasLimit, err := strconv.ParseUint(c.String("gas"), 0, 64)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("invalid gas [%s]", c.String("gas")), 2)
+				}
+				gas = uint64ToHex(gasLimit)
+			}
+
+			podName := podNameFromPrefix(nodeName, namespace)
+			from, err := nodeAccount(podName, namespace)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to get the account of node [%s]: %v", nodeName, err), 3)
+			}
+			var privateFor []string
+			for _, name := range privateForNodes {
+				tmKey, err := getTmPublicKey(name, namespace)
+				if err != nil || tmKey == "" {
+					return cli.Exit(fmt.Sprintf("unable to get the tessera public key of node [%s]: %v", name, err), 3)
+				}
+				privateFor = append(privateFor, tmKey)
+			}
+
+			visibility := "public"
+			if len(privateFor) > 0 {
+				visibility = fmt.Sprintf("private for %v", privateForNodes)
+			}
+			green.Println(fmt.Sprintf("  Deploying [%s] from node [%s] account [%s], %s", artifact.Name, nodeName, from, visibility))
+			txHash, err := sendTransaction(podName, namespace, rpcTx{From: from, Data: artifact.Bytecode + encodedArgs,
+				Gas: gas, PrivateFor: privateFor})
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("deploying [%s] failed: %v", artifact.Name, err), 3)
+			}
+			fmt.Println(fmt.Sprintf("  transaction  %s", txHash))
+			receipt, err := waitForReceipt(podName, namespace, txHash, DefaultReceiptTimeout)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("deploying [%s] failed: %v", artifact.Name, err), 3)
+			}
+			blockNumber, _ := hexToUint64(receipt.BlockNumber)
+			fmt.Println(fmt.Sprintf("  block        %d", blockNumber))
+			green.Println(fmt.Sprintf("  address      %s", receipt.ContractAddress))
+
+			contract := registeredContract{Name: c.String("name"), Address: receipt.ContractAddress, Abi: artifact.Abi,
+				Node: nodeName, PrivateFor: privateForNodes, TxHash: txHash, BlockNumber: blockNumber,
+				Artifact: c.String("artifact"), Deployed: time.Now().UTC()}
+			if contract.Name == "" {
+				contract.Name = artifact.Name
+			}
+			if replaced, ok := registry.put(contract); ok {
+				red.Println(fmt.Sprintf("  replacing the registered contract [%s] at [%s]", replaced.Name, replaced.Address))
+			}
+			if err := saveContractRegistry(configFile, registry); err != nil {
+				return cli.Exit(fmt.Sprintf("unable to register the contract: %v", err), 3)
+			}
+			fmt.Println(fmt.Sprintf("  registered   %s in [%s]", contract.Name, contractRegistryFile(configFile)))
+
+			// a private contract is only visible to the sender and the privateFor nodes.
+			views := contractVisibilities(nodeNames, namespace, receipt.ContractAddress, blockNumber, func(name string) bool {
+				return len(privateFor) == 0 || name == nodeName || containsString(privateForNodes, name)
+			})
+			if problems := displayContractVisibilities(views); problems > 0 {
+				return cli.Exit(fmt.Sprintf("the contract code is not visible as expected on %d node(s).", problems), 1)
+			}
+			return nil
+		},
+	}
+	// qctl contract ls
+	// qctl contract ls --long
+	contractListCommand = cli.Command{
+		Name:    "list",
+		Aliases: []string{"ls"},
+		Usage:   "list the contracts deployed to the network with qctl contract deploy.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "long, l",
+				Usage: "also list the functions of the contracts.",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			registry, err := loadContractRegistry(c.String("config"))
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "  NAME\tADDRESS\tNODE\tPRIVATE FOR\tBLOCK\tDEPLOYED")
+			for _, contract := range registry.Contracts {
+				privateFor := "public"
+				if len(contract.PrivateFor) > 0 {
+					privateFor = strings.Join(contract.PrivateFor, ",")
+				}
+				fmt.Fprintln(w, fmt.Sprintf("  %s\t%s\t%s\t%s\t%d\t%s", contract.Name, contract.Address, contract.Node,
+					privateFor, contract.BlockNumber, contract.Deployed.Format(time.RFC3339)))
+			}
+			w.Flush()
+			fmt.Println()
+			if !c.Bool("long") {
+				return nil
+			}
+			for _, contract := range registry.Contracts {
+				green.Println(fmt.Sprintf("  %s", contract.Name))
+				abi, _ := parseAbi(contract.Abi)
+				for _, entry := range abi {
+					if entry.Type == "function" {
+						fmt.Println(fmt.Sprintf("    %s%s %sreturns %s", entry.Name, abiSignatureTypes(entry.Inputs),
+							abiMutability(entry), abiSignatureTypes(entry.Outputs)))
+					}
+				}
+				fmt.Println()
+			}
+			return nil
+		},
+	}
+	// qctl contract call SimpleStorage get
+	// qctl contract call --node=quorum-node2 SimpleStorage balanceOf 0xed9d02e382b34818e88b88a309c7fe71e65f419d
+	contractCallCommand = cli.Command{
+		Name:      "call",
+		Usage:     "call a (view) function of a registered contract with eth_call and decode the result.",
+		ArgsUsage: "<contract name | address> <function | signature> [args...]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "node",
+				Usage: "the node to call the contract on, defaults to the node that deployed it.",
+			},
+			&cli.StringFlag{
+				Name:  "block",
+				Usage: "the block number (or latest) to call the contract at.",
+				Value: "latest",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			contract, function, data, err := contractFunctionCall(c)
+			if err != nil {
+				return err
+			}
+			nodeName := contractNode(c, contract)
+			block := c.String("block")
+			if blockNumber, err := strconv.ParseUint(block, 10, 64); err == nil {
+				block = uint64ToHex(blockNumber)
+			}
+			podName := podNameFromPrefix(nodeName, namespace)
+			from, err := nodeAccount(podName, namespace)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to get the account of node [%s]: %v", nodeName, err), 3)
+			}
+			var result string
+			if err := nodeRpcInto(podName, namespace, &result, "eth_call", rpcTx{From: from, To: contract.Address, Data: data}, block); err != nil {
+				return cli.Exit(fmt.Sprintf("calling [%s.%s] failed: %v", contract.Name, function.Name, err), 3)
+			}
+			if (result == "" || result == "0x") && len(function.Outputs) > 0 {
+				// eth_call returns 0x when there is no code, e.g. a private contract on a node that is not a participant.
+				return cli.Exit(fmt.Sprintf("no result from [%s.%s] on node [%s], is the contract visible to the node? participants %v",
+					contract.Name, function.Name, nodeName, contract.participants()), 3)
+			}
+			resultBytes, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("invalid result [%s]: %v", result, err), 3)
+			}
+			values, err := abiDecodeValues(abiTypes(function.Outputs), resultBytes)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to decode the result [%s]: %v", result, err), 3)
+			}
+			for i, output := range function.Outputs {
+				displayAbiValue(output, i, values[i])
+			}
+			return nil
+		},
+	}
+	// qctl contract send SimpleStorage set 42
+	// qctl contract send --node=quorum-node2 SimpleStorage set 43
+	contractSendCommand = cli.Command{
+		Name:      "send",
+		Usage:     "send a transaction to a function of a registered contract, private contracts are sent privateFor the other participants.",
+		ArgsUsage: "<contract name | address> <function | signature> [args...]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "node",
+				Usage: "the node to send the transaction from, defaults to the node that deployed the contract.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "private-for",
+				Usage: "the node(s) the transaction is private for, comma separated or repeated, defaults to the other participants of the contract.",
+			},
+			&cli.StringFlag{
+				Name:  "gas",
+				Usage: "the gas limit (hex or decimal), estimated if not set.",
+			},
+			&cli.StringFlag{
+				Name:  "value",
+				Usage: "the wei (hex or decimal) to send with the transaction.",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			contract, function, data, err := contractFunctionCall(c)
+			if err != nil {
+				return err
+			}
+			nodeName := contractNode(c, contract)
+			privateForNodes := splitFlagValues(c.StringSlice("private-for"))
+			if !c.IsSet("private-for") && len(contract.PrivateFor) > 0 {
+				for _, participant := range contract.participants() {
+					if participant != nodeName {
+						privateForNodes = append(privateForNodes, participant)
+					}
+				}
+			}
+			tx := rpcTx{To: contract.Address, Data: data}
+			for _, flag := range []struct {
+				name  string
+				value *string
+			}{{"gas", &tx.Gas}, {"value", &tx.Value}} {
+				if c.String(flag.name) == "" {
+					continue
+				}
+				n, err := strconv.ParseUint(c.String(flag.name), 0, 64)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("invalid %s [%s]", flag.name, c.String(flag.name)), 2)
+				}
+				*flag.value = uint64ToHex(n)
+			}
+			for _, name := range privateForNodes {
+				tmKey, err := getTmPublicKey(name, namespace)
+				if err != nil || tmKey == "" {
+					return cli.Exit(fmt.Sprintf("unable to get the tessera public key of node [%s]: %v", name, err), 3)
+				}
+				tx.PrivateFor = append(tx.PrivateFor, tmKey)
+			}
+			podName := podNameFromPrefix(nodeName, namespace)
+			if tx.From, err = nodeAccount(podName, namespace); err != nil {
+				return cli.Exit(fmt.Sprintf("unable to get the account of node [%s]: %v", nodeName, err), 3)
+			}
+			visibility := "public"
+			if len(privateForNodes) > 0 {
+				visibility = fmt.Sprintf("private for %v", privateForNodes)
+			}
+			green.Println(fmt.Sprintf("  Sending [%s.%s] from node [%s] account [%s], %s", contract.Name,
+				function.Name+abiSignatureTypes(function.Inputs), nodeName, tx.From, visibility))
+			txHash, err := sendTransaction(podName, namespace, tx)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("sending [%s.%s] failed: %v", contract.Name, function.Name, err), 3)
+			}
+			fmt.Println(fmt.Sprintf("  transaction  %s", txHash))
+			receipt, err := waitForReceipt(podName, namespace, txHash, DefaultReceiptTimeout)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("sending [%s.%s] failed: %v", contract.Name, function.Name, err), 3)
+			}
+			blockNumber, _ := hexToUint64(receipt.BlockNumber)
+			gasUsed, _ := hexToUint64(receipt.GasUsed)
+			fmt.Println(fmt.Sprintf("  block        %d", blockNumber))
+			fmt.Println(fmt.Sprintf("  gas used     %d", gasUsed))
+			fmt.Println(fmt.Sprintf("  logs         %d", len(receipt.Logs)))
+			green.Println("  status       success")
+			return nil
+		},
+	}
+)
+
+// contractFunctionCall loads the contract from the registry and abi encodes the function call from the command args.
+func contractFunctionCall(c *cli.Context) (registeredContract, abiEntry, string, error) {
+	if c.Args().Len() < 2 {
+		return registeredContract{}, abiEntry{}, "", cli.Exit("the contract and function are required, see --help", 2)
+	}
+	registry, err := loadContractRegistry(c.String("config"))
+	if err != nil {
+		return registeredContract{}, abiEntry{}, "", cli.Exit(err.Error(), 2)
+	}
+	contract, ok := registry.get(c.Args().Get(0))
+	if !ok {
+		return contract, abiEntry{}, "", cli.Exit(fmt.Sprintf("contract [%s] is not registered, see qctl contract ls", c.Args().Get(0)), 2)
+	}
+	abi, err := parseAbi(contract.Abi)
+	if err != nil {
+		return contract, abiEntry{}, "", cli.Exit(fmt.Sprintf("invalid abi of contract [%s]: %v", contract.Name, err), 2)
+	}
+	args := c.Args().Slice()[2:]
+	function, err := abiFunction(abi, c.Args().Get(1), len(args))
+	if err != nil {
+		return contract, function, "", cli.Exit(err.Error(), 2)
+	}
+	encodedArgs, err := abiEncodeArgs(function.Inputs, args)
+	if err != nil {
+		return contract, function, "", cli.Exit(fmt.Sprintf("invalid arguments for [%s]: %v", function.Name, err), 2)
+	}
+	// the selector is hashed on the node the call is sent from, the node that deployed the contract may be down.
+	podName := podNameFromPrefix(contractNode(c, contract), c.String("namespace"))
+	selector, err := functionSelector(podName, c.String("namespace"), function.Name+abiSignatureTypes(function.Inputs))
+	if err != nil {
+		return contract, function, "", cli.Exit(fmt.Sprintf("unable to get the function selector: %v", err), 3)
+	}
+	return contract, function, selector + encodedArgs, nil
+}
+
+// contractNode returns the --node the call is sent from, defaults to the node that deployed the contract.
+func contractNode(c *cli.Context, contract registeredContract) string {
+	if c.String("node") != "" {
+		return c.String("node")
+	}
+	return contract.Node
+}
+
+func displayAbiValue(param abiParam, index int, value interface{}) {
+	name := param.Name
+	if name == "" {
+		name = strconv.Itoa(index)
+	}
+	if _, isArray := value.([]interface{}); isArray {
+		valueJson, _ := json.Marshal(value)
+		value = string(valueJson)
+	}
+	fmt.Println(fmt.Sprintf("  %s %s = %v", param.Type, name, value))
+}
+
+// abiMutability returns view / pure / payable for display, older abis only set constant.
+func abiMutability(entry abiEntry) string {
+	if entry.StateMutability != "" && entry.StateMutability != "nonpayable" {
+		return entry.StateMutability + " "
+	}
+	if entry.Constant {
+		return "view "
+	}
+	return ""
+}
+
+// contractVisibilities checks whether the contract code is on every node, once the node has the deployment block.
+func contractVisibilities(nodeNames []string, namespace, address string, blockNumber uint64, expected func(string) bool) []contractVisibility {
+	var views []contractVisibility
+	for _, nodeName := range nodeNames {
+		view := contractVisibility{Name: nodeName, Expected: expected(nodeName)}
+		podName := podNameFromPrefix(nodeName, namespace)
+		deadline := time.Now().Add(DefaultReceiptTimeout)
+		for {
+			var height uint64
+			height, view.Err = nodeBlockNumber(podName, namespace)
+			if view.Err != nil || height >= blockNumber {
+				break
+			}
+			if time.Now().After(deadline) {
+				view.Err = fmt.Errorf("node is at block %d, behind the deployment block %d", height, blockNumber)
+				break
+			}
+			time.Sleep(time.Second)
+		}
+		if view.Err == nil {
+			var code string
+			view.Err = nodeRpcInto(podName, namespace, &code, "eth_getCode", address, "latest")
+			view.Visible = code != "" && code != "0x"
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// displayContractVisibilities prints the visibility on every node, returns the number of nodes not as expected.
+func displayContractVisibilities(views []contractVisibility) int {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  NODE\tCODE\tEXPECTED\tSTATUS")
+	problems := 0
+	state := func(visible bool) string {
+		if visible {
+			return "visible"
+		}
+		return "absent"
+	}
+	for _, view := range views {
+		if view.Err != nil {
+			problems++
+			fmt.Fprintln(w, fmt.Sprintf("  %s\t-\t%s\t%v", view.Name, state(view.Expected), view.Err))
+			continue
+		}
+		status := "ok"
+		if view.Visible != view.Expected {
+			problems++
+			status = "unexpected"
+		}
+		fmt.Fprintln(w, fmt.Sprintf("  %s\t%s\t%s\t%s", view.Name, state(view.Visible), state(view.Expected), status))
+	}
+	w.Flush()
+	fmt.Println()
+	return problems
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// a contract event, written as a JSON line.
+type contractEvent struct {
+	Contract        string                 `json:"contract,omitempty"`
+	Address         string                 `json:"address"`
+	Event           string                 `json:"event,omitempty"`
+	Signature       string                 `json:"signature,omitempty"`
+	Args            map[string]interface{} `json:"args,omitempty"`
+	Node            string                 `json:"node"`
+	BlockNumber     uint64                 `json:"blockNumber"`
+	TransactionHash string                 `json:"transactionHash"`
+	LogIndex        uint64                 `json:"logIndex"`
+	Removed         bool                   `json:"removed,omitempty"`
+	// the raw log when it could not be decoded with the abi.
+	Topics []string `json:"topics,omitempty"`
+	Data   string   `json:"data,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// contractEventDecoder decodes the logs of the contract with the events of its abi, by the topic of the event
+// signature.
+type contractEventDecoder struct {
+	contract string
+	node     string
+	events   map[string]abiEntry // topic0 -> event
+}
+
+var (
+	// qctl events contract SimpleStorage
+	// qctl events contract SimpleStorage --node quorum-node2 --follow
+	// qctl events contract 0x1932c48b2bf8102ba33b4a6b545c32236e342f34 --abi SimpleStorage.json --follow --ws ws://quorum.ws.testnet.com/quorum-node1/quorum-ws
+	contractEventsCommand = cli.Command{
+		Name:      "contract",
+		Usage:     "stream the events of a contract as JSON lines, decoded with the abi. Private contract events are only visible on the participants.",
+		ArgsUsage: "<contract name | address>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "abi",
+				Usage: "the abi (.json / .abi) or the truffle / solc json artifact of the contract, defaults to the abi of the registered contract.",
+			},
+			&cli.StringFlag{
+				Name:  "node",
+				Usage: "the node to get the events from, defaults to the node that deployed the contract.",
+			},
+			&cli.StringFlag{
+				Name:  "event",
+				Usage: "only the events with the name, or signature e.g. Transfer(address,address,uint256).",
+			},
+			&cli.StringFlag{
+				Name:  "from-block",
+				Usage: "the first block to get the events from, defaults to 0, or the next block when following.",
+			},
+			&cli.BoolFlag{
+				Name:    "follow",
+				Aliases: []string{"f"},
+				Usage:   "keep streaming the new events.",
+			},
+			&cli.StringFlag{
+				Name:  "ws",
+				Usage: "follow over the websocket of the node, e.g. ws://HOST/quorum-node1/quorum-ws with Ingress ws: true, instead of polling.",
+			},
+			&cli.DurationFlag{
+				Name:  "poll",
+				Usage: "how often to poll for new events when following without --ws.",
+				Value: 2 * time.Second,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			if c.Args().Len() != 1 {
+				return cli.Exit("the contract name or address is required, see --help", 2)
+			}
+			if c.String("ws") != "" && !isWebsocketUrl(c.String("ws")) {
+				return cli.Exit(fmt.Sprintf("invalid --ws [%s], must start with ws:// or wss://", c.String("ws")), 2)
+			}
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			registry, err := loadContractRegistry(configFile)
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			contract, registered := registry.get(c.Args().First())
+			if !registered {
+				if !strings.HasPrefix(c.Args().First(), "0x") {
+					return cli.Exit(fmt.Sprintf("contract [%s] is not registered, see qctl contract ls", c.Args().First()), 2)
+				}
+				contract = registeredContract{Address: c.Args().First(), Node: getNodeNames(configFileYaml)[0]}
+			}
+			if c.String("abi") != "" {
+				if contract.Abi, err = loadAbiFile(c.String("abi")); err != nil {
+					return cli.Exit(fmt.Sprintf("unable to load the abi [%s]: %v", c.String("abi"), err), 2)
+				}
+			}
+			// the events followed over --ws come from the node of the websocket, the node must be the same as --node.
+			nodeName := c.String("node")
+			wsNode := ""
+			if c.String("ws") != "" {
+				wsNode = websocketNodeName(c.String("ws"), getNodeNames(configFileYaml))
+				switch {
+				case wsNode == "":
+					log.Warnf("unable to tell the node of --ws [%s] from the url, the followed events are labeled with the url.", c.String("ws"))
+				case nodeName == "":
+					nodeName = wsNode
+				case nodeName != wsNode:
+					return cli.Exit(fmt.Sprintf("--ws [%s] is the websocket of node [%s], not of --node [%s]", c.String("ws"), wsNode, nodeName), 2)
+				}
+			}
+			if nodeName == "" {
+				nodeName = contract.Node
+			}
+			if len(contract.PrivateFor) > 0 && !containsString(contract.participants(), nodeName) {
+				log.Warnf("node [%s] is not a participant of the private contract [%s], participants %v, no events will be visible.",
+					nodeName, contract.Name, contract.participants())
+			}
+			podName := podNameFromPrefix(nodeName, namespace)
+			abi, err := parseAbi(contract.Abi)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("invalid abi of contract [%s]: %v", contract.Address, err), 2)
+			}
+			decoder, err := newContractEventDecoder(contract.Name, nodeName, abi, func(signature string) (string, error) {
+				return web3Sha3(podName, namespace, signature)
+			})
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to get the event topics: %v", err), 3)
+			}
+			if len(decoder.events) == 0 {
+				log.Warnf("no abi events for contract [%s], the logs are not decoded.", contract.Address)
+			}
+			filter := map[string]interface{}{"address": contract.Address}
+			if c.String("event") != "" {
+				topic, err := decoder.topic(c.String("event"))
+				if err != nil {
+					return cli.Exit(err.Error(), 2)
+				}
+				filter["topics"] = []interface{}{topic}
+			}
+
+			head, err := nodeBlockNumber(podName, namespace)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to reach node [%s]: %v", nodeName, err), 3)
+			}
+			fromBlock := uint64(0)
+			if c.Bool("follow") {
+				fromBlock = head + 1
+			}
+			if c.String("from-block") != "" {
+				if fromBlock, err = strconv.ParseUint(c.String("from-block"), 0, 64); err != nil {
+					return cli.Exit(fmt.Sprintf("invalid --from-block [%s]", c.String("from-block")), 2)
+				}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			write := func(logs []rpcLog) error {
+				for _, rawLog := range logs {
+					if err := encoder.Encode(decoder.decode(rawLog)); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			// with --ws the subscription is made before the past logs are read, so no events are missed in between.
+			var ws *wsConn
+			var subscription string
+			if c.Bool("follow") && c.String("ws") != "" {
+				if ws, subscription, err = subscribeLogs(c.String("ws"), filter); err != nil {
+					return cli.Exit(fmt.Sprintf("unable to subscribe to the logs over [%s]: %v", c.String("ws"), err), 3)
+				}
+				defer ws.Close()
+			}
+			if fromBlock <= head {
+				logs, err := getLogs(podName, namespace, filter, fromBlock, head)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("unable to get the logs from node [%s]: %v", nodeName, err), 3)
+				}
+				if err := write(logs); err != nil {
+					return cli.Exit(err.Error(), 3)
+				}
+			}
+			if !c.Bool("follow") {
+				return nil
+			}
+			// the events are followed from --from-block when it is beyond the head.
+			lastBlock := head
+			if fromBlock > head {
+				lastBlock = fromBlock - 1
+			}
+			if ws != nil {
+				if wsNode == "" {
+					decoder.node = c.String("ws")
+				}
+				err = followLogsWebsocket(ws, subscription, lastBlock, write)
+			} else {
+				err = followLogsPolling(podName, namespace, filter, lastBlock, c.Duration("poll"), write)
+			}
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("stopped following the events: %v", err), 3)
+			}
+			return nil
+		},
+	}
+)
+
+// websocketNodeName returns the node of the websocket url, by the ingress host (quorum-node1.HOST) or path
+// (HOST/quorum-node1/quorum-ws), empty if the url has no node name.
+func websocketNodeName(wsUrl string, nodeNames []string) string {
+	u, err := url.Parse(wsUrl)
+	if err != nil {
+		return ""
+	}
+	parts := append([]string{strings.Split(u.Hostname(), ".")[0]}, strings.Split(u.Path, "/")...)
+	for _, part := range parts {
+		if containsString(nodeNames, part) {
+			return part
+		}
+	}
+	return ""
+}
+
+// loadAbiFile loads the abi from a file holding the abi array, or from a contract artifact.
+func loadAbiFile(abiFile string) (json.RawMessage, error) {
+	fileBytes, err := ioutil.ReadFile(abiFile)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(fileBytes)), "[") {
+		return fileBytes, nil
+	}
+	artifact, err := loadContractArtifact(abiFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(artifact.Abi) == 0 {
+		return nil, fmt.Errorf("no abi in [%s]", abiFile)
+	}
+	return artifact.Abi, nil
+}
+
+// newContractEventDecoder hashes the signatures of the (non anonymous) events of the abi, the anonymous events have no
+// signature topic so they can not be told apart.
+func newContractEventDecoder(contract, node string, abi []abiEntry, hash func(signature string) (string, error)) (*contractEventDecoder, error) {
+	decoder := &contractEventDecoder{contract: contract, node: node, events: map[string]abiEntry{}}
+	for _, entry := range abi {
+		if entry.Type != "event" || entry.Anonymous {
+			continue
+		}
+		topic, err := hash(entry.Name + abiSignatureTypes(entry.Inputs))
+		if err != nil {
+			return nil, err
+		}
+		decoder.events[strings.ToLower(topic)] = entry
+	}
+	return decoder, nil
+}
+
+// topic returns the signature topic of the event by name or signature.
+func (d *contractEventDecoder) topic(event string) (string, error) {
+	var matches []string
+	for topic, entry := range d.events {
+		if entry.Name == event || entry.Name+abiSignatureTypes(entry.Inputs) == event {
+			matches = append(matches, topic)
+		}
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no event [%s] in the abi", event)
+	}
+	if len(matches) > 1 {
+		return "", fmt.Errorf("event [%s] is overloaded, use the signature", event)
+	}
+	return matches[0], nil
+}
+
+// decode decodes the indexed args from the topics and the other args from the data. Indexed dynamic types (string,
+// bytes, arrays) are stored as their keccak256 hash, so the topic is returned for them.
+func (d *contractEventDecoder) decode(rawLog rpcLog) contractEvent {
+	event := contractEvent{Contract: d.contract, Address: rawLog.Address, Node: d.node,
+		TransactionHash: rawLog.TransactionHash, Removed: rawLog.Removed}
+	event.BlockNumber, _ = hexToUint64(rawLog.BlockNumber)
+	event.LogIndex, _ = hexToUint64(rawLog.LogIndex)
+	undecoded := func(err error) contractEvent {
+		event.Topics, event.Data = rawLog.Topics, rawLog.Data
+		if err != nil {
+			event.Error = err.Error()
+		}
+		return event
+	}
+	if len(rawLog.Topics) == 0 {
+		return undecoded(nil)
+	}
+	entry, found := d.events[strings.ToLower(rawLog.Topics[0])]
+	if !found {
+		return undecoded(nil)
+	}
+	event.Event, event.Signature = entry.Name, entry.Name+abiSignatureTypes(entry.Inputs)
+	var dataParams []abiParam
+	for _, param := range entry.Inputs {
+		if !param.Indexed {
+			dataParams = append(dataParams, param)
+		}
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(rawLog.Data, "0x"))
+	if err != nil {
+		return undecoded(fmt.Errorf("invalid data: %v", err))
+	}
+	dataValues, err := abiDecodeValues(abiTypes(dataParams), data)
+	if err != nil {
+		return undecoded(err)
+	}
+	event.Args = map[string]interface{}{}
+	topic, dataIndex := 1, 0
+	for i, param := range entry.Inputs {
+		name := param.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		if !param.Indexed {
+			event.Args[name] = dataValues[dataIndex]
+			dataIndex++
+			continue
+		}
+		if topic >= len(rawLog.Topics) {
+			return undecoded(fmt.Errorf("missing the topic of the indexed arg [%s]", name))
+		}
+		if abiIsDynamic(param.Type) || strings.HasSuffix(param.Type, "]") {
+			event.Args[name] = rawLog.Topics[topic]
+		} else {
+			topicBytes, _ := hex.DecodeString(strings.TrimPrefix(rawLog.Topics[topic], "0x"))
+			if event.Args[name], err = abiDecodeValue(param.Type, topicBytes, 0); err != nil {
+				return undecoded(err)
+			}
+		}
+		topic++
+	}
+	return event
+}
+
+func getLogs(podName, namespace string, filter map[string]interface{}, fromBlock, toBlock uint64) ([]rpcLog, error) {
+	query := map[string]interface{}{"fromBlock": uint64ToHex(fromBlock), "toBlock": uint64ToHex(toBlock)}
+	for key, value := range filter {
+		query[key] = value
+	}
+	var logs []rpcLog
+	err := nodeRpcInto(podName, namespace, &logs, "eth_getLogs", query)
+	return logs, err
+}
+
+// followLogsPolling gets the logs of the new blocks until the node can not be reached.
+func followLogsPolling(podName, namespace string, filter map[string]interface{}, lastBlock uint64, poll time.Duration,
+	write func([]rpcLog) error) error {
+	for {
+		time.Sleep(poll)
+		head, err := nodeBlockNumber(podName, namespace)
+		if err != nil {
+			return err
+		}
+		if head <= lastBlock {
+			continue
+		}
+		logs, err := getLogs(podName, namespace, filter, lastBlock+1, head)
+		if err != nil {
+			return err
+		}
+		if err := write(logs); err != nil {
+			return err
+		}
+		lastBlock = head
+	}
+}
+
+// subscribeLogs subscribes to the logs (eth_subscribe) over the websocket, returns the subscription id.
+func subscribeLogs(wsUrl string, filter map[string]interface{}) (*wsConn, string, error) {
+	ws, err := dialWebsocket(wsUrl)
+	if err != nil {
+		return nil, "", err
+	}
+	request, _ := json.Marshal(rpcRequest{Jsonrpc: "2.0", Method: "eth_subscribe", Params: []interface{}{"logs", filter}, Id: 1})
+	if err := ws.writeText(request); err != nil {
+		ws.Close()
+		return nil, "", err
+	}
+	message, err := ws.readMessage()
+	if err != nil {
+		ws.Close()
+		return nil, "", err
+	}
+	var response rpcResponse
+	var subscription string
+	if err := json.Unmarshal(message, &response); err != nil {
+		ws.Close()
+		return nil, "", fmt.Errorf("invalid response [%s]", string(message))
+	}
+	if response.Error != nil {
+		ws.Close()
+		return nil, "", fmt.Errorf("%s", response.Error.Message)
+	}
+	if err := json.Unmarshal(response.Result, &subscription); err != nil {
+		ws.Close()
+		return nil, "", fmt.Errorf("invalid subscription id [%s]", string(response.Result))
+	}
+	return ws, subscription, nil
+}
+
+// followLogsWebsocket writes the logs of the subscription, skipping the logs up to the block the past logs were read
+// to.
+func followLogsWebsocket(ws *wsConn, subscription string, lastBlock uint64, write func([]rpcLog) error) error {
+	for {
+		message, err := ws.readMessage()
+		if err == io.EOF {
+			return fmt.Errorf("the websocket was closed by the node")
+		}
+		if err != nil {
+			return err
+		}
+		var notification struct {
+			Method string `json:"method"`
+			Params struct {
+				Subscription string `json:"subscription"`
+				Result       rpcLog `json:"result"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(message, &notification); err != nil || notification.Method != "eth_subscription" ||
+			notification.Params.Subscription != subscription {
+			continue
+		}
+		if blockNumber, _ := hexToUint64(notification.Params.Result.BlockNumber); blockNumber <= lastBlock {
+			continue
+		}
+		if err := write([]rpcLog{notification.Params.Result}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// an ERC20 Transfer(address indexed from, address indexed to, uint256 value) and a string indexed event.
+func TestContractEventDecode(t *testing.T) {
+	abi := []abiEntry{
+		{Type: "event", Name: "Transfer", Inputs: []abiParam{{Name: "from", Type: "address", Indexed: true},
+			{Name: "to", Type: "address", Indexed: true}, {Name: "value", Type: "uint256"}}},
+		{Type: "event", Name: "Named", Inputs: []abiParam{{Name: "name", Type: "string", Indexed: true}, {Type: "bool"}}},
+	}
+	topics := map[string]string{
+		"Transfer(address,address,uint256)": "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		"Named(string,bool)":                "0x1111111111111111111111111111111111111111111111111111111111111111",
+	}
+	decoder, err := newContractEventDecoder("Token", "quorum-node1", abi, func(signature string) (string, error) {
+		return topics[signature], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic, err := decoder.topic("Transfer"); err != nil || topic != topics["Transfer(address,address,uint256)"] {
+		t.Errorf("topic of Transfer: %s %v", topic, err)
+	}
+
+	event := decoder.decode(rpcLog{Address: "0x1932c48b2bf8102ba33b4a6b545c32236e342f34",
+		Topics: []string{"0xDDF252AD1BE2C89B69C2B068FC378DAA952BA7F163C4A11628F55A4DF523B3EF",
+			"0x000000000000000000000000ca35b7d915458ef540ade6068dfe2f44e8fa733c",
+			"0x00000000000000000000000014723a09acff6d2a60dcdf7aa4aff308fddc160c"},
+		Data:        "0x00000000000000000000000000000000000000000000000000000000000003e8",
+		BlockNumber: "0x2a", TransactionHash: "0xabc", LogIndex: "0x1"})
+	expected := contractEvent{Contract: "Token", Address: "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", Event: "Transfer",
+		Signature: "Transfer(address,address,uint256)", Node: "quorum-node1", BlockNumber: 42, TransactionHash: "0xabc",
+		LogIndex: 1, Args: map[string]interface{}{"from": "0xca35b7d915458ef540ade6068dfe2f44e8fa733c",
+			"to": "0x14723a09acff6d2a60dcdf7aa4aff308fddc160c", "value": "1000"}}
+	if !reflect.DeepEqual(event, expected) {
+		t.Errorf("decode Transfer\n got: %+v\nwant: %+v", event, expected)
+	}
+
+	// the indexed string is only the hash of the string.
+	event = decoder.decode(rpcLog{Topics: []string{topics["Named(string,bool)"],
+		"0x2222222222222222222222222222222222222222222222222222222222222222"},
+		Data: "0x0000000000000000000000000000000000000000000000000000000000000001"})
+	if !reflect.DeepEqual(event.Args, map[string]interface{}{"name": "0x2222222222222222222222222222222222222222222222222222222222222222",
+		"1": true}) {
+		t.Errorf("decode Named: %+v", event.Args)
+	}
+
+	// unknown events are returned undecoded.
+	event = decoder.decode(rpcLog{Topics: []string{"0x3333"}, Data: "0x"})
+	if event.Event != "" || len(event.Topics) != 1 || event.Data != "0x" {
+		t.Errorf("decode unknown event: %+v", event)
+	}
+}
+
+func TestWebsocketNodeName(t *testing.T) {
+	nodeNames := []string{"quorum-node1", "quorum-node2"}
+	tests := map[string]string{
+		"ws://quorum.ws.testnet.com/quorum-node2/quorum-ws":   "quorum-node2",
+		"wss://quorum-node1.quorum.testnet.com:443/quorum-ws": "quorum-node1",
+		"ws://10.0.0.1:8546": "",
+		"ws://quorum.ws.testnet.com/quorum-node3/quorum-ws": "",
+	}
+	for wsUrl, expected := range tests {
+		if node := websocketNodeName(wsUrl, nodeNames); node != expected {
+			t.Errorf("websocketNodeName(%s) = [%s], expected [%s]", wsUrl, node, expected)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// the contracts deployed with qctl contract deploy are remembered per network in a registry file next to the config,
+// e.g. qubernetes.yaml -> qubernetes-contracts.json, so they can be called by name.
+
+type registeredContract struct {
+	Name        string          `json:"name"`
+	Address     string          `json:"address"`
+	Abi         json.RawMessage `json:"abi,omitempty"`
+	Node        string          `json:"node"`                 // the node that deployed the contract.
+	PrivateFor  []string        `json:"privateFor,omitempty"` // the node names, empty for a public contract.
+	TxHash      string          `json:"txHash"`
+	BlockNumber uint64          `json:"blockNumber"`
+	Artifact    string          `json:"artifact"`
+	Deployed    time.Time       `json:"deployed"`
+}
+
+type contractRegistry struct {
+	Contracts []registeredContract `json:"contracts"`
+}
+
+func contractRegistryFile(configFile string) string {
+	return strings.TrimSuffix(configFile, filepath.Ext(configFile)) + "-contracts.json"
+}
+
+// loadContractRegistry loads the registry of the network, an empty registry if no contracts were deployed yet.
+func loadContractRegistry(configFile string) (contractRegistry, error) {
+	var registry contractRegistry
+	registryBytes, err := ioutil.ReadFile(contractRegistryFile(configFile))
+	if os.IsNotExist(err) {
+		return registry, nil
+	}
+	if err != nil {
+		return registry, err
+	}
+	if err := json.Unmarshal(registryBytes, &registry); err != nil {
+		return registry, fmt.Errorf("invalid contract registry [%s]: %v", contractRegistryFile(configFile), err)
+	}
+	return registry, nil
+}
+
+func saveContractRegistry(configFile string, registry contractRegistry) error {
+	registryBytes, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(contractRegistryFile(configFile), append(registryBytes, '\n'), 0644)
+}
+
+// get returns the contract by name or address.
+func (r contractRegistry) get(nameOrAddress string) (registeredContract, bool) {
+	for _, contract := range r.Contracts {
+		if contract.Name == nameOrAddress || strings.EqualFold(contract.Address, nameOrAddress) {
+			return contract, true
+		}
+	}
+	return registeredContract{}, false
+}
+
+// put adds the contract, replacing a contract registered with the same name, returns the replaced contract.
+func (r *contractRegistry) put(contract registeredContract) (registeredContract, bool) {
+	for i, existing := range r.Contracts {
+		if existing.Name == contract.Name {
+			r.Contracts[i] = contract
+			return existing, true
+		}
+	}
+	r.Contracts = append(r.Contracts, contract)
+	return registeredContract{}, false
+}
+
+// participants returns the nodes that can see the private contract, the deploying node and the privateFor nodes.
+func (c registeredContract) participants() []string {
+	return append([]string{c.Node}, c.PrivateFor...)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// helpers for deploying and calling contracts on a node over JSON-RPC, see rpc.go
+
+// how long to wait for a transaction to be mined.
+var DefaultReceiptTimeout = 2 * time.Minute
+
+// a compiled contract, either solc output (Name.bin / Name.abi) or a truffle / solc json artifact (Name.json).
+type contractArtifact struct {
+	Name     string
+	Abi      json.RawMessage
+	Bytecode string
+}
+
+// the transaction fields used by eth_sendTransaction, eth_call and eth_estimateGas.
+type rpcTx struct {
+	From       string   `json:"from,omitempty"`
+	To         string   `json:"to,omitempty"`
+	Data       string   `json:"data,omitempty"`
+	Gas        string   `json:"gas,omitempty"`
+	Value      string   `json:"value,omitempty"`
+	PrivateFor []string `json:"privateFor,omitempty"`
+}
+
+type rpcReceipt struct {
+	TransactionHash string `json:"transactionHash"`
+	BlockNumber     string `json:"blockNumber"`
+	BlockHash       string `json:"blockHash"`
+	ContractAddress string `json:"contractAddress"`
+	Status          string `json:"status"`
+	GasUsed         string `json:"gasUsed"`
+	Logs            []struct {
+		Address string   `json:"address"`
+		Topics  []string `json:"topics"`
+		Data    string   `json:"data"`
+	} `json:"logs"`
+}
+
+// loadContractArtifact loads the artifact from a .json file (truffle / solc standard json output) or a .bin file, in which
+// case the abi is read from the .abi file next to it if there is one.
+func loadContractArtifact(artifactFile string) (contractArtifact, error) {
+	artifact := contractArtifact{Name: strings.TrimSuffix(filepath.Base(artifactFile), filepath.Ext(artifactFile))}
+	fileBytes, err := ioutil.ReadFile(artifactFile)
+	if err != nil {
+		return artifact, err
+	}
+	if filepath.Ext(artifactFile) != ".json" {
+		artifact.Bytecode = strings.TrimSpace(string(fileBytes))
+		abiFile := strings.TrimSuffix(artifactFile, filepath.Ext(artifactFile)) + ".abi"
+		if abiBytes, err := ioutil.ReadFile(abiFile); err == nil {
+			artifact.Abi = abiBytes
+		}
+	} else {
+		var jsonArtifact struct {
+			ContractName string          `json:"contractName"`
+			Abi          json.RawMessage `json:"abi"`
+			Bytecode     json.RawMessage `json:"bytecode"`
+			Bin          string          `json:"bin"`
+			Evm          struct {
+				Bytecode struct {
+					Object string `json:"object"`
+				} `json:"bytecode"`
+			} `json:"evm"`
+		}
+		if err := json.Unmarshal(fileBytes, &jsonArtifact); err != nil {
+			return artifact, fmt.Errorf("invalid contract artifact [%s]: %v", artifactFile, err)
+		}
+		if jsonArtifact.ContractName != "" {
+			artifact.Name = jsonArtifact.ContractName
+		}
+		artifact.Abi = jsonArtifact.Abi
+		// truffle stores the bytecode as a string, solc (hardhat) as {"object": "..."}
+		var bytecode string
+		if err := json.Unmarshal(jsonArtifact.Bytecode, &bytecode); err != nil {
+			var bytecodeObject struct {
+				Object string `json:"object"`
+			}
+			json.Unmarshal(jsonArtifact.Bytecode, &bytecodeObject)
+			bytecode = bytecodeObject.Object
+		}
+		if bytecode == "" {
+			bytecode = jsonArtifact.Bin
+		}
+		if bytecode == "" {
+			bytecode = jsonArtifact.Evm.Bytecode.Object
+		}
+		artifact.Bytecode = bytecode
+	}
+	if artifact.Bytecode == "" {
+		return artifact, fmt.Errorf("no bytecode found in contract artifact [%s]", artifactFile)
+	}
+	if !strings.HasPrefix(artifact.Bytecode, "0x") {
+		artifact.Bytecode = "0x" + artifact.Bytecode
+	}
+	return artifact, nil
+}
+
+// nodeAccount returns the first account of the node, the account the node unlocks at startup (--unlock 0).
+func nodeAccount(podName, namespace string) (string, error) {
+	var accounts []string
+	if err := nodeRpcInto(podName, namespace, &accounts, "eth_accounts"); err != nil {
+		return "", err
+	}
+	if len(accounts) == 0 {
+		return "", fmt.Errorf("no accounts found on pod [%s]", podName)
+	}
+	return accounts[0], nil
+}
+
+// sendTransaction sends the transaction from the node's unlocked account, estimating the gas if it was not set.
+func sendTransaction(podName, namespace string, tx rpcTx) (string, error) {
+	if tx.Gas == "" {
+		var gasHex string
+		estimateTx := tx
+		estimateTx.PrivateFor = nil
+		if err := nodeRpcInto(podName, namespace, &gasHex, "eth_estimateGas", estimateTx); err != nil {
+			return "", err
+		}
+		gas, err := hexToUint64(gasHex)
+		if err != nil {
+			return "", err
+		}
+		// leave some head room, private transactions and contract creation can vary from the estimate.
+		tx.Gas = uint64ToHex(gas + gas/2)
+	}
+	var txHash string
+	err := nodeRpcInto(podName, namespace, &txHash, "eth_sendTransaction", tx)
+	return txHash, err
+}
+
+// waitForReceipt polls for the transaction receipt until it is available or the timeout is reached.
+func waitForReceipt(podName, namespace, txHash string, timeout time.Duration) (rpcReceipt, error) {
+	var receipt rpcReceipt
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		raw, err := nodeRpc(podName, namespace, "eth_getTransactionReceipt", txHash)
+		if err != nil {
+			return receipt, err
+		}
+		if string(raw) != "null" {
+			err = json.Unmarshal(raw, &receipt)
+			if err == nil && receipt.Status == "0x0" {
+				err = fmt.Errorf("transaction [%s] failed (status 0x0)", txHash)
+			}
+			return receipt, err
+		}
+		time.Sleep(time.Second)
+	}
+	return receipt, fmt.Errorf("timed out waiting for the receipt of transaction [%s]", txHash)
+}
+
+// deployContract deploys the bytecode (with the already abi encoded constructor args appended) and waits for the
+// contract address.
+func deployContract(podName, namespace, from, bytecode string, privateFor []string) (rpcReceipt, error) {
+	txHash, err := sendTransaction(podName, namespace, rpcTx{From: from, Data: bytecode, PrivateFor: privateFor})
+	if err != nil {
+		return rpcReceipt{}, err
+	}
+	return waitForReceipt(podName, namespace, txHash, DefaultReceiptTimeout)
+}
+
+// functionSelector returns the 4 byte selector of the function signature, e.g. init(address,address), the keccak256
+// is calculated by the node (web3_sha3) so qctl does not need to depend on the ethereum crypto libs.
+func functionSelector(podName, namespace, signature string) (string, error) {
+	hash, err := web3Sha3(podName, namespace, signature)
+	if err != nil {
+		return "", err
+	}
+	return hash[:10], nil
+}
+
+// web3Sha3 returns the keccak256 hash (0x hex) of the text, calculated by the node.
+func web3Sha3(podName, namespace, text string) (string, error) {
+	var hash string
+	if err := nodeRpcInto(podName, namespace, &hash, "web3_sha3", "0x"+hex.EncodeToString([]byte(text))); err != nil {
+		return "", err
+	}
+	if len(hash) != 66 {
+		return "", fmt.Errorf("invalid hash [%s] for [%s]", hash, text)
+	}
+	return hash, nil
+}
+
+// abiEncodeAddress left pads the address to a 32 byte abi word (without 0x).
+func abiEncodeAddress(address string) string {
+	address = strings.ToLower(strings.TrimPrefix(address, "0x"))
+	return strings.Repeat("0", 64-len(address)) + address
+}
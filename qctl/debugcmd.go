@@ -0,0 +1,275 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// config values whose key matches are replaced before being added to the bundle.
+var secretConfigLine = regexp.MustCompile(`(?i)^(\s*-?\s*[\w.-]*(password|secret|token|private|credential)[\w.-]*\s*:\s*)\S.*$`)
+
+// collects files in memory and writes them as a gzipped tar.
+type debugBundle struct {
+	dir   string
+	files map[string][]byte
+	order []string
+}
+
+func (b *debugBundle) add(name string, content []byte) {
+	name = b.dir + "/" + name
+	if _, exists := b.files[name]; !exists {
+		b.order = append(b.order, name)
+	}
+	b.files[name] = content
+}
+
+// addCmd runs the command and adds its output, or the error, so a failing command does not stop the collection.
+func (b *debugBundle) addCmd(name string, cmd *exec.Cmd) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := runner.Run(cmd); err != nil {
+		fmt.Fprintf(&out, "\n[%s] failed: %v\n", strings.Join(cmd.Args, " "), err)
+	}
+	b.add(name, out.Bytes())
+}
+
+// addRpc adds the JSON-RPC result of the method on the pod, or the error.
+func (b *debugBundle) addRpc(name, podName, namespace, method string, params ...interface{}) {
+	result, err := nodeRpc(podName, namespace, method, params...)
+	if err != nil {
+		b.add(name, []byte(err.Error()+"\n"))
+		return
+	}
+	var out bytes.Buffer
+	if json.Indent(&out, result, "", "  ") != nil {
+		out.Write(result)
+	}
+	out.WriteString("\n")
+	b.add(name, out.Bytes())
+}
+
+func (b *debugBundle) write(outputFile string) error {
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, name := range b.order {
+		content := b.files[name]
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: time.Now()}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+var (
+	// qctl debug bundle -o net.tgz
+	// qctl debug bundle --tail=5000 --k8sdir=out
+	debugBundleCommand = cli.Command{
+		Name:  "bundle",
+		Usage: "collect the config, pod state, logs and node / tessera state of the network into a tgz for bug reports.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{ // the generated configs are only added if the k8sdir is set.
+				Name:    "k8sdir",
+				Usage:   "The k8sdir (usually out) containing the output k8s resources",
+				EnvVars: []string{"QUBE_K8S_DIR"},
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "the bundle file to write.",
+				Value:   fmt.Sprintf("qubernetes-debug-%s.tgz", time.Now().Format("20060102-150405")),
+			},
+			&cli.IntFlag{
+				Name:  "tail",
+				Usage: "number of log lines to collect from each container.",
+				Value: 1000,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			k8sdir := c.String("k8sdir")
+			outputFile := c.String("output")
+			tail := c.Int("tail")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			bundleDir := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(outputFile), ".tgz"), ".tar.gz")
+			bundle := &debugBundle{dir: bundleDir, files: map[string][]byte{}}
+
+			green.Println("  collecting versions and config")
+			bundle.add("versions.txt", []byte(fmt.Sprintf("qctl %s\n", c.App.Version)))
+			bundle.addCmd("kubectl-version.txt", exec.Command("kubectl", "version"))
+			configBytes, err := ioutil.ReadFile(configFile)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to read the config file [%s]: %v", configFile, err), 3)
+			}
+			bundle.add("qubernetes.yaml", redactConfig(configBytes))
+			if k8sdir != "" {
+				if err := addGeneratedConfig(bundle, k8sdir); err != nil {
+					red.Println(fmt.Sprintf("  unable to add the generated resources from [%s]: %v", k8sdir, err))
+				}
+			}
+
+			green.Println("  collecting pods and events")
+			bundle.addCmd("k8s/pods.txt", exec.Command("kubectl", "--namespace="+namespace, "get", "pods", "-o", "wide"))
+			bundle.addCmd("k8s/pvcs.txt", exec.Command("kubectl", "--namespace="+namespace, "get", "pvc"))
+			bundle.addCmd("k8s/services.txt", exec.Command("kubectl", "--namespace="+namespace, "get", "services"))
+			bundle.addCmd("k8s/events.txt", exec.Command("kubectl", "--namespace="+namespace, "get", "events", "--sort-by=.lastTimestamp"))
+
+			consensus := configFileYaml.Genesis.Consensus
+			for _, nodeName := range getNodeNames(configFileYaml) {
+				green.Println(fmt.Sprintf("  collecting [%s]", nodeName))
+				nodeDir := "nodes/" + nodeName + "/"
+				podName := podNameFromPrefix(nodeName, namespace)
+				if podName == "" {
+					bundle.add(nodeDir+"error.txt", []byte(fmt.Sprintf("no pod found for node [%s]\n", nodeName)))
+					continue
+				}
+				bundle.addCmd(nodeDir+"describe.txt", exec.Command("kubectl", "--namespace="+namespace, "describe", "pod", podName))
+				pod, _ := getPod(podName, namespace)
+				for _, container := range pod.Spec.Containers {
+					tailFlag := fmt.Sprintf("--tail=%d", tail)
+					bundle.addCmd(nodeDir+container.Name+".log", exec.Command("kubectl", "--namespace="+namespace, "logs", tailFlag, podName, container.Name))
+					// the logs of the previous container are the ones that explain a restart.
+					for _, containerStatus := range pod.Status.ContainerStatuses {
+						if containerStatus.Name == container.Name && containerStatus.RestartCount > 0 {
+							bundle.addCmd(nodeDir+container.Name+"-previous.log", exec.Command("kubectl", "--namespace="+namespace, "logs", "--previous", tailFlag, podName, container.Name))
+						}
+					}
+				}
+				bundle.addRpc(nodeDir+"node-info.json", podName, namespace, "admin_nodeInfo")
+				bundle.addRpc(nodeDir+"peers.json", podName, namespace, "admin_peers")
+				bundle.addRpc(nodeDir+"block-number.json", podName, namespace, "eth_blockNumber")
+				bundle.addRpc(nodeDir+"syncing.json", podName, namespace, "eth_syncing")
+				switch consensus {
+				case RaftConsensus:
+					bundle.addRpc(nodeDir+"raft-role.json", podName, namespace, "raft_role")
+					bundle.addRpc(nodeDir+"raft-leader.json", podName, namespace, "raft_leader")
+					bundle.addRpc(nodeDir+"raft-cluster.json", podName, namespace, "raft_cluster")
+				case IstanbulConsensus, QibftConsensus:
+					bundle.addRpc(nodeDir+"istanbul-validators.json", podName, namespace, "istanbul_getValidators")
+					bundle.addRpc(nodeDir+"istanbul-status.json", podName, namespace, "istanbul_status")
+					bundle.addRpc(nodeDir+"istanbul-node-address.json", podName, namespace, "istanbul_nodeAddress")
+				case "clique":
+					bundle.addRpc(nodeDir+"clique-signers.json", podName, namespace, "clique_getSigners")
+				}
+				if partyInfo, err := tmGet(podName, namespace, "/partyinfo"); err != nil {
+					bundle.add(nodeDir+"partyinfo.json", []byte(err.Error()+"\n"))
+				} else {
+					bundle.add(nodeDir+"partyinfo.json", partyInfo)
+				}
+				bundle.add(nodeDir+"upcheck.txt", []byte(fmt.Sprintf("%t\n", tmUpcheck(podName, namespace))))
+			}
+
+			if err := bundle.write(outputFile); err != nil {
+				return cli.Exit(fmt.Sprintf("unable to write the bundle [%s]: %v", outputFile, err), 3)
+			}
+			fmt.Println()
+			green.Println(fmt.Sprintf("  debug bundle written to [%s], %d files.", outputFile, len(bundle.order)))
+			fmt.Println("  the config has been redacted and keys excluded, please check the bundle before sharing it.")
+			fmt.Println()
+			return nil
+		},
+	}
+)
+
+// redactConfig replaces the values of the password / secret like keys in the yaml config, line by line so the
+// comments and layout of the config are kept.
+func redactConfig(configBytes []byte) []byte {
+	lines := strings.Split(string(configBytes), "\n")
+	for i, line := range lines {
+		lines[i] = secretConfigLine.ReplaceAllString(line, "${1}<redacted>")
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// addGeneratedConfig adds the generated K8s resources and config from the k8sdir, only the files needed to debug the
+// network are added so the keys are left out.
+func addGeneratedConfig(bundle *debugBundle, k8sdir string) error {
+	return filepath.Walk(k8sdir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(k8sdir, path)
+		if info.IsDir() || !isBundledFile(filepath.ToSlash(relPath)) {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		bundle.add("generated/"+filepath.ToSlash(relPath), content)
+		return nil
+	})
+}
+
+// the config files generated in out/config, the key dirs are not in the list.
+var bundledConfigFiles = map[string]bool{
+	"genesis.json":                   true,
+	"permissioned-nodes.json":        true,
+	"permission-config.json":         true,
+	"tessera-config.json":            true,
+	"tessera-config-enhanced.json":   true,
+	"tessera-config-9.0.json":        true,
+	"istanbul-validator-config.toml": true,
+	"prometheus.yml":                 true,
+	"alert-rules.yml":                true,
+	"alertmanager.yml":               true,
+	"cakeshop-nodes.json":            true,
+}
+
+// the public files of a key dir (out/config/key1/...), the keystores, nodekey and password are not in the list.
+var bundledKeyDirFiles = map[string]bool{
+	"enode":          true,
+	"nodekeyaddress": true,
+	"tm.pub":         true,
+}
+
+// isBundledFile returns true for the generated files (relative to the k8sdir) the bundle needs: the K8s resources
+// without the key configmaps, the network config and the public files of the key dirs.
+func isBundledFile(relPath string) bool {
+	parts := strings.Split(relPath, "/")
+	name := parts[len(parts)-1]
+	switch {
+	case len(parts) == 1, len(parts) == 2 && parts[0] == "deployments":
+		return strings.HasSuffix(name, ".yaml") && name != "04-quorum-keyconfigs.yaml"
+	case len(parts) == 2 && parts[0] == "config":
+		return bundledConfigFiles[name]
+	case len(parts) == 3 && parts[0] == "config":
+		return bundledKeyDirFiles[name]
+	}
+	return false
+}
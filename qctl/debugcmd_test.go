@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestIsBundledFile(t *testing.T) {
+	tests := map[string]bool{
+		"01-quorum-genesis.yaml":                          true,
+		"04-quorum-keyconfigs.yaml":                       false,
+		"deployments/quorum-node1-quorum-deployment.yaml": true,
+		"config/genesis.json":                             true,
+		"config/tessera-config-9.0.json":                  true,
+		"config/key1/enode":                               true,
+		"config/key1/tm.pub":                              true,
+		"config/key1/nodekey":                             false,
+		"config/key1/nodekeyacct.json":                    false,
+		"config/key1/acctkeyfile.json":                    false,
+		"config/key1/password.txt":                        false,
+		"config/key1/tm.key":                              false,
+		"config/key1/key1":                                false,
+		"config/unknown.json":                             false,
+	}
+	for relPath, expected := range tests {
+		if bundled := isBundledFile(relPath); bundled != expected {
+			t.Errorf("isBundledFile(%s) = %t, expected %t", relPath, bundled, expected)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// number of blocks fetched per JSON-RPC batch when scanning the chain.
+const chainScanBatchSize = 200
+
+// a single entry in the network timeline, from K8s, the pods or the chain.
+type timelineEvent struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"` // k8s | pod | chain
+	Node    string    `json:"node,omitempty"`
+	Type    string    `json:"type"`
+	Object  string    `json:"object,omitempty"`
+	Block   uint64    `json:"block,omitempty"`
+	Message string    `json:"message"`
+}
+
+var (
+	// qctl events --since=1h
+	// qctl events --since=30m --output=json > timeline.json
+	eventsCommand = cli.Command{
+		Name:  "events",
+		Usage: "timeline of the K8s events, container restarts and chain events (leader, validator changes, block gaps).",
+		Subcommands: []*cli.Command{
+			&contractEventsCommand,
+		},
+		Flags: []cli.Flag{
+			// not required, so the config flag can be given to the subcommands.
+			&cli.StringFlag{
+				Name:    "config, c",
+				Usage:   "Load configuration from `FULL_PATH_FILE`",
+				EnvVars: []string{"QUBE_CONFIG"},
+			},
+			&cli.DurationFlag{
+				Name:  "since",
+				Usage: "how far back to build the timeline from.",
+				Value: time.Hour,
+			},
+			&cli.DurationFlag{
+				Name:  "gap",
+				Usage: "report a block gap when no block was produced for longer than this.",
+				Value: 30 * time.Second,
+			},
+			&cli.StringFlag{
+				Name:  "output, o",
+				Usage: "output format: table | json",
+				Value: "table",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			output := c.String("output")
+			if output != "table" && output != "json" {
+				return cli.Exit(fmt.Sprintf("invalid output [%s], must be table or json", output), 2)
+			}
+			if configFile == "" {
+				return cli.Exit("--config flag must be set to the fullpath of your config file.", 2)
+			}
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			since := time.Now().Add(-c.Duration("since"))
+			nodeNames := getNodeNames(configFileYaml)
+
+			var timeline []timelineEvent
+			k8sEvents, err := k8sTimelineEvents(nodeNames, namespace, since)
+			if err != nil {
+				log.Errorf("unable to get the K8s events: %v", err)
+			}
+			timeline = append(timeline, k8sEvents...)
+			timeline = append(timeline, restartTimelineEvents(nodeNames, namespace, since)...)
+			chainEvents, err := chainTimelineEvents(nodeNames, namespace, configFileYaml.Genesis.Consensus, since, c.Duration("gap"))
+			if err != nil {
+				log.Errorf("unable to get the chain events: %v", err)
+			}
+			timeline = append(timeline, chainEvents...)
+			sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].Time.Before(timeline[j].Time) })
+
+			if output == "json" {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(timeline)
+			}
+			displayTimeline(timeline)
+			return nil
+		},
+	}
+)
+
+// k8sTimelineEvents returns the K8s events of the network's objects (pods, deployments, pvcs, ...) since the given time.
+func k8sTimelineEvents(nodeNames []string, namespace string, since time.Time) ([]timelineEvent, error) {
+	events, err := getEvents(namespace)
+	if err != nil {
+		return nil, err
+	}
+	var timeline []timelineEvent
+	for _, event := range events {
+		eventTime := parseK8sTime(event.LastTimestamp)
+		if eventTime.IsZero() {
+			eventTime = parseK8sTime(event.EventTime)
+		}
+		if eventTime.Before(since) {
+			continue
+		}
+		objectName := event.InvolvedObject.Name
+		nodeName := nodeForObject(objectName, nodeNames)
+		if nodeName == "" && !isNetworkObject(objectName) {
+			continue
+		}
+		message := event.Message
+		if event.Count > 1 {
+			message = fmt.Sprintf("%s (x%d)", message, event.Count)
+		}
+		timeline = append(timeline, timelineEvent{Time: eventTime, Source: "k8s", Node: nodeName, Type: event.Reason,
+			Object: strings.ToLower(event.InvolvedObject.Kind) + "/" + objectName, Message: message})
+	}
+	return timeline, nil
+}
+
+// restartTimelineEvents returns the last termination of every container that restarted since the given time, K8s only
+// keeps the last state so earlier restarts are only visible through the K8s events (BackOff, Killing, ...).
+func restartTimelineEvents(nodeNames []string, namespace string, since time.Time) []timelineEvent {
+	var timeline []timelineEvent
+	for _, nodeName := range nodeNames {
+		pod, err := getPod(podNameFromPrefix(nodeName, namespace), namespace)
+		if err != nil {
+			continue
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			terminated := containerStatus.LastState.Terminated
+			if terminated == nil {
+				continue
+			}
+			finishedAt := parseK8sTime(terminated.FinishedAt)
+			if finishedAt.Before(since) {
+				continue
+			}
+			timeline = append(timeline, timelineEvent{Time: finishedAt, Source: "pod", Node: nodeName, Type: "Restart",
+				Object: "pod/" + pod.Metadata.Name,
+				Message: fmt.Sprintf("container [%s] terminated: %s (exit code %d), restarts: %d", containerStatus.Name,
+					terminated.Reason, terminated.ExitCode, containerStatus.RestartCount)})
+		}
+	}
+	return timeline
+}
+
+// chainTimelineEvents scans the blocks produced since the given time for raft leader changes, validator set changes
+// and gaps in block production.
+func chainTimelineEvents(nodeNames []string, namespace, consensus string, since time.Time, gap time.Duration) ([]timelineEvent, error) {
+	// the node with the highest block is used to scan the chain, all nodes are used to attribute addresses to nodes.
+	var queryPod string
+	head := uint64(0)
+	addressNames := map[string]string{}
+	for _, nodeName := range nodeNames {
+		podName := podNameFromPrefix(nodeName, namespace)
+		height, err := nodeBlockNumber(podName, namespace)
+		if err != nil {
+			continue
+		}
+		if queryPod == "" || height > head {
+			queryPod, head = podName, height
+		}
+		var address string
+		if err := nodeRpcInto(podName, namespace, &address, "eth_coinbase"); err == nil {
+			addressNames[strings.ToLower(address)] = nodeName
+		}
+		if consensus == IstanbulConsensus || consensus == QibftConsensus {
+			if err := nodeRpcInto(podName, namespace, &address, "istanbul_nodeAddress"); err == nil {
+				addressNames[strings.ToLower(address)] = nodeName
+			}
+		}
+	}
+	if queryPod == "" {
+		return nil, fmt.Errorf("no running node found in the network")
+	}
+	blockTime := func(block rpcBlock) time.Time {
+		timestamp, _ := hexToUint64(block.Timestamp)
+		if consensus == RaftConsensus { // raft block timestamps are in nanoseconds.
+			return time.Unix(0, int64(timestamp))
+		}
+		return time.Unix(int64(timestamp), 0)
+	}
+	addressName := func(address string) string {
+		if name, found := addressNames[strings.ToLower(address)]; found {
+			return name
+		}
+		return address
+	}
+
+	// binary search for the first block after since.
+	low, high := uint64(0), head
+	for low < high {
+		mid := low + (high-low)/2
+		block, err := nodeBlockByNumber(queryPod, namespace, mid)
+		if err != nil {
+			return nil, err
+		}
+		if blockTime(block).Before(since) {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	// start from the block before, so the first block in the range can be compared against it.
+	start := low
+	if start > 0 {
+		start--
+	}
+
+	isIstanbul := consensus == IstanbulConsensus || consensus == QibftConsensus
+	var timeline []timelineEvent
+	var previous *rpcBlock
+	var previousValidators []string
+	for from := start; from <= head; from += chainScanBatchSize {
+		to := from + chainScanBatchSize - 1
+		if to > head {
+			to = head
+		}
+		var requests []rpcRequest
+		for n := from; n <= to; n++ {
+			requests = append(requests, rpcRequest{Method: "eth_getBlockByNumber", Params: []interface{}{uint64ToHex(n), false}})
+			if isIstanbul {
+				requests = append(requests, rpcRequest{Method: "istanbul_getValidators", Params: []interface{}{uint64ToHex(n)}})
+			}
+		}
+		responses, err := nodeRpcBatch(queryPod, namespace, requests)
+		if err != nil {
+			return timeline, err
+		}
+		// each block has one response, or two on istanbul networks (block, validators).
+		step := len(responses) / int(to-from+1)
+		for n := from; n <= to; n++ {
+			i := int(n-from) * step
+			var block rpcBlock
+			if responses[i].Error != nil || json.Unmarshal(responses[i].Result, &block) != nil {
+				return timeline, fmt.Errorf("unable to get block [%d] from pod [%s]", n, queryPod)
+			}
+			// a failed istanbul_getValidators (or a null result) skips the diff of the block, the next block is
+			// compared to the last validators that were returned.
+			var validators []string
+			validatorsOk := isIstanbul
+			if isIstanbul && (responses[i+1].Error != nil || json.Unmarshal(responses[i+1].Result, &validators) != nil ||
+				len(validators) == 0) {
+				validatorsOk = false
+			}
+			if t := blockTime(block); previous != nil && !t.Before(since) {
+				// raft only mints blocks when there are transactions, so gaps are expected.
+				if blockGap := t.Sub(blockTime(*previous)); consensus != RaftConsensus && blockGap > gap {
+					timeline = append(timeline, timelineEvent{Time: t, Source: "chain", Type: "BlockGap", Block: n,
+						Message: fmt.Sprintf("no blocks for %s before block %d", blockGap.Round(time.Second), n)})
+				}
+				// the raft minter (leader) sets itself as the coinbase of the blocks it mints.
+				if consensus == RaftConsensus && !strings.EqualFold(block.Miner, previous.Miner) {
+					timeline = append(timeline, timelineEvent{Time: t, Source: "chain", Node: addressName(block.Miner),
+						Type: "NewLeader", Block: n,
+						Message: fmt.Sprintf("raft leader changed from [%s] to [%s]", addressName(previous.Miner), addressName(block.Miner))})
+				}
+				if validatorsOk && previousValidators != nil {
+					added, removed := diffAddresses(previousValidators, validators)
+					for _, address := range added {
+						timeline = append(timeline, timelineEvent{Time: t, Source: "chain", Node: addressName(address),
+							Type: "ValidatorAdded", Block: n,
+							Message: fmt.Sprintf("validator [%s] added, %d validators", addressName(address), len(validators))})
+					}
+					for _, address := range removed {
+						timeline = append(timeline, timelineEvent{Time: t, Source: "chain", Node: addressName(address),
+							Type: "ValidatorRemoved", Block: n,
+							Message: fmt.Sprintf("validator [%s] removed, %d validators", addressName(address), len(validators))})
+					}
+				}
+			}
+			blockCopy := block
+			previous = &blockCopy
+			if validatorsOk {
+				previousValidators = validators
+			}
+		}
+	}
+	// block production that is stalled right now.
+	if previous != nil && consensus != RaftConsensus {
+		if stalled := time.Since(blockTime(*previous)); stalled > gap {
+			timeline = append(timeline, timelineEvent{Time: time.Now(), Source: "chain", Type: "BlockGap", Block: head,
+				Message: fmt.Sprintf("no blocks for %s since block %d", stalled.Round(time.Second), head)})
+		}
+	}
+	return timeline, nil
+}
+
+// diffAddresses returns the addresses added and removed between the two sets.
+func diffAddresses(before, after []string) ([]string, []string) {
+	inBefore := map[string]bool{}
+	for _, address := range before {
+		inBefore[strings.ToLower(address)] = true
+	}
+	inAfter := map[string]bool{}
+	var added []string
+	for _, address := range after {
+		inAfter[strings.ToLower(address)] = true
+		if !inBefore[strings.ToLower(address)] {
+			added = append(added, address)
+		}
+	}
+	var removed []string
+	for _, address := range before {
+		if !inAfter[strings.ToLower(address)] {
+			removed = append(removed, address)
+		}
+	}
+	return added, removed
+}
+
+// nodeForObject returns the node the K8s object belongs to, e.g. quorum-node1-deployment-7b6c4c8d8-tkxww -> quorum-node1,
+// the longest match is used so quorum-node10 is not attributed to quorum-node1.
+func nodeForObject(objectName string, nodeNames []string) string {
+	match := ""
+	for _, nodeName := range nodeNames {
+		if (objectName == nodeName || strings.HasPrefix(objectName, nodeName+"-")) && len(nodeName) > len(match) {
+			match = nodeName
+		}
+	}
+	return match
+}
+
+// the non node objects that are part of the network, see templates/k8s
+func isNetworkObject(objectName string) bool {
+	for _, prefix := range []string{"quorum-", "monitor-", "grafana-", "alertmanager-", "cakeshop"} {
+		if strings.HasPrefix(objectName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseK8sTime(timestamp string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func displayTimeline(timeline []timelineEvent) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  TIME\tSOURCE\tNODE\tEVENT\tMESSAGE")
+	for _, event := range timeline {
+		node := event.Node
+		if node == "" {
+			node = "-"
+		}
+		line := fmt.Sprintf("  %s\t%s\t%s\t%s\t%s", event.Time.Local().Format("2006-01-02 15:04:05"), event.Source,
+			node, event.Type, event.Message)
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+	fmt.Println()
+}
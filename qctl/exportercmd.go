@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the exporter collects the network metrics on an interval, and serves the last collection in the prometheus
+// text format, so scrapes are cheap and do not kubectl exec into every node.
+type networkExporter struct {
+	configFileYaml  QConfig
+	namespace       string
+	numBlocks       uint64
+	maxLag          uint64
+	mu              sync.Mutex
+	metrics         []byte
+	raftLeader      string
+	leaderChanges   int
+	lastCollection  time.Time
+	collectDuration time.Duration
+}
+
+var (
+	// qctl exporter --listen=:9700
+	exporterCommand = cli.Command{
+		Name:  "exporter",
+		Usage: "serve prometheus metrics for the health of the network (height lag, peers, consensus, tessera, restarts).",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "listen",
+				Usage: "the address to serve the /metrics on.",
+				Value: ":9700",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "how often to collect the metrics from the network.",
+				Value: 15 * time.Second,
+			},
+			&cli.Uint64Flag{
+				Name:  "blocks",
+				Usage: "number of recent blocks to check the validator liveness over (istanbul).",
+				Value: 100,
+			},
+			&cli.Uint64Flag{
+				Name:  "lag",
+				Usage: "number of blocks a validator can be behind the highest node before it is reported as not live.",
+				Value: 5,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			listen := c.String("listen")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			exporter := &networkExporter{configFileYaml: configFileYaml, namespace: namespace, numBlocks: c.Uint64("blocks"),
+				maxLag: c.Uint64("lag")}
+			exporter.collect()
+			go func() {
+				for range time.Tick(c.Duration("interval")) {
+					exporter.collect()
+				}
+			}()
+			http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+				exporter.mu.Lock()
+				defer exporter.mu.Unlock()
+				w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+				w.Write(exporter.metrics)
+			})
+			green.Println(fmt.Sprintf("  serving the network metrics on [%s/metrics]", listen))
+			return http.ListenAndServe(listen, nil)
+		},
+	}
+)
+
+// collect gets the status of the network and renders the metrics.
+func (e *networkExporter) collect() {
+	start := time.Now()
+	consensus := e.configFileYaml.Genesis.Consensus
+	statuses := getNodeStatuses(getNodeNames(e.configFileYaml), e.namespace, consensus)
+	// the same definition as the NodePeerLoss alert rule, see templates/monitor/alert-rules.yml.erb
+	expectedPeers := len(e.configFileYaml.Nodes) - 1
+
+	var metrics bytes.Buffer
+	maxHeight := uint64(0)
+	for _, s := range statuses {
+		if s.GethReachable && s.BlockNumber > maxHeight {
+			maxHeight = s.BlockNumber
+		}
+	}
+	writeMetricHeader(&metrics, "qctl_node_up", "gauge", "1 if the geth JSON-RPC of the node is reachable.")
+	for _, s := range statuses {
+		writeMetric(&metrics, "qctl_node_up", boolToFloat(s.GethReachable), "node", s.Name)
+	}
+	writeMetricHeader(&metrics, "qctl_node_block_height", "gauge", "the current block height of the node.")
+	for _, s := range statuses {
+		if s.GethReachable {
+			writeMetric(&metrics, "qctl_node_block_height", float64(s.BlockNumber), "node", s.Name)
+		}
+	}
+	writeMetricHeader(&metrics, "qctl_node_height_lag", "gauge", "number of blocks the node is behind the highest node.")
+	for _, s := range statuses {
+		if s.GethReachable {
+			writeMetric(&metrics, "qctl_node_height_lag", float64(maxHeight-s.BlockNumber), "node", s.Name)
+		}
+	}
+	writeMetricHeader(&metrics, "qctl_node_peers", "gauge", "number of peers connected to the node.")
+	for _, s := range statuses {
+		if s.GethReachable {
+			writeMetric(&metrics, "qctl_node_peers", float64(s.Peers), "node", s.Name)
+		}
+	}
+	writeMetricHeader(&metrics, "qctl_node_expected_peers", "gauge", "number of peers the node should be connected to (the other nodes in the config, external nodes are not counted).")
+	writeMetric(&metrics, "qctl_node_expected_peers", float64(expectedPeers))
+	writeMetricHeader(&metrics, "qctl_node_syncing", "gauge", "1 if the node is syncing.")
+	for _, s := range statuses {
+		if s.GethReachable {
+			writeMetric(&metrics, "qctl_node_syncing", boolToFloat(s.Syncing), "node", s.Name)
+		}
+	}
+	writeMetricHeader(&metrics, "qctl_tm_up", "gauge", "1 if the transaction manager upcheck succeeds.")
+	for _, s := range statuses {
+		writeMetric(&metrics, "qctl_tm_up", boolToFloat(s.TmUp), "node", s.Name)
+	}
+	writeMetricHeader(&metrics, "qctl_tm_peers", "gauge", "number of peers in the transaction manager partyinfo.")
+	for _, s := range statuses {
+		if s.TmUp {
+			writeMetric(&metrics, "qctl_tm_peers", float64(s.TmPeers), "node", s.Name)
+		}
+	}
+	writeMetricHeader(&metrics, "qctl_pod_ready", "gauge", "1 if all the containers of the node's pod are ready.")
+	for _, s := range statuses {
+		writeMetric(&metrics, "qctl_pod_ready", boolToFloat(s.PodReady), "node", s.Name)
+	}
+	// a gauge, the restarts start again from 0 when the pod is recreated.
+	writeMetricHeader(&metrics, "qctl_pod_restarts", "gauge", "container restarts of the node's current pod.")
+	for _, s := range statuses {
+		writeMetric(&metrics, "qctl_pod_restarts", float64(s.Restarts), "node", s.Name)
+	}
+
+	writeMetricHeader(&metrics, "qctl_node_pvc_used_ratio", "gauge", "fraction of the node's persistent volume in use.")
+	for _, s := range statuses {
+		if s.Pod == "" {
+			continue
+		}
+		used, capacity, err := nodeVolumeUsage(s.Pod, e.namespace)
+		if err == nil && capacity > 0 {
+			writeMetric(&metrics, "qctl_node_pvc_used_ratio", float64(used)/float64(capacity), "node", s.Name)
+		}
+	}
+
+	switch consensus {
+	case RaftConsensus:
+		e.collectRaft(&metrics, statuses)
+	case IstanbulConsensus, QibftConsensus:
+		e.collectValidators(&metrics)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastCollection = time.Now()
+	e.collectDuration = time.Since(start)
+	writeMetricHeader(&metrics, "qctl_exporter_collect_duration_seconds", "gauge", "time taken to collect the network metrics.")
+	writeMetric(&metrics, "qctl_exporter_collect_duration_seconds", e.collectDuration.Seconds())
+	writeMetricHeader(&metrics, "qctl_exporter_last_collect_timestamp_seconds", "gauge", "unix time of the last collection.")
+	writeMetric(&metrics, "qctl_exporter_last_collect_timestamp_seconds", float64(e.lastCollection.Unix()))
+	e.metrics = metrics.Bytes()
+}
+
+// collectRaft tracks the raft leader between collections, the leader changing often is a sign of an unhealthy cluster.
+func (e *networkExporter) collectRaft(metrics *bytes.Buffer, statuses []nodeStatus) {
+	writeMetricHeader(metrics, "qctl_raft_leader", "gauge", "1 if the node is the raft leader (minter).")
+	leader := ""
+	for _, s := range statuses {
+		if !s.GethReachable {
+			continue
+		}
+		isLeader := s.ConsensusRole == "minter"
+		if isLeader {
+			leader = s.Name
+		}
+		writeMetric(metrics, "qctl_raft_leader", boolToFloat(isLeader), "node", s.Name)
+	}
+	if leader != "" && e.raftLeader != "" && leader != e.raftLeader {
+		e.leaderChanges++
+	}
+	if leader != "" {
+		e.raftLeader = leader
+	}
+	writeMetricHeader(metrics, "qctl_raft_leader_changes_total", "counter", "number of raft leader changes observed by the exporter.")
+	writeMetric(metrics, "qctl_raft_leader_changes_total", float64(e.leaderChanges))
+	writeMetricHeader(metrics, "qctl_raft_has_leader", "gauge", "1 if a raft leader was found.")
+	writeMetric(metrics, "qctl_raft_has_leader", boolToFloat(leader != ""))
+}
+
+// collectValidators reports the validators that have proposed blocks in the recent range, see validatorscmd.go
+func (e *networkExporter) collectValidators(metrics *bytes.Buffer) {
+	validators, err := validatorsHealth(e.configFileYaml, e.namespace, e.numBlocks, e.maxLag)
+	if err != nil {
+		log.Errorf("unable to collect the validator metrics: %v", err)
+		return
+	}
+	writeMetricHeader(metrics, "qctl_validator_blocks_proposed", "gauge", "number of blocks the validator proposed in the recent range.")
+	for _, v := range validators {
+		writeMetric(metrics, "qctl_validator_blocks_proposed", float64(v.Proposed), "validator", v.Name, "address", v.Address)
+	}
+	writeMetricHeader(metrics, "qctl_validator_live", "gauge", "1 if the validator is healthy, running and proposing blocks.")
+	live := 0
+	for _, v := range validators {
+		if v.isHealthy() {
+			live++
+		}
+		writeMetric(metrics, "qctl_validator_live", boolToFloat(v.isHealthy()), "validator", v.Name, "address", v.Address)
+	}
+	writeMetricHeader(metrics, "qctl_validators", "gauge", "number of validators.")
+	writeMetric(metrics, "qctl_validators", float64(len(validators)))
+	writeMetricHeader(metrics, "qctl_validators_quorum_size", "gauge", "number of live validators needed to produce blocks (2F+1).")
+	writeMetric(metrics, "qctl_validators_quorum_size", float64(istanbulQuorumSize(len(validators))))
+}
+
+// nodeVolumeUsage returns the used and total bytes of the node's persistent volume, using df in the quorum container.
+func nodeVolumeUsage(podName, namespace string) (uint64, uint64, error) {
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "exec", podName, "-c", "quorum", "--",
+		"df", "-P", "-k", DefaultNodeDataDir)
+	out, err := runCmd(cmd)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Filesystem 1024-blocks Used Available Capacity Mounted on
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, 0, fmt.Errorf("unexpected df output [%s]", out.String())
+	}
+	capacity, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	used, err := strconv.ParseUint(fields[2], 10, 64)
+	return used * 1024, capacity * 1024, err
+}
+
+func writeMetricHeader(metrics *bytes.Buffer, name, metricType, help string) {
+	fmt.Fprintf(metrics, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// writeMetric writes a sample, labels are given as name, value pairs.
+func writeMetric(metrics *bytes.Buffer, name string, value float64, labels ...string) {
+	var labelPairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		labelPairs = append(labelPairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	sort.Strings(labelPairs)
+	if len(labelPairs) > 0 {
+		fmt.Fprintf(metrics, "%s{%s} %v\n", name, strings.Join(labelPairs, ","), value)
+	} else {
+		fmt.Fprintf(metrics, "%s %v\n", name, value)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -47,9 +47,14 @@ var (
 			namespace := c.String("namespace")
 			podName := podNameFromPrefix(nodeName, namespace)
 			log.Printf("executing geth command on pod [%v]", podName)
-			cmd := exec.Command("kubectl", "--namespace="+namespace, "exec", "-it", podName, "-c", "quorum", "--", "/geth-helpers/geth-exec.sh", gethCmd)
-			dropIntoCmd(cmd)
+			gethExec(podName, namespace, gethCmd)
 			return nil
 		},
 	}
 )
+
+// gethExec runs the geth command on the quorum container of the pod, attached to the terminal.
+func gethExec(podName, namespace, gethCmd string) error {
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "exec", "-it", podName, "-c", "quorum", "--", "/geth-helpers/geth-exec.sh", gethCmd)
+	return dropIntoCmd(cmd)
+}
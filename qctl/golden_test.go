@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+// go test -run TestGolden -update   rewrites the golden files from the current output.
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// newTestDir creates a working directory with a copy of the test config and the key dir of quorum-node2, the caller
+// removes it.
+func newTestDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "qctl-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := ioutil.ReadFile(filepath.Join("testdata", "qubernetes.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "qubernetes.yaml"), config, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "out", "config", "key2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// replay runs f with the commands answered from the fixture, failing the test if a command was not recorded or a
+// recorded interaction was not used. The output is returned with the test dir replaced by ${PWD}.
+func replay(t *testing.T, dir, fixture string, f func()) string {
+	t.Helper()
+	replayer, err := newReplayRunner(filepath.Join("testdata", "fixtures", fixture+".json"), "PWD", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing on the PATH, so no command can run for real and the commands display without their full path.
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir)
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout, color.Output, color.NoColor, runner = w, w, true, replayer
+	output := make(chan string)
+	go func() {
+		out, _ := ioutil.ReadAll(r)
+		output <- string(out)
+	}()
+	f()
+	w.Close()
+	os.Stdout, color.Output, runner = stdout, stdout, execRunner{}
+	os.Setenv("PATH", path)
+	out := <-output
+
+	for _, args := range replayer.unmatched {
+		t.Errorf("no recorded interaction for [%s]", strings.Join(args, " "))
+	}
+	for i, used := range replayer.used {
+		if !used {
+			t.Errorf("recorded interaction [%s] was not used", strings.Join(replayer.interactions[i].Args, " "))
+		}
+	}
+	return strings.ReplaceAll(out, dir, "${PWD}")
+}
+
+// runQctl runs the qctl command line with the replayed fixture.
+func runQctl(t *testing.T, dir, fixture string, args ...string) string {
+	t.Helper()
+	cli.OsExiter = func(int) {}
+	app := newApp()
+	resetSliceFlags(app.Commands)
+	return replay(t, dir, fixture, func() {
+		if err := app.Run(append([]string{"qctl"}, args...)); err != nil {
+			t.Errorf("qctl %s: %v", strings.Join(args, " "), err)
+		}
+	})
+}
+
+// the commands are package vars, and the slice flags keep the values parsed by the previous run.
+func resetSliceFlags(commands []*cli.Command) {
+	for _, command := range commands {
+		for _, flag := range command.Flags {
+			if sliceFlag, ok := flag.(*cli.StringSliceFlag); ok {
+				sliceFlag.Value = nil
+			}
+		}
+		resetSliceFlags(command.Subcommands)
+	}
+}
+
+func assertGolden(t *testing.T, name, actual string) {
+	t.Helper()
+	goldenFile := filepath.Join("testdata", "golden", name+".golden")
+	if *updateGolden {
+		if err := ioutil.WriteFile(goldenFile, []byte(actual), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to create it", err)
+	}
+	if string(expected) != actual {
+		t.Errorf("output does not match [%s]\n--- expected\n%s\n--- actual\n%s", goldenFile, expected, actual)
+	}
+}
+
+func readTestConfig(t *testing.T, dir string) string {
+	t.Helper()
+	config, err := ioutil.ReadFile(filepath.Join(dir, "qubernetes.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(config)
+}
+
+func TestGoldenUrlsNodePort(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	out := runQctl(t, dir, "ls-urls", "ls", "urls", "--config", dir+"/qubernetes.yaml", "--type=nodeport", "--node-ip=10.0.0.1")
+	assertGolden(t, "ls-urls-nodeport", out)
+}
+
+func TestGoldenUrlsClusterIp(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	out := runQctl(t, dir, "ls-urls", "ls", "urls", "--config", dir+"/qubernetes.yaml", "--type=clusterip")
+	assertGolden(t, "ls-urls-clusterip", out)
+}
+
+func TestGoldenUrlsBare(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	out := runQctl(t, dir, "ls-urls", "ls", "urls", "--config", dir+"/qubernetes.yaml", "--node=quorum-node2",
+		"--type=nodeport", "--tm", "--bare", "--node-ip=10.0.0.1")
+	assertGolden(t, "ls-urls-bare", out)
+}
+
+func TestGoldenNodeListAsExternal(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	out := runQctl(t, dir, "ls-node-asexternal", "ls", "node", "--config", dir+"/qubernetes.yaml", "--k8sdir", dir+"/out",
+		"--asexternal", "--bare", "--node-ip=10.0.0.1")
+	assertGolden(t, "ls-node-asexternal", out)
+}
+
+func TestGoldenAcceptanceConfig(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	configFileYaml, err := LoadYamlConfig(dir + "/qubernetes.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var acceptanceTestConfig AcceptTestConfig
+	replay(t, dir, "acceptance-config", func() {
+		acceptanceTestConfig, err = createAcceptanceTestConfig(configFileYaml, acceptanceTestOptions{UrlType: AcceptanceUrlNodePort,
+			NodeIp: "10.0.0.1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "acceptance-config", acceptanceTestConfig.ToString())
+}
+
+func TestGoldenAddNode(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	out := runQctl(t, dir, "add-node", "add", "node", "--config", dir+"/qubernetes.yaml", "--qversion=21.1.0", "quorum-node3")
+	assertGolden(t, "add-node", out)
+	assertGolden(t, "add-node-config", readTestConfig(t, dir))
+}
+
+func TestGoldenUpdateNode(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	out := runQctl(t, dir, "update-node", "update", "node", "--config", dir+"/qubernetes.yaml", "--tmversion=21.1.0",
+		"--gethparams=--verbosity 5", "quorum-node1")
+	assertGolden(t, "update-node", out)
+	assertGolden(t, "update-node-config", readTestConfig(t, dir))
+}
+
+func TestGoldenStopNode(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	out := runQctl(t, dir, "stop-node", "stop", "node", "--config", dir+"/qubernetes.yaml", "quorum-node2")
+	assertGolden(t, "stop-node", out)
+}
+
+func TestGoldenDeleteNode(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	out := runQctl(t, dir, "delete-node", "delete", "node", "--config", dir+"/qubernetes.yaml", "--k8sdir", dir+"/out",
+		"--hard", "quorum-node2")
+	assertGolden(t, "delete-node", out)
+	assertGolden(t, "delete-node-config", readTestConfig(t, dir))
+}
@@ -49,15 +49,20 @@ var (
 				showPods(namespace)
 				return cli.Exit(c.App.Command("logs").Usage, 2)
 			}
-			//  logs -f quorum-node1-deployment-7b6c4c8d8-tkxww quorum
-			var cmd *exec.Cmd
-			if follow {
-				cmd = exec.Command("kubectl", "--namespace="+namespace, "logs", "--follow", podName, container)
-			} else {
-				cmd = exec.Command("kubectl", "--namespace="+namespace, "logs", podName, container)
-			}
-			dropIntoCmd(cmd)
+			containerLogs(podName, namespace, container, follow)
 			return nil
 		},
 	}
 )
+
+// containerLogs shows the logs of the container of the pod, attached to the terminal.
+func containerLogs(podName, namespace, container string, follow bool) error {
+	//  logs -f quorum-node1-deployment-7b6c4c8d8-tkxww quorum
+	var cmd *exec.Cmd
+	if follow {
+		cmd = exec.Command("kubectl", "--namespace="+namespace, "logs", "--follow", podName, container)
+	} else {
+		cmd = exec.Command("kubectl", "--namespace="+namespace, "logs", podName, container)
+	}
+	return dropIntoCmd(cmd)
+}
@@ -22,9 +22,19 @@ var (
 				EnvVars:  []string{"QUBE_CONFIG"},
 				Required: true,
 			},
+			&cli.StringFlag{
+				Name:  "exporter",
+				Usage: "host:port of the qctl exporter (qctl exporter) to add as a prometheus scrape target.",
+			},
+			&cli.BoolFlag{
+				Name:  "grafana",
+				Usage: "deploy grafana with the prometheus datasource and dashboards for geth, consensus and tessera, the last two need the qctl exporter (prometheus.exporter).",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			configFile := c.String("config")
+			exporter := c.String("exporter")
+			isGrafana := c.Bool("grafana")
 
 			// get the current directory path, we'll use this in case the config file passed in was a relative path.
 			pwdCmd := exec.Command("pwd")
@@ -68,10 +78,21 @@ var (
 			}
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			if !configFileYaml.Prometheus.Enabled {
 				configFileYaml.Prometheus.Enabled = true
+				configFileYaml.Prometheus.Exporter = exporter
+				configFileYaml.Prometheus.Grafana = isGrafana
+			} else if (exporter != "" && exporter != configFileYaml.Prometheus.Exporter) || (isGrafana && !configFileYaml.Prometheus.Grafana) {
+				if exporter != "" {
+					configFileYaml.Prometheus.Exporter = exporter
+					green.Println(fmt.Sprintf(" adding the qctl exporter [%s] as a scrape target", exporter))
+				}
+				if isGrafana {
+					configFileYaml.Prometheus.Grafana = true
+					green.Println(" adding grafana to the monitoring")
+				}
 			} else {
 				green.Println(" monitoring is already set in the config")
 				return nil
@@ -110,9 +131,15 @@ var (
 				Usage:   "The k8sdir (usually out) containing the output k8s resources",
 				EnvVars: []string{"QUBE_K8S_DIR"},
 			},
+			&cli.BoolFlag{
+				Name:  "grafana",
+				Usage: "only delete grafana, keep the prometheus monitoring.",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			configFile := c.String("config")
+			namespace := c.String("namespace")
+			isGrafanaOnly := c.Bool("grafana")
 
 			k8sdir := c.String("k8sdir")
 			// get the current directory path, we'll use this in case the config file passed in was a relative path.
@@ -156,6 +183,29 @@ var (
 				return cli.Exit(fmt.Sprintf("ConfigFile must exist! Given configFile [%v]", configFile), 3)
 			}
 
+			// grafana resources are labeled name=quorum-grafana, see templates/k8s/monitor.yaml.erb
+			rmGrafana := exec.Command("kubectl", "--namespace="+namespace, "delete", "deployment,service,configmap", "-l", "name=quorum-grafana")
+			runCmd(rmGrafana)
+			if isGrafanaOnly {
+				configFileYaml, err := LoadYamlConfig(configFile)
+				if err != nil {
+					log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				}
+				configFileYaml.Prometheus.Grafana = false
+				configFileYaml.Prometheus.NodePortGrafana = ""
+				WriteYamlConfig(configFileYaml, configFile)
+				fmt.Println(fmt.Sprintf("grafana has been removed from the config file [%s]", configFile))
+				fmt.Println()
+				fmt.Println("Next, generate the monitoring resources without grafana:")
+				fmt.Println()
+				fmt.Println("**********************************************************************************************")
+				fmt.Println()
+				green.Println(fmt.Sprintf("  $> qctl generate network --update"))
+				fmt.Println()
+				fmt.Println("**********************************************************************************************")
+				return nil
+			}
+
 			rmDeployment := exec.Command("kubectl", "delete", "-f", k8sdir+"/06-quorum-monitor.yaml")
 			fmt.Println("rmDeployment", rmDeployment)
 			runCmd(rmDeployment)
@@ -165,7 +215,7 @@ var (
 
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			// remove from yaml
 			configFileYaml.Prometheus = Prometheus{}
@@ -56,7 +56,7 @@ var (
 				config := c.String("config")
 				configYaml, err := LoadYamlConfig(config)
 				if err != nil || config == "" {
-					log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", config, err)
+					log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", config, err)
 				}
 				configFileYaml = configYaml
 			}
@@ -92,7 +92,7 @@ var (
 			namespace := c.String("namespace")
 			configFileYaml, err := LoadYamlConfig(config)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", config, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", config, err)
 			}
 			waitForPodsReadyState(configFileYaml, namespace)
 			return nil
@@ -222,7 +222,7 @@ var (
 			}
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 
 			// if the quberentes version is set to latest, try to pull it from the remote, as it may have changed upstream.
@@ -308,7 +308,7 @@ func k8sCreateDeleteCluster(c *cli.Context, action string) error {
 	k8sdir := c.String("k8sdir")
 	// if the passed in k8s dir does not exit, tell the user and do not proceed.
 	if _, err := os.Stat(k8sdir); os.IsNotExist(err) {
-		log.Error("the --k8sdir [%v] does not exist!", k8sdir)
+		log.Errorf("the --k8sdir [%v] does not exist!", k8sdir)
 		return err
 	}
 	namespace := c.String("namespace")
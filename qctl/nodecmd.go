@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -108,7 +107,7 @@ var (
 			}
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			currentNum := len(configFileYaml.Nodes)
 			fmt.Printf("config currently has %d nodes \n", currentNum)
@@ -251,7 +250,7 @@ var (
 			}
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			currentNum := len(configFileYaml.ExternalNodes)
 			fmt.Printf("config currently has %d external nodes \n", currentNum)
@@ -354,7 +353,7 @@ var (
 			}
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			currentNum := len(configFileYaml.Nodes)
 			fmt.Printf("config currently has %d nodes \n", currentNum)
@@ -506,7 +505,7 @@ var (
 				}
 			}
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			// set defaults from the existing config if node values were not provided
 			if quorumVersion == "" {
@@ -532,7 +531,7 @@ var (
 			displayNode("", nodeEntry, true, true, true, true, true, true, false, true, true, true)
 			// write file back
 			WriteYamlConfig(configFileYaml, configFile)
-			fmt.Println("The node(s) have been added to the config file [%s]", configFile)
+			fmt.Println(fmt.Sprintf("The node(s) have been added to the config file [%s]", configFile))
 			fmt.Println("Next, generate (update) the additional node resources for quorum and k8s:")
 			fmt.Println()
 			fmt.Println("**********************************************************************************************")
@@ -641,7 +640,7 @@ var (
 				}
 			}
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 
 			fmt.Println(fmt.Sprintf("Adding external node [%s] ", name))
@@ -661,7 +660,7 @@ var (
 			displayExternalNode(externalNodeEntry, true, true, true, true)
 			// write file back
 			WriteYamlConfig(configFileYaml, configFile)
-			fmt.Println("The external node(s) have been added to the config file [%s]", configFile)
+			fmt.Println(fmt.Sprintf("The external node(s) have been added to the config file [%s]", configFile))
 			fmt.Println("Next, generate (update) the additional node resources for quorum and k8s:")
 			fmt.Println()
 			fmt.Println("**********************************************************************************************")
@@ -786,7 +785,7 @@ var (
 			}
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			// find the nodes
 			var updatedNode NodeEntry
@@ -835,7 +834,7 @@ var (
 			displayNode("", updatedNode, true, true, true, true, true, true, false, true, true, true)
 			// write file back
 			WriteYamlConfig(configFileYaml, configFile)
-			fmt.Println("The node have been updated the config file [%s]", configFile)
+			fmt.Println(fmt.Sprintf("The node have been updated the config file [%s]", configFile))
 			fmt.Println("Next, generate (update) the additional node resources for quorum and k8s:")
 			fmt.Println()
 			fmt.Println("**********************************************************************************************")
@@ -1022,7 +1021,7 @@ var (
 
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			currentNum := len(configFileYaml.Nodes)
 			if !isBare {
@@ -1206,7 +1205,7 @@ var (
 
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 			currentNum := len(configFileYaml.Nodes)
 			if !isBare {
@@ -1267,20 +1266,8 @@ func createNodeEntry(nodeName, nodeKeyDir, consensus, quorumVersion, txManager,
 // cat $QUBE_K8S_DIR/config/permissioned-nodes.json | grep quorum-node1
 func getEnodeUrl(nodeName, qubeK8sDir string) string {
 	c1 := exec.Command("cat", qubeK8sDir+"/config/permissioned-nodes.json")
-	c2 := exec.Command("grep", nodeName)
-
-	r, w := io.Pipe()
-	c1.Stdout = w
-	c2.Stdin = r
-
-	var out bytes.Buffer
-	c2.Stdout = &out
-	c1.Start()
-	c2.Start()
-	c1.Wait()
-	w.Close()
-	c2.Wait()
-	enodeUrl := strings.TrimSpace(out.String())
+	out, _ := runCmd(c1)
+	enodeUrl := strings.TrimSpace(grepLines(out.String(), nodeName))
 	enodeUrl = strings.ReplaceAll(enodeUrl, ",", "")
 	return enodeUrl
 }
@@ -1427,7 +1414,7 @@ func stopNode(nodeName string) error {
 	// TODO: run should return the error so we can handle it or ignore it.
 	var out bytes.Buffer
 	rmRunningDeployment.Stdout = &out
-	err := rmRunningDeployment.Run()
+	err := runner.Run(rmRunningDeployment)
 	if err != nil { // log the error but don't throw any
 		log.Info("deployment not found in k8s, ignoring.")
 	}
@@ -1441,7 +1428,7 @@ func rmPersistentData(nodeName string) error {
 	fmt.Println(rmPVC)
 	var out bytes.Buffer
 	rmPVC.Stdout = &out
-	err := rmPVC.Run()
+	err := runner.Run(rmPVC)
 	if err != nil { // log the error but don't throw any
 		log.Info("PVC / Persistent data not found in k8s, ignoring.")
 	}
@@ -1454,33 +1441,20 @@ func rmService(nodeName string) error {
 	fmt.Println(rmService)
 	var out bytes.Buffer
 	rmService.Stdout = &out
-	err := rmService.Run()
+	err := runner.Run(rmService)
 	if err != nil { // log the error but don't throw any
 		log.Info("service not found in k8s, ignoring.")
 	}
 	return err
 }
 
-func getTmPublicKey(nodeName string) string {
-	//c1 := exec.Command("cat", qubeK8sDir+"/config/" + nodeKeyDir + "tm.pub")
-	//kc get configMaps quorum-node3-tm-key-config -o yaml | grep "tm.pub:"
-	c1 := exec.Command("kubectl", "get", "configMap", nodeName+"-tm-key-config", "-o", "yaml")
-	c2 := exec.Command("grep", "tm.pub:")
-
-	r, w := io.Pipe()
-	c1.Stdout = w
-	c2.Stdin = r
-
-	var out bytes.Buffer
-	c2.Stdout = &out
-	c1.Start()
-	c2.Start()
-	c1.Wait()
-	w.Close()
-	c2.Wait()
-	// output will look like:
-	// tm.pub: dF+Y81qRKI3Noh6ldI+FnQmqmjRYvOqLCaooTi5txi4=
-	tmPublicKey := strings.ReplaceAll(out.String(), "tm.pub:", "")
-	tmPublicKey = strings.TrimSpace(tmPublicKey)
-	return tmPublicKey
+// getTmPublicKey returns the node's tessera public key from the deployed tm key configmap.
+func getTmPublicKey(nodeName, namespace string) (string, error) {
+	c1 := exec.Command("kubectl", "--namespace="+namespace, "get", "configmap", nodeName+"-tm-key-config",
+		"-o=jsonpath={.data.tm\\.pub}")
+	out, err := runCmd(c1)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
 }
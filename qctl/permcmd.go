@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// GoQuorum smart contract permissioning.
+// see: https://docs.goquorum.consensys.net/en/stable/Concepts/Permissioning/Enhanced/EnhancedPermissionsOverview/
+
+var (
+	DefaultNwAdminOrg    = "ADMINORG"
+	DefaultNwAdminRole   = "ADMIN"
+	DefaultOrgAdminRole  = "ORGADMIN"
+	DefaultSubOrgBreadth = 4
+	DefaultSubOrgDepth   = 4
+
+	// the permission contracts deployed to bootstrap permissioning, the file names of the compiled contracts are
+	// expected to match the contract names, e.g. PermissionsUpgradable.bin or PermissionsUpgradable.json
+	permissionContractNames = []string{"PermissionsUpgradable", "AccountManager", "NodeManager", "OrgManager",
+		"RoleManager", "VoterManager", "PermissionsInterface", "PermissionsImplementation"}
+
+	permNodeFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:     "node",
+			Usage:    "the node to send the permissioning request from, its account must have the required role.",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "the account to send the request from, defaults to the node's unlocked account.",
+		},
+	}
+
+	// qctl generate permissioning --contracts=/path/to/compiled/permission/contracts
+	generatePermissioningCommand = cli.Command{
+		Name:  "permissioning",
+		Usage: "deploy the smart contract permissioning contracts to the running network and add them to the config.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "contracts",
+				Usage:    "dir containing the compiled GoQuorum permission contracts (NAME.bin or NAME.json).",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "node",
+				Usage: "the node to deploy the contracts from, defaults to the first node in the config.",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			contractsDir := c.String("contracts")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			if configFileYaml.Permissioning.Contracts.Upgradable != "" {
+				red.Println(fmt.Sprintf("  The permission contracts have already been deployed, upgradable contract [%s]", configFileYaml.Permissioning.Contracts.Upgradable))
+				return cli.Exit("permission contracts already deployed", 3)
+			}
+			nodeName := c.String("node")
+			if nodeName == "" {
+				nodeName = configFileYaml.Nodes[0].NodeUserIdent
+			}
+			artifacts := map[string]contractArtifact{}
+			for _, contractName := range permissionContractNames {
+				artifact, err := loadPermissionContract(contractsDir, contractName)
+				if err != nil {
+					return cli.Exit(err.Error(), 3)
+				}
+				artifacts[contractName] = artifact
+			}
+			podName := podNameFromPrefix(nodeName, namespace)
+			from, err := nodeAccount(podName, namespace)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to get the account of node [%s]: %v", nodeName, err), 3)
+			}
+			permissioning := configFileYaml.Permissioning
+			setPermissioningDefaults(&permissioning, from)
+
+			green.Println(fmt.Sprintf("  Deploying the permission contracts from node [%s] account [%s]", nodeName, from))
+			fmt.Println()
+			deploy := func(contractName string, constructorArgs ...string) (string, error) {
+				bytecode := artifacts[contractName].Bytecode
+				for _, arg := range constructorArgs {
+					bytecode += abiEncodeAddress(arg)
+				}
+				receipt, err := deployContract(podName, namespace, from, bytecode, nil)
+				if err != nil {
+					return "", fmt.Errorf("deploying [%s] failed: %v", contractName, err)
+				}
+				fmt.Println(fmt.Sprintf("  %-27s %s", contractName, receipt.ContractAddress))
+				return receipt.ContractAddress, nil
+			}
+			contracts := &permissioning.Contracts
+			if contracts.Upgradable, err = deploy("PermissionsUpgradable", from); err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if contracts.AccountMgr, err = deploy("AccountManager", contracts.Upgradable); err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if contracts.NodeMgr, err = deploy("NodeManager", contracts.Upgradable); err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if contracts.OrgMgr, err = deploy("OrgManager", contracts.Upgradable); err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if contracts.RoleMgr, err = deploy("RoleManager", contracts.Upgradable); err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if contracts.VoterMgr, err = deploy("VoterManager", contracts.Upgradable); err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if contracts.Interface, err = deploy("PermissionsInterface", contracts.Upgradable); err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if contracts.Impl, err = deploy("PermissionsImplementation", contracts.Upgradable, contracts.OrgMgr,
+				contracts.RoleMgr, contracts.AccountMgr, contracts.VoterMgr, contracts.NodeMgr); err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			// link the interface and implementation through the upgradable contract.
+			selector, err := functionSelector(podName, namespace, "init(address,address)")
+			if err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			initTxHash, err := sendTransaction(podName, namespace, rpcTx{From: from, To: contracts.Upgradable,
+				Data: selector + abiEncodeAddress(contracts.Interface) + abiEncodeAddress(contracts.Impl)})
+			if err == nil {
+				_, err = waitForReceipt(podName, namespace, initTxHash, DefaultReceiptTimeout)
+			}
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("initializing the upgradable contract failed: %v", err), 3)
+			}
+			configFileYaml.Permissioning = permissioning
+			WriteYamlConfig(configFileYaml, configFile)
+
+			fmt.Println()
+			green.Println("  The permission contracts have been deployed and added to the config.")
+			fmt.Println("  Next, generate the permission-config.json and redeploy the nodes with smart contract permissioning:")
+			fmt.Println()
+			fmt.Println("**********************************************************************************************")
+			fmt.Println()
+			green.Println("  $> qctl generate network --update")
+			green.Println("  $> qctl deploy network --wait")
+			fmt.Println()
+			fmt.Println("**********************************************************************************************")
+			return nil
+		},
+	}
+
+	// qctl perm org ls --node quorum-node1
+	permOrgListCommand = cli.Command{
+		Name:    "ls",
+		Aliases: []string{"list"},
+		Usage:   "list the organizations.",
+		Flags:   permNodeFlags,
+		Action: func(c *cli.Context) error {
+			return permRequest(c, "quorumPermission_orgList")
+		},
+	}
+	// qctl perm org add PARTNER "enode://...@1.2.3.4:30303?discport=0" 0xabc... --node quorum-node1
+	permOrgAddCommand = cli.Command{
+		Name:      "add",
+		Usage:     "propose a new organization, with its first node and admin account.",
+		ArgsUsage: "[orgId] [enode url] [admin account]",
+		Flags:     permNodeFlags,
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 3 {
+				return cli.Exit("wrong number of arguments, orgId, enode url and admin account are required.", 2)
+			}
+			return permRequest(c, "quorumPermission_addOrg", c.Args().Get(0), c.Args().Get(1), c.Args().Get(2))
+		},
+	}
+	// qctl perm org approve PARTNER "enode://...@1.2.3.4:30303?discport=0" 0xabc... --node quorum-node2
+	permOrgApproveCommand = cli.Command{
+		Name:      "approve",
+		Usage:     "approve a proposed organization (network admin).",
+		ArgsUsage: "[orgId] [enode url] [admin account]",
+		Flags:     permNodeFlags,
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 3 {
+				return cli.Exit("wrong number of arguments, orgId, enode url and admin account are required.", 2)
+			}
+			return permRequest(c, "quorumPermission_approveOrg", c.Args().Get(0), c.Args().Get(1), c.Args().Get(2))
+		},
+	}
+	// qctl perm org status PARTNER --action=suspend --node quorum-node1
+	// qctl perm org status PARTNER --action=suspend --approve --node quorum-node2
+	permOrgStatusCommand = cli.Command{
+		Name:      "status",
+		Usage:     "suspend or activate an organization, or approve the status change.",
+		ArgsUsage: "[orgId]",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:     "action",
+				Usage:    "suspend | activate",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "approve",
+				Usage: "approve the status change (network admin).",
+			},
+		}, permNodeFlags...),
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 1 {
+				return cli.Exit("wrong number of arguments, orgId is required.", 2)
+			}
+			action, err := permAction(c.String("action"), map[string]int{"suspend": 1, "activate": 2})
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			method := "quorumPermission_updateOrgStatus"
+			if c.Bool("approve") {
+				method = "quorumPermission_approveOrgStatus"
+			}
+			return permRequest(c, method, c.Args().First(), action)
+		},
+	}
+
+	// qctl perm node ls --node quorum-node1
+	permNodeListCommand = cli.Command{
+		Name:    "ls",
+		Aliases: []string{"list"},
+		Usage:   "list the permissioned nodes.",
+		Flags:   permNodeFlags,
+		Action: func(c *cli.Context) error {
+			return permRequest(c, "quorumPermission_nodeList")
+		},
+	}
+	// qctl perm node add PARTNER "enode://...@1.2.3.4:30303?discport=0" --node partner-node1
+	permNodeAddCommand = cli.Command{
+		Name:      "add",
+		Usage:     "add a node to an organization (org admin).",
+		ArgsUsage: "[orgId] [enode url]",
+		Flags:     permNodeFlags,
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 2 {
+				return cli.Exit("wrong number of arguments, orgId and enode url are required.", 2)
+			}
+			return permRequest(c, "quorumPermission_addNode", c.Args().Get(0), c.Args().Get(1))
+		},
+	}
+	// qctl perm node status PARTNER "enode://..." --action=deactivate --node partner-node1
+	permNodeStatusCommand = cli.Command{
+		Name:      "status",
+		Usage:     "deactivate, activate or blacklist a node (org admin).",
+		ArgsUsage: "[orgId] [enode url]",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:     "action",
+				Usage:    "deactivate | activate | blacklist",
+				Required: true,
+			},
+		}, permNodeFlags...),
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 2 {
+				return cli.Exit("wrong number of arguments, orgId and enode url are required.", 2)
+			}
+			action, err := permAction(c.String("action"), map[string]int{"deactivate": 1, "activate": 2, "blacklist": 3})
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			return permRequest(c, "quorumPermission_updateNodeStatus", c.Args().Get(0), c.Args().Get(1), action)
+		},
+	}
+
+	// qctl perm account ls --node quorum-node1
+	permAccountListCommand = cli.Command{
+		Name:    "ls",
+		Aliases: []string{"list"},
+		Usage:   "list the permissioned accounts.",
+		Flags:   permNodeFlags,
+		Action: func(c *cli.Context) error {
+			return permRequest(c, "quorumPermission_acctList")
+		},
+	}
+	// qctl perm account assign 0xabc... PARTNER ORGADMIN --node partner-node1
+	permAccountAssignCommand = cli.Command{
+		Name:      "assign",
+		Usage:     "assign a role to an account (org admin).",
+		ArgsUsage: "[account] [orgId] [roleId]",
+		Flags:     permNodeFlags,
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 3 {
+				return cli.Exit("wrong number of arguments, account, orgId and roleId are required.", 2)
+			}
+			return permRequest(c, "quorumPermission_assignAccountRole", c.Args().Get(0), c.Args().Get(1), c.Args().Get(2))
+		},
+	}
+	// qctl perm account status PARTNER 0xabc... --action=suspend --node partner-node1
+	permAccountStatusCommand = cli.Command{
+		Name:      "status",
+		Usage:     "suspend, activate or blacklist an account (org admin).",
+		ArgsUsage: "[orgId] [account]",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:     "action",
+				Usage:    "suspend | activate | blacklist",
+				Required: true,
+			},
+		}, permNodeFlags...),
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 2 {
+				return cli.Exit("wrong number of arguments, orgId and account are required.", 2)
+			}
+			action, err := permAction(c.String("action"), map[string]int{"suspend": 1, "activate": 2, "blacklist": 3})
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			return permRequest(c, "quorumPermission_updateAccountStatus", c.Args().Get(0), c.Args().Get(1), action)
+		},
+	}
+)
+
+func setPermissioningDefaults(permissioning *Permissioning, adminAccount string) {
+	permissioning.Enabled = true
+	if permissioning.NwAdminOrg == "" {
+		permissioning.NwAdminOrg = DefaultNwAdminOrg
+	}
+	if permissioning.NwAdminRole == "" {
+		permissioning.NwAdminRole = DefaultNwAdminRole
+	}
+	if permissioning.OrgAdminRole == "" {
+		permissioning.OrgAdminRole = DefaultOrgAdminRole
+	}
+	if permissioning.SubOrgBreadth == 0 {
+		permissioning.SubOrgBreadth = DefaultSubOrgBreadth
+	}
+	if permissioning.SubOrgDepth == 0 {
+		permissioning.SubOrgDepth = DefaultSubOrgDepth
+	}
+	if len(permissioning.Accounts) == 0 && adminAccount != "" {
+		permissioning.Accounts = []string{adminAccount}
+	}
+}
+
+func loadPermissionContract(contractsDir, contractName string) (contractArtifact, error) {
+	for _, ext := range []string{".json", ".bin"} {
+		artifactFile := filepath.Join(contractsDir, contractName+ext)
+		if fileExists(artifactFile) {
+			return loadContractArtifact(artifactFile)
+		}
+	}
+	return contractArtifact{}, fmt.Errorf("permission contract [%s] not found in [%s], expected %s.bin or %s.json",
+		contractName, contractsDir, contractName, contractName)
+}
+
+func permAction(action string, actions map[string]int) (int, error) {
+	actionNum, found := actions[strings.ToLower(action)]
+	if !found {
+		var valid []string
+		for name := range actions {
+			valid = append(valid, name)
+		}
+		return 0, fmt.Errorf("invalid action [%s], valid actions: %s", action, strings.Join(valid, " | "))
+	}
+	return actionNum, nil
+}
+
+// permRequest sends the quorumPermission API request to the node, the update requests take the sending account
+// as the last param, list requests do not.
+func permRequest(c *cli.Context, method string, params ...interface{}) error {
+	namespace := c.String("namespace")
+	nodeName := c.String("node")
+	podName := podNameFromPrefix(nodeName, namespace)
+	if podName == "" {
+		return cli.Exit(fmt.Sprintf("no pod found for node [%s]", nodeName), 3)
+	}
+	if !strings.HasSuffix(method, "List") {
+		from := c.String("from")
+		if from == "" {
+			account, err := nodeAccount(podName, namespace)
+			if err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			from = account
+		}
+		params = append(params, map[string]string{"from": from})
+	}
+	result, err := nodeRpc(podName, namespace, method, params...)
+	if err != nil {
+		red.Println(fmt.Sprintf("  %v", err))
+		return cli.Exit(fmt.Sprintf("%s failed", method), 3)
+	}
+	var out interface{}
+	json.Unmarshal(result, &out)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the configmap (and key) holding the permissioned-nodes.json shared by all nodes, see templates/k8s/quorum-shared-config.yaml.erb
+const (
+	PermissionedNodesConfigMap = "quorum-permissioned-config"
+	PermissionedNodesKey       = "permissioned-nodes.json"
+)
+
+var (
+	permissionsFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:     "config, c",
+			Usage:    "Load configuration from `FULL_PATH_FILE`",
+			EnvVars:  []string{"QUBE_CONFIG"},
+			Required: true,
+		},
+		&cli.StringFlag{ // used to keep the generated resources in sync with the deployed configmap.
+			Name:    "k8sdir",
+			Usage:   "The k8sdir (usually out) containing the output k8s resources",
+			EnvVars: []string{"QUBE_K8S_DIR"},
+		},
+	}
+	permissionsUpdateFlags = append(permissionsFlags,
+		&cli.BoolFlag{
+			Name:  "restart",
+			Usage: "roll the node deployments instead of hot reloading the peers via the admin API.",
+		},
+	)
+
+	// qctl permissions ls
+	permissionsListCommand = cli.Command{
+		Name:    "ls",
+		Aliases: []string{"list"},
+		Usage:   "list the enodes in the deployed permissioned-nodes.json.",
+		Flags:   permissionsFlags,
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			enodes, err := getPermissionedNodes(namespace)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to get the permissioned nodes: %v", err), 3)
+			}
+			fmt.Println()
+			for _, enode := range enodes {
+				green.Println(fmt.Sprintf("  [%s]", enodeName(enode, configFileYaml)))
+				fmt.Println("    " + enode)
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+
+	// qctl permissions add quorum-node5
+	// qctl permissions add my-external-node
+	// qctl permissions add "enode://abc...@1.2.3.4:30303?discport=0"
+	permissionsAddCommand = cli.Command{
+		Name:      "add",
+		Usage:     "add a node or enode to the deployed permissioned-nodes.json.",
+		ArgsUsage: "[node name | external node name | enode url]",
+		Flags:     permissionsUpdateFlags,
+		Action: func(c *cli.Context) error {
+			return updatePermissionedNodes(c, true)
+		},
+	}
+
+	// qctl permissions remove quorum-node5
+	permissionsRemoveCommand = cli.Command{
+		Name:      "remove",
+		Aliases:   []string{"rm"},
+		Usage:     "remove a node or enode from the deployed permissioned-nodes.json.",
+		ArgsUsage: "[node name | external node name | enode url]",
+		Flags:     permissionsUpdateFlags,
+		Action: func(c *cli.Context) error {
+			return updatePermissionedNodes(c, false)
+		},
+	}
+)
+
+func updatePermissionedNodes(c *cli.Context, isAdd bool) error {
+	if c.Args().Len() < 1 {
+		return cli.Exit("wrong number of arguments, a node name or enode url is required.", 2)
+	}
+	target := c.Args().First()
+	namespace := c.String("namespace")
+	configFile := c.String("config")
+	k8sdir := c.String("k8sdir")
+	isRestart := c.Bool("restart")
+	configFileYaml, err := LoadYamlConfig(configFile)
+	if err != nil {
+		log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+	}
+	enode, err := resolveEnode(target, configFileYaml, k8sdir)
+	if err != nil {
+		return cli.Exit(err.Error(), 3)
+	}
+	enodes, err := getPermissionedNodes(namespace)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("unable to get the permissioned nodes: %v", err), 3)
+	}
+	var updated []string
+	found := false
+	for _, permissioned := range enodes {
+		if enodePubKey(permissioned) == enodePubKey(enode) {
+			found = true
+			if isAdd { // already permissioned, keep the existing entry
+				updated = append(updated, permissioned)
+			}
+			continue
+		}
+		updated = append(updated, permissioned)
+	}
+	if isAdd && found {
+		green.Println(fmt.Sprintf("  [%s] is already permissioned.", target))
+		return nil
+	}
+	if !isAdd && !found {
+		red.Println(fmt.Sprintf("  [%s] is not in the permissioned nodes.", target))
+		return nil
+	}
+	if isAdd {
+		updated = append(updated, enode)
+	}
+	if err := setPermissionedNodes(updated, namespace, k8sdir); err != nil {
+		return cli.Exit(fmt.Sprintf("unable to update the permissioned nodes: %v", err), 3)
+	}
+	if isAdd {
+		green.Println(fmt.Sprintf("  Added [%s] to the permissioned nodes.", target))
+	} else {
+		green.Println(fmt.Sprintf("  Removed [%s] from the permissioned nodes.", target))
+	}
+	fmt.Println()
+
+	if isRestart {
+		for _, nodeName := range getNodeNames(configFileYaml) {
+			if err := rolloutRestart(nodeName, namespace); err != nil {
+				red.Println(fmt.Sprintf("  unable to restart node [%s]: %v", nodeName, err))
+			}
+		}
+		return nil
+	}
+	// quorum checks the permissioned-nodes.json on every connection, so only the current peers need to be updated,
+	// the configmap volume will be synced by the kubelet (this can take up to a minute).
+	peerMethod := "admin_removePeer"
+	if isAdd {
+		peerMethod = "admin_addPeer"
+	}
+	for _, nodeName := range getNodeNames(configFileYaml) {
+		podName := podNameFromPrefix(nodeName, namespace)
+		if _, err := nodeRpc(podName, namespace, peerMethod, enode); err != nil {
+			red.Println(fmt.Sprintf("  unable to update the peers of node [%s]: %v", nodeName, err))
+		}
+	}
+	green.Println("  The peers have been updated on the running nodes, the permissioned-nodes.json will be synced")
+	green.Println("  to the pods by K8s shortly.")
+	if isAdd && configFileYaml.Genesis.Consensus == RaftConsensus {
+		fmt.Println()
+		green.Println("  This is a raft network, to add the node to the raft cluster run on a healthy node:")
+		fmt.Println()
+		green.Println(fmt.Sprintf("    > qctl geth exec %s 'raft.addPeer(\"%s\")'", configFileYaml.Nodes[0].NodeUserIdent, enode))
+	}
+	fmt.Println()
+	return nil
+}
+
+// resolveEnode returns the enode url for a node in the config, an external node in the config, or the enode url itself.
+func resolveEnode(target string, configFileYaml QConfig, k8sdir string) (string, error) {
+	if strings.HasPrefix(target, "enode://") {
+		return target, nil
+	}
+	for _, externalNode := range configFileYaml.ExternalNodes {
+		if externalNode.NodeUserIdent == target {
+			return externalNode.EnodeUrl, nil
+		}
+	}
+	for _, node := range configFileYaml.Nodes {
+		if node.NodeUserIdent == target {
+			if k8sdir == "" {
+				return "", fmt.Errorf("set --k8sdir flag or QUBE_K8S_DIR env in order to get the enode of node [%s]", target)
+			}
+			enodeBytes, err := ioutil.ReadFile(k8sdir + "/config/" + node.KeyDir + "/enode")
+			if err != nil {
+				return "", fmt.Errorf("unable to read the enode for node [%s]: %v", target, err)
+			}
+			// same format as templates/quorum/permissioned-nodes.json.erb
+			return fmt.Sprintf("enode://%s@%s:%s?discport=0&raftport=%s",
+				strings.TrimSpace(string(enodeBytes)), node.NodeUserIdent, DefaultP2PPort, DefaultRaftPort), nil
+		}
+	}
+	return "", fmt.Errorf("[%s] is not a node, an external node, or an enode url", target)
+}
+
+// enodePubKey returns the node id part of the enode url, e.g. enode://PUBKEY@host:port?params
+func enodePubKey(enode string) string {
+	pubKey := strings.TrimPrefix(enode, "enode://")
+	return strings.Split(pubKey, "@")[0]
+}
+
+// enodeName finds the name of the node (or external node) in the config for the given enode url.
+func enodeName(enode string, configFileYaml QConfig) string {
+	for _, externalNode := range configFileYaml.ExternalNodes {
+		if enodePubKey(externalNode.EnodeUrl) == enodePubKey(enode) {
+			return externalNode.NodeUserIdent
+		}
+	}
+	for _, node := range configFileYaml.Nodes {
+		if strings.Contains(enode, "@"+node.NodeUserIdent+":") {
+			return node.NodeUserIdent
+		}
+	}
+	return "unknown"
+}
+
+// getPermissionedNodes returns the enodes in the deployed permissioned-nodes configmap.
+func getPermissionedNodes(namespace string) ([]string, error) {
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "get", "configmap", PermissionedNodesConfigMap,
+		"-o=jsonpath={.data.permissioned-nodes\\.json}")
+	out, err := runCmd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var enodes []string
+	err = json.Unmarshal(out.Bytes(), &enodes)
+	return enodes, err
+}
+
+// setPermissionedNodes updates the deployed configmap in place, and if the k8sdir is set, the generated
+// permissioned-nodes.json and shared config so a later `qctl deploy network` does not revert the change.
+func setPermissionedNodes(enodes []string, namespace, k8sdir string) error {
+	enodesBytes, err := json.MarshalIndent(enodes, "", "  ")
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"data": map[string]string{PermissionedNodesKey: string(enodesBytes) + "\n"},
+	})
+	if err != nil {
+		return err
+	}
+	patchCmd := exec.Command("kubectl", "--namespace="+namespace, "patch", "configmap", PermissionedNodesConfigMap,
+		"--type=merge", "-p", string(patch))
+	if _, err := runCmd(patchCmd); err != nil {
+		return fmt.Errorf("patching configmap [%s] failed: %v", PermissionedNodesConfigMap, err)
+	}
+	if k8sdir == "" {
+		return nil
+	}
+	if err := ioutil.WriteFile(k8sdir+"/config/"+PermissionedNodesKey, append(enodesBytes, '\n'), 0644); err != nil {
+		return err
+	}
+	return updateSharedConfigPermissionedNodes(k8sdir+"/02-quorum-shared-config.yaml", string(enodesBytes))
+}
+
+// updateSharedConfigPermissionedNodes replaces the permissioned-nodes.json embedded in the shared config resource.
+func updateSharedConfigPermissionedNodes(sharedConfigFile, enodesJson string) error {
+	sharedConfigBytes, err := ioutil.ReadFile(sharedConfigFile)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(sharedConfigBytes), "\n")
+	var updated []string
+	inPermissionedNodes := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "  "+PermissionedNodesKey+":") {
+			inPermissionedNodes = true
+			updated = append(updated, line, "")
+			for _, enodeLine := range strings.Split(enodesJson, "\n") {
+				updated = append(updated, "    "+enodeLine)
+			}
+			updated = append(updated, "")
+			continue
+		}
+		// the permissioned-nodes.json is followed by the next resource in the file.
+		if inPermissionedNodes && strings.HasPrefix(line, "---") {
+			inPermissionedNodes = false
+		}
+		if !inPermissionedNodes {
+			updated = append(updated, line)
+		}
+	}
+	return ioutil.WriteFile(sharedConfigFile, []byte(strings.Join(updated, "\n")), 0644)
+}
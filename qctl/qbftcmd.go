@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	// qctl qbft transition
+	// qctl qbft transition --block=5000 --k8sdir=$QUBE_K8S_DIR
+	qbftTransitionCommand = cli.Command{
+		Name:  "transition",
+		Usage: "show the IBFT to QBFT transition status of the network, or set a future transition block.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{ // only required when setting the transition block.
+				Name:    "k8sdir",
+				Usage:   "The k8sdir (usually out) containing the output k8s resources",
+				EnvVars: []string{"QUBE_K8S_DIR"},
+			},
+			&cli.Uint64Flag{
+				Name:  "block",
+				Usage: "set the block at which the running network will switch from IBFT to QBFT, and roll the nodes.",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			k8sdir := c.String("k8sdir")
+			transitionBlock := c.Uint64("block")
+
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			if configFileYaml.Genesis.Consensus != IstanbulConsensus && configFileYaml.Genesis.Consensus != QibftConsensus {
+				return cli.Exit(fmt.Sprintf("the network consensus is [%s], qbft transition is only supported for istanbul networks", configFileYaml.Genesis.Consensus), 3)
+			}
+			if transitionBlock == 0 {
+				showQbftTransition(configFileYaml, namespace)
+				return nil
+			}
+			if k8sdir == "" {
+				red.Println("  Set --k8sdir flag or QUBE_K8S_DIR env in order to update the genesis of the network.")
+				return cli.Exit("--k8sdir must be set when setting the transition block.", 3)
+			}
+			// the transition can only happen in the future, otherwise the nodes would disagree about past blocks.
+			maxHeight := uint64(0)
+			for _, nodeName := range getNodeNames(configFileYaml) {
+				height, err := nodeBlockNumber(podNameFromPrefix(nodeName, namespace), namespace)
+				if err != nil {
+					red.Println(fmt.Sprintf("  unable to get the block number for node [%s], all nodes must be running to set the transition block.", nodeName))
+					return cli.Exit(err.Error(), 3)
+				}
+				if height > maxHeight {
+					maxHeight = height
+				}
+			}
+			if transitionBlock <= maxHeight {
+				return cli.Exit(fmt.Sprintf("transition block [%d] must be greater than the current network height [%d]", transitionBlock, maxHeight), 3)
+			}
+			green.Println(fmt.Sprintf("  Setting the QBFT transition block to [%d], current network height [%d]", transitionBlock, maxHeight))
+			fmt.Println()
+
+			genesisFile := genesisFilePath(configFileYaml, k8sdir)
+			if err := setGenesisQibftBlock(genesisFile, transitionBlock); err != nil {
+				return cli.Exit(fmt.Sprintf("unable to update the genesis file [%s]: %v", genesisFile, err), 3)
+			}
+			genesisConfigMapFile := k8sdir + "/01-quorum-genesis.yaml"
+			if err := writeGenesisConfigMap(genesisFile, genesisConfigMapFile, configFileYaml.K8s.Namespace); err != nil {
+				return cli.Exit(fmt.Sprintf("unable to update the genesis configmap [%s]: %v", genesisConfigMapFile, err), 3)
+			}
+			configFileYaml.Genesis.QibftBlock = strconv.FormatUint(transitionBlock, 10)
+			WriteYamlConfig(configFileYaml, configFile)
+
+			applyCmd := exec.Command("kubectl", "--namespace="+namespace, "apply", "-f", genesisConfigMapFile)
+			fmt.Println(applyCmd.String())
+			if err := dropIntoCmd(applyCmd); err != nil {
+				return cli.Exit(fmt.Sprintf("unable to apply the genesis configmap: %v", err), 3)
+			}
+			// roll the nodes one at a time, so the network keeps enough validators to continue producing blocks.
+			for _, nodeName := range getNodeNames(configFileYaml) {
+				green.Println(fmt.Sprintf("  Rolling node [%s]", nodeName))
+				if err := reinitGenesisAndRestart(nodeName, namespace); err != nil {
+					red.Println(fmt.Sprintf("  unable to roll node [%s]: %v", nodeName, err))
+					return cli.Exit(err.Error(), 3)
+				}
+			}
+			fmt.Println()
+			green.Println("  All nodes have been rolled with the new transition block, to check the transition status run:")
+			fmt.Println()
+			green.Println("    > qctl qbft transition")
+			fmt.Println()
+			return nil
+		},
+	}
+)
+
+// showQbftTransition displays the configured transition block, the height and transition state of each node, and
+// an estimate of when the transition will happen.
+func showQbftTransition(configFileYaml QConfig, namespace string) {
+	configuredBlock := configFileYaml.Genesis.QibftBlock
+	fmt.Println()
+	if configuredBlock == "" {
+		red.Println("  No qibftBlock set in the config, the network will stay on IBFT.")
+		fmt.Println()
+		red.Println("  To set a future transition block run:")
+		fmt.Println()
+		red.Println("    > qctl qbft transition --block=BLOCK_NUM")
+		fmt.Println()
+		return
+	}
+	transitionBlock, err := strconv.ParseUint(configuredBlock, 10, 64)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("invalid qibftBlock [%s] in config: %v", configuredBlock, err))
+	}
+	green.Println(fmt.Sprintf("  configured transition block: %d", transitionBlock))
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  NODE\tHEIGHT\tNODE QIBFT BLOCK\tSTATE")
+	maxHeight := uint64(0)
+	var blockTimePod string
+	allSwitched := true
+	for _, nodeName := range getNodeNames(configFileYaml) {
+		podName := podNameFromPrefix(nodeName, namespace)
+		height, err := nodeBlockNumber(podName, namespace)
+		if err != nil {
+			allSwitched = false
+			fmt.Fprintln(w, fmt.Sprintf("  %s\t-\t-\tunreachable", nodeName))
+			continue
+		}
+		if height > maxHeight {
+			maxHeight = height
+			blockTimePod = podName
+		}
+		nodeQibftBlock := nodeQibftBlock(podName, namespace)
+		state := "ibft"
+		if height >= transitionBlock {
+			// the node runs qbft once its head is past the qibftBlock of the chain config it was started with.
+			if nodeBlock, err := strconv.ParseUint(nodeQibftBlock, 10, 64); err == nil && height >= nodeBlock {
+				state = "qbft"
+			} else {
+				state = "NOT SWITCHED"
+				allSwitched = false
+			}
+		} else {
+			allSwitched = false
+			if nodeQibftBlock != configuredBlock {
+				state = "ibft (transition block not applied, roll the node)"
+			}
+		}
+		fmt.Fprintln(w, fmt.Sprintf("  %s\t%d\t%s\t%s", nodeName, height, nodeQibftBlock, state))
+	}
+	w.Flush()
+	fmt.Println()
+	if maxHeight >= transitionBlock {
+		if allSwitched {
+			green.Println("  The transition block has passed and all nodes have switched to QBFT.")
+		} else {
+			red.Println("  The transition block has passed, but not all nodes have switched to QBFT.")
+		}
+		fmt.Println()
+		return
+	}
+	blocksLeft := transitionBlock - maxHeight
+	blockTime, err := averageBlockTime(blockTimePod, namespace, maxHeight)
+	if err != nil {
+		green.Println(fmt.Sprintf("  %d blocks until the transition.", blocksLeft))
+	} else {
+		eta := time.Duration(blocksLeft) * blockTime
+		green.Println(fmt.Sprintf("  %d blocks until the transition, estimated in %v (~%v per block), at %s.",
+			blocksLeft, eta.Round(time.Second), blockTime.Round(time.Millisecond), time.Now().Add(eta).Format(time.RFC1123)))
+	}
+	fmt.Println()
+}
+
+// nodeQibftBlock gets the qibftBlock the node is actually running with from its chain config.
+func nodeQibftBlock(podName, namespace string) string {
+	var nodeInfo struct {
+		Protocols map[string]struct {
+			Config struct {
+				Istanbul struct {
+					QibftBlock *json.Number `json:"qibftBlock"`
+				} `json:"istanbul"`
+			} `json:"config"`
+		} `json:"protocols"`
+	}
+	if err := nodeRpcInto(podName, namespace, &nodeInfo, "admin_nodeInfo"); err != nil {
+		return "-"
+	}
+	for _, protocol := range nodeInfo.Protocols {
+		if protocol.Config.Istanbul.QibftBlock != nil {
+			return protocol.Config.Istanbul.QibftBlock.String()
+		}
+	}
+	return "-"
+}
+
+// averageBlockTime estimates the block time from the timestamps of the last (up to) 100 blocks.
+func averageBlockTime(podName, namespace string, head uint64) (time.Duration, error) {
+	if head < 2 {
+		return 0, fmt.Errorf("not enough blocks to estimate the block time")
+	}
+	from := uint64(1)
+	if head > 100 {
+		from = head - 100
+	}
+	headBlock, err := nodeBlockByNumber(podName, namespace, head)
+	if err != nil {
+		return 0, err
+	}
+	fromBlock, err := nodeBlockByNumber(podName, namespace, from)
+	if err != nil {
+		return 0, err
+	}
+	headTime, err := hexToUint64(headBlock.Timestamp)
+	if err != nil {
+		return 0, err
+	}
+	fromTime, err := hexToUint64(fromBlock.Timestamp)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(headTime-fromTime) * time.Second / time.Duration(head-from), nil
+}
+
+// genesisFilePath gets the genesis file the network is generated from, config.Genesis_File if set, otherwise the
+// default out/config/genesis.json. The paths are relative to the qubernetes dir, which has the k8sdir mounted as out.
+func genesisFilePath(configFileYaml QConfig, k8sdir string) string {
+	genesisFile := configFileYaml.Config.Genesis_File
+	if genesisFile == "" {
+		return filepath.Join(k8sdir, "config", "genesis.json")
+	}
+	genesisFile = filepath.Clean(strings.TrimPrefix(genesisFile, "/qubernetes/"))
+	if genesisFile == "out" || strings.HasPrefix(genesisFile, "out"+string(filepath.Separator)) {
+		return filepath.Join(k8sdir, strings.TrimPrefix(genesisFile, "out"))
+	}
+	if filepath.IsAbs(genesisFile) {
+		return genesisFile
+	}
+	return filepath.Join(filepath.Dir(k8sdir), genesisFile)
+}
+
+// setGenesisQibftBlock sets config.istanbul.qibftBlock in the genesis file, keeping the rest of the genesis intact.
+func setGenesisQibftBlock(genesisFile string, qibftBlock uint64) error {
+	genesisBytes, err := ioutil.ReadFile(genesisFile)
+	if err != nil {
+		return err
+	}
+	var genesis map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(genesisBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&genesis); err != nil {
+		return err
+	}
+	chainConfig, ok := genesis["config"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("genesis file has no config section")
+	}
+	istanbulConfig, ok := chainConfig["istanbul"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("genesis file has no istanbul config section")
+	}
+	istanbulConfig["qibftBlock"] = qibftBlock
+	genesisBytes, err = json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(genesisFile, genesisBytes, 0644)
+}
+
+// writeGenesisConfigMap writes the genesis-config configmap the same way templates/k8s/quorum-genesis-config.yaml.erb
+// does, so the k8sdir stays in sync with what is deployed. The namespace is the k8s.namespace of the config, not set if
+// empty.
+func writeGenesisConfigMap(genesisFile, configMapFile, k8sNamespace string) error {
+	genesisBytes, err := ioutil.ReadFile(genesisFile)
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString("apiVersion: v1\n")
+	sb.WriteString("kind: ConfigMap\n")
+	sb.WriteString("metadata:\n")
+	sb.WriteString("  name: genesis-config\n")
+	if k8sNamespace != "" {
+		sb.WriteString("  namespace: " + k8sNamespace + "\n")
+	}
+	sb.WriteString("  labels:\n")
+	sb.WriteString("    app: qubernetes\n")
+	sb.WriteString("    name: genesis-config\n")
+	sb.WriteString("data:\n")
+	sb.WriteString("  genesis-geth.json: |-\n")
+	for _, line := range strings.Split(strings.TrimSpace(string(genesisBytes)), "\n") {
+		sb.WriteString("      " + line + "\n")
+	}
+	return ioutil.WriteFile(configMapFile, []byte(sb.String()), 0644)
+}
+
+// reinitGenesisAndRestart removes the genesis_created marker so the init container re-runs `geth init` with the
+// updated genesis, then restarts the node and waits for it to become available.
+func reinitGenesisAndRestart(nodeName, namespace string) error {
+	podName := podNameFromPrefix(nodeName, namespace)
+	rmMarkerCmd := exec.Command("kubectl", "--namespace="+namespace, "exec", podName, "-c", "quorum", "--",
+		"sh", "-c", "rm -f $QUORUM_DATA_DIR/genesis_created")
+	if _, err := runCmd(rmMarkerCmd); err != nil {
+		return fmt.Errorf("unable to remove the genesis marker on pod [%s]: %v", podName, err)
+	}
+	return rolloutRestart(nodeName, namespace)
+}
+
+// rolloutRestart restarts the node's deployment and waits for the rollout to finish.
+func rolloutRestart(nodeName, namespace string) error {
+	restartCmd := exec.Command("kubectl", "--namespace="+namespace, "rollout", "restart", "deployment", nodeName+"-deployment")
+	fmt.Println(restartCmd.String())
+	if err := dropIntoCmd(restartCmd); err != nil {
+		return err
+	}
+	statusCmd := exec.Command("kubectl", "--namespace="+namespace, "rollout", "status", "deployment", nodeName+"-deployment", "--timeout=5m")
+	return dropIntoCmd(statusCmd)
+}
@@ -6,11 +6,28 @@ import (
 	"os"
 )
 
-// https://github.com/urfave/cli/blob/master/docs/v2/manual.md#getting-started
+// the qctl version, set by the build, e.g. go build -ldflags "-X main.version=v0.1.0", see builds/build-binaries.sh
+var version = "dev"
+
 func main() {
+	// QCTL_RECORD / QCTL_REPLAY, see runner.go
+	envRunner, err := runnerFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	runner = envRunner
+	err = newApp().Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// https://github.com/urfave/cli/blob/master/docs/v2/manual.md#getting-started
+func newApp() *cli.App {
 	app := cli.NewApp()
 	app.EnableBashCompletion = true
 	app.Name = "qctl"
+	app.Version = version
 	app.Usage = "command line tool for managing qubernetes network. Yay!"
 	app.Flags = []cli.Flag{
 		&cli.StringFlag{
@@ -37,6 +54,8 @@ func main() {
 			Subcommands: []*cli.Command{
 				&testContractCmd,
 				&acceptanceTestRunCmd,
+				&smokeTestCmd,
+				&upgradeTestCmd,
 			},
 		},
 		{
@@ -44,6 +63,7 @@ func main() {
 			Usage: "options for generating base config / resources",
 			Subcommands: []*cli.Command{
 				&generateNetworkCommand,
+				&generatePermissioningCommand,
 			},
 		},
 		{
@@ -126,11 +146,127 @@ func main() {
 		//	},
 		//},
 
-		&nodeConnectCommand,
-	}
+		{
+			Name:  "qbft",
+			Usage: "options for the IBFT to QBFT transition",
+			Subcommands: []*cli.Command{
+				&qbftTransitionCommand,
+			},
+		},
+		{
+			Name:  "validators",
+			Usage: "options for inspecting the istanbul validators",
+			Subcommands: []*cli.Command{
+				&validatorsStatusCommand,
+			},
+		},
+		{
+			Name:  "permissions",
+			Usage: "options for managing the permissioned-nodes of the running network",
+			Subcommands: []*cli.Command{
+				&permissionsListCommand,
+				&permissionsAddCommand,
+				&permissionsRemoveCommand,
+			},
+		},
+		{
+			Name:  "check",
+			Usage: "options for checking the health of the running network",
+			Subcommands: []*cli.Command{
+				&checkChainCommand,
+			},
+		},
+		{
+			Name:  "tx",
+			Usage: "options for inspecting transactions across the nodes",
+			Subcommands: []*cli.Command{
+				&txShowCommand,
+			},
+		},
+		{
+			Name:  "tm",
+			Usage: "options for the transaction managers (tessera / constellation)",
+			Subcommands: []*cli.Command{
+				&tmCheckCommand,
+			},
+		},
+		{
+			Name:  "contract",
+			Usage: "options for deploying and interacting with contracts",
+			Subcommands: []*cli.Command{
+				&contractDeployCommand,
+				&contractListCommand,
+				&contractCallCommand,
+				&contractSendCommand,
+			},
+		},
+		{
+			Name:  "chaos",
+			Usage: "run chaos experiments against the running network and check it recovers",
+			Subcommands: []*cli.Command{
+				&chaosKillCommand,
+				&chaosPauseCommand,
+				&chaosIsolateCommand,
+				&chaosPartitionCommand,
+			},
+		},
+		{
+			Name:  "workload",
+			Usage: "record the transactions of a network and replay them against another network",
+			Subcommands: []*cli.Command{
+				&workloadRecordCommand,
+				&workloadReplayCommand,
+			},
+		},
+		{
+			Name:  "debug",
+			Usage: "options for debugging the network",
+			Subcommands: []*cli.Command{
+				&debugBundleCommand,
+			},
+		},
+		{
+			Name:  "perm",
+			Usage: "options for managing the smart contract permissioning of the running network",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "org",
+					Usage: "manage the permissioned organizations",
+					Subcommands: []*cli.Command{
+						&permOrgListCommand,
+						&permOrgAddCommand,
+						&permOrgApproveCommand,
+						&permOrgStatusCommand,
+					},
+				},
+				{
+					Name:  "node",
+					Usage: "manage the nodes of the permissioned organizations",
+					Subcommands: []*cli.Command{
+						&permNodeListCommand,
+						&permNodeAddCommand,
+						&permNodeStatusCommand,
+					},
+				},
+				{
+					Name:  "account",
+					Usage: "manage the accounts and roles of the permissioned organizations",
+					Subcommands: []*cli.Command{
+						&permAccountListCommand,
+						&permAccountAssignCommand,
+						&permAccountStatusCommand,
+					},
+				},
+			},
+		},
 
-	err := app.Run(os.Args)
-	if err != nil {
-		log.Fatal(err)
+		&networkStatusCommand,
+		&watchCommand,
+		&exporterCommand,
+		&eventsCommand,
+		&topologyCommand,
+		&benchCommand,
+		&nodeConnectCommand,
 	}
+	return app
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// JSON-RPC helpers for talking to the geth process running on a node.
+// The requests are run from inside the quorum container (kubectl exec + curl) so the RPC port does not
+// need to be exposed outside of the K8s cluster, the same way `qctl geth exec` works.
+
+type rpcRequest struct {
+	Jsonrpc string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	Id      int           `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// the subset of the block fields qctl cares about.
+type rpcBlock struct {
+	Number       string   `json:"number"`
+	Hash         string   `json:"hash"`
+	ParentHash   string   `json:"parentHash"`
+	StateRoot    string   `json:"stateRoot"`
+	Timestamp    string   `json:"timestamp"`
+	Miner        string   `json:"miner"`
+	ExtraData    string   `json:"extraData"`
+	Transactions []string `json:"transactions"`
+}
+
+// a log of eth_getLogs and the logs subscription.
+type rpcLog struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber"`
+	TransactionHash string   `json:"transactionHash"`
+	LogIndex        string   `json:"logIndex"`
+	Removed         bool     `json:"removed"`
+}
+
+// nodeRpc calls the JSON-RPC method on the geth node running in the given pod, returning the raw result.
+func nodeRpc(podName, namespace, method string, params ...interface{}) (json.RawMessage, error) {
+	if podName == "" {
+		return nil, errors.New("no running pod to send the request to")
+	}
+	if params == nil {
+		params = []interface{}{}
+	}
+	req, err := json.Marshal(rpcRequest{Jsonrpc: "2.0", Method: method, Params: params, Id: 1})
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "exec", podName, "-c", "quorum", "--",
+		"curl", "-s", "-X", "POST", "-H", "Content-Type: application/json", "--data", string(req), "http://localhost:"+DefaultGethPort)
+	out, err := runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("rpc [%s] on pod [%s] failed: %v", method, podName, err)
+	}
+	var res rpcResponse
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		return nil, fmt.Errorf("rpc [%s] on pod [%s] returned an invalid response [%s]", method, podName, strings.TrimSpace(out.String()))
+	}
+	if res.Error != nil {
+		return nil, fmt.Errorf("rpc [%s] on pod [%s] returned error [%d] %s", method, podName, res.Error.Code, res.Error.Message)
+	}
+	return res.Result, nil
+}
+
+// nodeRpcInto calls the JSON-RPC method and unmarshals the result into result.
+func nodeRpcInto(podName, namespace string, result interface{}, method string, params ...interface{}) error {
+	raw, err := nodeRpc(podName, namespace, method, params...)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// nodeRpcBatch sends the requests as one JSON-RPC batch, the responses are returned in the order of the requests.
+// Used when many calls are needed (e.g. scanning a range of blocks) as every kubectl exec is expensive.
+func nodeRpcBatch(podName, namespace string, requests []rpcRequest) ([]rpcResponse, error) {
+	if podName == "" {
+		return nil, errors.New("no running pod to send the request to")
+	}
+	for i := range requests {
+		requests[i].Jsonrpc = "2.0"
+		requests[i].Id = i
+		if requests[i].Params == nil {
+			requests[i].Params = []interface{}{}
+		}
+	}
+	req, err := json.Marshal(requests)
+	if err != nil {
+		return nil, err
+	}
+	// the batch can be too large for the command line, so it is sent on stdin.
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "exec", "-i", podName, "-c", "quorum", "--",
+		"curl", "-s", "-X", "POST", "-H", "Content-Type: application/json", "--data-binary", "@-", "http://localhost:"+DefaultGethPort)
+	cmd.Stdin = bytes.NewReader(req)
+	out, err := runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("rpc batch on pod [%s] failed: %v", podName, err)
+	}
+	var batch []struct {
+		Id int `json:"id"`
+		rpcResponse
+	}
+	if err := json.Unmarshal(out.Bytes(), &batch); err != nil {
+		return nil, fmt.Errorf("rpc batch on pod [%s] returned an invalid response: %v", podName, err)
+	}
+	responses := make([]rpcResponse, len(requests))
+	for _, res := range batch {
+		if res.Id >= 0 && res.Id < len(responses) {
+			responses[res.Id] = res.rpcResponse
+		}
+	}
+	return responses, nil
+}
+
+func nodeBlockNumber(podName, namespace string) (uint64, error) {
+	var blockNumHex string
+	if err := nodeRpcInto(podName, namespace, &blockNumHex, "eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return hexToUint64(blockNumHex)
+}
+
+func nodeBlockByNumber(podName, namespace string, blockNumber uint64) (rpcBlock, error) {
+	var block rpcBlock
+	raw, err := nodeRpc(podName, namespace, "eth_getBlockByNumber", uint64ToHex(blockNumber), false)
+	if err != nil {
+		return block, err
+	}
+	if string(raw) == "null" {
+		return block, fmt.Errorf("block [%d] not found on pod [%s]", blockNumber, podName)
+	}
+	err = json.Unmarshal(raw, &block)
+	return block, err
+}
+
+func hexToUint64(hex string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(hex, "0x"), 16, 64)
+}
+
+func uint64ToHex(num uint64) string {
+	return "0x" + strconv.FormatUint(num, 16)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// all the external processes qctl runs (kubectl, docker, rm, pwd, ...) go through the runner, so the interactions
+// can be recorded against a real network and replayed in tests.
+//
+// QCTL_RECORD=fixture.json qctl ls urls --type=nodeport   records every command, its input, output and exit code.
+// QCTL_REPLAY=fixture.json qctl ls urls --type=nodeport   answers every command from the fixture instead.
+type Runner interface {
+	// Run runs the command to completion, reading the cmd.Stdin and writing to cmd.Stdout / cmd.Stderr like exec.Cmd.Run
+	Run(cmd *exec.Cmd) error
+	// Start starts the command in the background like exec.Cmd.Start, e.g. a kubectl port-forward. The returned func
+	// stops the command and waits for it to exit.
+	Start(cmd *exec.Cmd) (func(), error)
+}
+
+var runner Runner = execRunner{}
+
+// a single command run, as stored in the fixture.
+type cmdInteraction struct {
+	Args     []string `json:"args"`
+	Stdin    string   `json:"stdin,omitempty"`
+	Stdout   string   `json:"stdout,omitempty"`
+	Stderr   string   `json:"stderr,omitempty"`
+	ExitCode int      `json:"exitCode,omitempty"`
+	Err      string   `json:"err,omitempty"` // the command could not be started, e.g. not installed.
+}
+
+// runs the commands for real.
+type execRunner struct{}
+
+func (execRunner) Run(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+func (execRunner) Start(cmd *exec.Cmd) (func(), error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}, nil
+}
+
+// recordingRunner runs the commands with the wrapped runner and writes every interaction to the fixture file.
+// The fixture is rewritten after each command, as qctl often exits through log.Fatal.
+type recordingRunner struct {
+	runner       Runner
+	fixtureFile  string
+	vars         []string // name, value pairs replaced by ${name} in the fixture, e.g. the working directory.
+	mu           sync.Mutex
+	interactions []cmdInteraction
+}
+
+func (r *recordingRunner) Run(cmd *exec.Cmd) error {
+	interaction := cmdInteraction{Args: cmd.Args}
+	// interactive commands (a terminal on stdin) are run but their input is not recorded.
+	if cmd.Stdin != nil {
+		if _, isFile := cmd.Stdin.(*os.File); !isFile {
+			stdin, err := ioutil.ReadAll(cmd.Stdin)
+			if err != nil {
+				return err
+			}
+			interaction.Stdin = string(stdin)
+			cmd.Stdin = bytes.NewReader(stdin)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = teeWriter(cmd.Stdout, &stdout)
+	cmd.Stderr = teeWriter(cmd.Stderr, &stderr)
+	err := r.runner.Run(cmd)
+	interaction.Stdout = stdout.String()
+	interaction.Stderr = stderr.String()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		interaction.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		interaction.Err = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, replaceVars(interaction, r.vars, false))
+	if saveErr := saveInteractions(r.fixtureFile, r.interactions); saveErr != nil {
+		return fmt.Errorf("unable to record to [%s]: %v", r.fixtureFile, saveErr)
+	}
+	return err
+}
+
+// Start records the background command when it is started, its output is not recorded.
+func (r *recordingRunner) Start(cmd *exec.Cmd) (func(), error) {
+	interaction := cmdInteraction{Args: cmd.Args}
+	stop, err := r.runner.Start(cmd)
+	if err != nil {
+		interaction.Err = err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, replaceVars(interaction, r.vars, false))
+	if saveErr := saveInteractions(r.fixtureFile, r.interactions); saveErr != nil {
+		if stop != nil {
+			stop()
+		}
+		return nil, fmt.Errorf("unable to record to [%s]: %v", r.fixtureFile, saveErr)
+	}
+	return stop, err
+}
+
+// replayRunner answers the commands from the recorded interactions. Each command is matched to the first unused
+// interaction with the same args and stdin, so commands run concurrently replay in any order. Once all the matching
+// interactions are used the last one is repeated, e.g. kubectl get service is run for every node.
+type replayRunner struct {
+	vars         []string
+	mu           sync.Mutex
+	interactions []cmdInteraction
+	used         []bool
+	unmatched    [][]string // commands that had no recorded interaction.
+}
+
+// the error of a replayed command that exited non zero, it reads like the *exec.ExitError of the recorded run.
+type replayExitError struct {
+	ExitCode int
+}
+
+func (e *replayExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.ExitCode)
+}
+
+func newReplayRunner(fixtureFile string, vars ...string) (*replayRunner, error) {
+	interactions, err := loadInteractions(fixtureFile)
+	if err != nil {
+		return nil, err
+	}
+	r := &replayRunner{vars: vars, used: make([]bool, len(interactions))}
+	for _, interaction := range interactions {
+		r.interactions = append(r.interactions, replaceVars(interaction, vars, true))
+	}
+	return r, nil
+}
+
+func (r *replayRunner) Run(cmd *exec.Cmd) error {
+	stdin := ""
+	if cmd.Stdin != nil {
+		if _, isFile := cmd.Stdin.(*os.File); !isFile {
+			in, err := ioutil.ReadAll(cmd.Stdin)
+			if err != nil {
+				return err
+			}
+			stdin = string(in)
+		}
+	}
+	interaction, err := r.match(cmd, stdin)
+	if err != nil {
+		return err
+	}
+	if cmd.Stdout != nil {
+		io.WriteString(cmd.Stdout, interaction.Stdout)
+	}
+	if cmd.Stderr != nil {
+		io.WriteString(cmd.Stderr, interaction.Stderr)
+	}
+	if interaction.Err != "" {
+		return errors.New(interaction.Err)
+	}
+	if interaction.ExitCode != 0 {
+		return &replayExitError{ExitCode: interaction.ExitCode}
+	}
+	return nil
+}
+
+// Start replays the start of the background command, nothing is left running so the returned func does nothing.
+func (r *replayRunner) Start(cmd *exec.Cmd) (func(), error) {
+	interaction, err := r.match(cmd, "")
+	if err != nil {
+		return nil, err
+	}
+	if interaction.Err != "" {
+		return nil, errors.New(interaction.Err)
+	}
+	return func() {}, nil
+}
+
+// match returns the interaction recorded for the command and marks it used.
+func (r *replayRunner) match(cmd *exec.Cmd, stdin string) (cmdInteraction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	match := -1
+	for i, interaction := range r.interactions {
+		if interaction.Stdin != stdin || strings.Join(interaction.Args, "\x00") != strings.Join(cmd.Args, "\x00") {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		r.unmatched = append(r.unmatched, cmd.Args)
+		return cmdInteraction{}, fmt.Errorf("no recorded interaction for [%s]", strings.Join(cmd.Args, " "))
+	}
+	r.used[match] = true
+	return r.interactions[match], nil
+}
+
+// runnerFromEnv returns the recording or replaying runner if QCTL_RECORD or QCTL_REPLAY is set.
+func runnerFromEnv() (Runner, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	vars := []string{"PWD", pwd}
+	if fixtureFile := os.Getenv("QCTL_REPLAY"); fixtureFile != "" {
+		return newReplayRunner(fixtureFile, vars...)
+	}
+	if fixtureFile := os.Getenv("QCTL_RECORD"); fixtureFile != "" {
+		return &recordingRunner{runner: execRunner{}, fixtureFile: fixtureFile, vars: vars}, nil
+	}
+	return execRunner{}, nil
+}
+
+func loadInteractions(fixtureFile string) ([]cmdInteraction, error) {
+	fixtureBytes, err := ioutil.ReadFile(fixtureFile)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []cmdInteraction
+	err = json.Unmarshal(fixtureBytes, &interactions)
+	return interactions, err
+}
+
+func saveInteractions(fixtureFile string, interactions []cmdInteraction) error {
+	fixtureBytes, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fixtureFile, append(fixtureBytes, '\n'), 0644)
+}
+
+// replaceVars replaces the values of the vars with ${name} when recording, and back when replaying, so the fixtures
+// do not depend on the directory they were recorded in.
+func replaceVars(interaction cmdInteraction, vars []string, expand bool) cmdInteraction {
+	replace := func(s string) string {
+		for i := 0; i+1 < len(vars); i += 2 {
+			name, value := "${"+vars[i]+"}", vars[i+1]
+			if value == "" {
+				continue
+			}
+			if expand {
+				s = strings.ReplaceAll(s, name, value)
+			} else {
+				s = strings.ReplaceAll(s, value, name)
+			}
+		}
+		return s
+	}
+	args := make([]string, len(interaction.Args))
+	for i, arg := range interaction.Args {
+		args[i] = replace(arg)
+	}
+	interaction.Args = args
+	interaction.Stdin = replace(interaction.Stdin)
+	interaction.Stdout = replace(interaction.Stdout)
+	interaction.Stderr = replace(interaction.Stderr)
+	return interaction
+}
+
+func teeWriter(w io.Writer, buf *bytes.Buffer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(w, buf)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qctl-runner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fixtureFile := filepath.Join(dir, "fixture.json")
+
+	recorder := &recordingRunner{runner: execRunner{}, fixtureFile: fixtureFile, vars: []string{"PWD", dir}}
+	cmd := exec.Command("sh", "-c", "cat; echo "+dir+"; exit 3")
+	cmd.Stdin = strings.NewReader("input\n")
+	var recorded bytes.Buffer
+	cmd.Stdout = &recorded
+	if err := recorder.Run(cmd); err == nil || err.Error() != "exit status 3" {
+		t.Fatalf("expected exit status 3, got [%v]", err)
+	}
+	interactions, err := loadInteractions(fixtureFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interactions) != 1 || interactions[0].Stdout != "input\n${PWD}\n" || interactions[0].ExitCode != 3 {
+		t.Fatalf("unexpected recorded interactions %+v", interactions)
+	}
+
+	// the same command replays the recorded output and error, with ${PWD} set to the replaying dir.
+	replayer, err := newReplayRunner(fixtureFile, "PWD", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		cmd = exec.Command("sh", "-c", "cat; echo "+dir+"; exit 3")
+		cmd.Stdin = strings.NewReader("input\n")
+		var replayed bytes.Buffer
+		cmd.Stdout = &replayed
+		if err := replayer.Run(cmd); err == nil || err.Error() != "exit status 3" {
+			t.Fatalf("expected exit status 3, got [%v]", err)
+		}
+		if replayed.String() != recorded.String() {
+			t.Fatalf("replayed [%s], recorded [%s]", replayed.String(), recorded.String())
+		}
+	}
+	// different stdin is a different interaction.
+	cmd = exec.Command("sh", "-c", "cat; echo "+dir+"; exit 3")
+	cmd.Stdin = strings.NewReader("other\n")
+	if err := replayer.Run(cmd); err == nil || len(replayer.unmatched) != 1 {
+		t.Fatalf("expected the command to be unmatched, got [%v]", err)
+	}
+}
+
+// a background command is recorded when it is started, and the stop func kills it.
+func TestRecordReplayStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qctl-runner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fixtureFile := filepath.Join(dir, "fixture.json")
+
+	recorder := &recordingRunner{runner: execRunner{}, fixtureFile: fixtureFile}
+	cmd := exec.Command("sleep", "60")
+	stop, err := recorder.Start(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if cmd.ProcessState == nil {
+		t.Fatal("expected the command to have exited after stop")
+	}
+
+	replayer, err := newReplayRunner(fixtureFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stop, err := replayer.Start(exec.Command("sleep", "60")); err != nil {
+		t.Fatal(err)
+	} else {
+		stop()
+	}
+	if _, err := replayer.Start(exec.Command("sleep", "30")); err == nil {
+		t.Fatal("expected the command to be unmatched")
+	}
+}
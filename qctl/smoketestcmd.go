@@ -0,0 +1,531 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the smoke test contract, hand assembled so qctl does not need solc or the test containers. Every call stores the
+// first 32 bytes of the call data in slot 0, which is read back with eth_getStorageAt.
+//
+//	init:    PUSH1 0x07 DUP1 PUSH1 0x0b PUSH1 0x00 CODECOPY PUSH1 0x00 RETURN
+//	runtime: PUSH1 0x00 CALLDATALOAD PUSH1 0x00 SSTORE STOP
+const smokeContractBytecode = "0x600780600b6000396000f3" + "60003560005500"
+const smokeTxGas = "0x30d40"
+
+// a node taking part in the smoke test.
+type smokeNode struct {
+	Name    string
+	Pod     string
+	Account string
+	TmKey   string
+}
+
+// the state shared by the smoke checks, later checks use the contracts deployed by the earlier ones.
+type smokeTest struct {
+	namespace       string
+	consensus       string
+	quorumVersion   string
+	nodes           []smokeNode
+	timeout         time.Duration
+	privateContract string
+	privateBlock    uint64
+}
+
+type smokeCheck struct {
+	Name string
+	Run  func(t *smokeTest) error
+}
+
+// the error returned by a check that does not apply to the network, e.g. contract extension with 2 nodes.
+type smokeSkip string
+
+func (s smokeSkip) Error() string {
+	return string(s)
+}
+
+type smokeResult struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+func (r smokeResult) skipped() bool {
+	_, isSkip := r.Err.(smokeSkip)
+	return isSkip
+}
+
+var smokeChecks = []smokeCheck{
+	{"transaction managers are up", smokeTmUp},
+	{"blocks are produced by the consensus", smokeBlockProduction},
+	{"public transaction is mined on all nodes", smokePublicTx},
+	{"private transaction is only visible to the participants", smokePrivateTx},
+	{"private state root matches on the participants", smokePrivateStateRoot},
+	{"private contract is extended to a new participant", smokeContractExtension},
+}
+
+var (
+	// qctl test smoke
+	// qctl test smoke --output=junit --report=smoke.xml
+	smokeTestCmd = cli.Command{
+		Name:  "smoke",
+		Usage: "run the smoke tests against the running network over JSON-RPC and the tessera API.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "the report format: tap | junit",
+				Value: "tap",
+			},
+			&cli.StringFlag{
+				Name:  "report",
+				Usage: "write the report to the file instead of stdout.",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "how long each check waits for blocks, receipts and the other nodes.",
+				Value: DefaultReceiptTimeout,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			output := c.String("output")
+			if output != "tap" && output != "junit" {
+				return cli.Exit(fmt.Sprintf("invalid output [%s], must be tap or junit", output), 2)
+			}
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			var report io.Writer = os.Stdout
+			if c.String("report") != "" {
+				reportFile, err := os.Create(c.String("report"))
+				if err != nil {
+					return cli.Exit(err.Error(), 2)
+				}
+				defer reportFile.Close()
+				report = reportFile
+			}
+			test := &smokeTest{namespace: namespace, consensus: configFileYaml.Genesis.Consensus,
+				quorumVersion: configFileYaml.Genesis.QuorumVersion, timeout: c.Duration("timeout")}
+			for _, nodeName := range getNodeNames(configFileYaml) {
+				node := smokeNode{Name: nodeName, Pod: podNameFromPrefix(nodeName, namespace)}
+				node.Account, _ = nodeAccount(node.Pod, namespace)
+				node.TmKey, _ = getTmPublicKey(nodeName, namespace)
+				test.nodes = append(test.nodes, node)
+			}
+
+			results := runSmokeChecks(test, smokeChecks, output, report)
+			if output == "junit" {
+				if err := writeJUnitReport(report, "qctl smoke", results); err != nil {
+					return cli.Exit(err.Error(), 3)
+				}
+			}
+			failed := 0
+			for _, result := range results {
+				if result.Err != nil && !result.skipped() {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return cli.Exit(fmt.Sprintf("%d of %d smoke checks failed.", failed, len(results)), 1)
+			}
+			return nil
+		},
+	}
+)
+
+// runSmokeChecks runs the checks in order, the TAP report is written as the checks complete.
+func runSmokeChecks(test *smokeTest, checks []smokeCheck, output string, report io.Writer) []smokeResult {
+	if output == "tap" {
+		fmt.Fprintln(report, "TAP version 13")
+		fmt.Fprintln(report, fmt.Sprintf("1..%d", len(checks)))
+	}
+	var results []smokeResult
+	for i, check := range checks {
+		start := time.Now()
+		result := smokeResult{Name: check.Name, Err: check.Run(test)}
+		result.Duration = time.Since(start)
+		results = append(results, result)
+		if output == "tap" {
+			writeTapResult(report, i+1, result)
+		}
+	}
+	return results
+}
+
+func writeTapResult(w io.Writer, number int, result smokeResult) {
+	switch {
+	case result.Err == nil:
+		fmt.Fprintln(w, fmt.Sprintf("ok %d - %s", number, result.Name))
+	case result.skipped():
+		fmt.Fprintln(w, fmt.Sprintf("ok %d - %s # SKIP %v", number, result.Name, result.Err))
+	default:
+		fmt.Fprintln(w, fmt.Sprintf("not ok %d - %s", number, result.Name))
+		fmt.Fprintln(w, "  ---")
+		fmt.Fprintln(w, fmt.Sprintf("  message: %q", result.Err.Error()))
+		fmt.Fprintln(w, "  ...")
+	}
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+func writeJUnitReport(w io.Writer, suiteName string, results []smokeResult) error {
+	suite := junitTestSuite{Name: suiteName, Tests: len(results)}
+	var total time.Duration
+	for _, result := range results {
+		total += result.Duration
+		testCase := junitTestCase{Name: result.Name, ClassName: strings.ReplaceAll(suiteName, " ", "."),
+			Time: fmt.Sprintf("%.3f", result.Duration.Seconds())}
+		if result.skipped() {
+			suite.Skipped++
+			testCase.Skipped = &junitMessage{Message: result.Err.Error()}
+		} else if result.Err != nil {
+			suite.Failures++
+			testCase.Failure = &junitMessage{Message: result.Err.Error()}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+	out, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, xml.Header+string(out))
+	return err
+}
+
+func smokeTmUp(t *smokeTest) error {
+	var problems []string
+	for _, node := range t.nodes {
+		if !tmUpcheck(node.Pod, t.namespace) {
+			problems = append(problems, fmt.Sprintf("%s: upcheck failed", node.Name))
+			continue
+		}
+		if node.TmKey == "" {
+			problems = append(problems, fmt.Sprintf("%s: no tm public key", node.Name))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// smokeBlockProduction checks the consensus is healthy and every node sees new blocks, raft only makes blocks for
+// transactions so a transaction is sent first.
+func smokeBlockProduction(t *smokeTest) error {
+	switch t.consensus {
+	case RaftConsensus:
+		var leader string
+		if err := nodeRpcInto(t.nodes[0].Pod, t.namespace, &leader, "raft_leader"); err != nil || leader == "" {
+			return fmt.Errorf("no raft leader: %v", err)
+		}
+	case IstanbulConsensus, QibftConsensus:
+		var validators []string
+		if err := nodeRpcInto(t.nodes[0].Pod, t.namespace, &validators, "istanbul_getValidators"); err != nil {
+			return fmt.Errorf("unable to get the validators: %v", err)
+		}
+		if len(validators) == 0 {
+			return errors.New("the network has no validators")
+		}
+	}
+	start := maxBlockNumber(smokeNodeNames(t.nodes), t.namespace)
+	if t.consensus == RaftConsensus {
+		if _, err := sendTransaction(t.nodes[0].Pod, t.namespace, rpcTx{From: t.nodes[0].Account, Data: smokeContractBytecode,
+			Gas: smokeTxGas}); err != nil {
+			return err
+		}
+	}
+	return t.waitForHeight(t.nodes, start+1)
+}
+
+// smokePublicTx deploys the contract publicly, sets its value and checks the code and value on every node.
+func smokePublicTx(t *smokeTest) error {
+	sender := t.nodes[0]
+	address, _, err := t.deploy(sender, nil)
+	if err != nil {
+		return err
+	}
+	value := uint64(time.Now().Unix())
+	_, block, err := t.set(sender, address, value, nil)
+	if err != nil {
+		return err
+	}
+	if err := t.waitForHeight(t.nodes, block); err != nil {
+		return err
+	}
+	var problems []string
+	for _, node := range t.nodes {
+		if stored, err := smokeStoredValue(node, t.namespace, address); err != nil || stored != value {
+			problems = append(problems, fmt.Sprintf("%s: value [%d] expected [%d] %v", node.Name, stored, value, err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// smokePrivateTx deploys the contract private for the second node, then checks the code, value and tessera payload
+// are only on the participants.
+func smokePrivateTx(t *smokeTest) error {
+	if len(t.nodes) < 2 {
+		return smokeSkip("a private transaction needs at least 2 nodes")
+	}
+	sender, recipient := t.nodes[0], t.nodes[1]
+	privateFor := []string{recipient.TmKey}
+	address, _, err := t.deploy(sender, privateFor)
+	if err != nil {
+		return err
+	}
+	value := uint64(time.Now().Unix())
+	setTx, block, err := t.set(sender, address, value, privateFor)
+	if err != nil {
+		return err
+	}
+	if err := t.waitForHeight(t.nodes, block); err != nil {
+		return err
+	}
+	// the input of a private transaction is the hash of the encrypted payload stored by tessera.
+	var tx struct {
+		Input string `json:"input"`
+	}
+	if err := nodeRpcInto(sender.Pod, t.namespace, &tx, "eth_getTransactionByHash", setTx); err != nil {
+		return err
+	}
+	tmHash, err := hex.DecodeString(strings.TrimPrefix(tx.Input, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid private transaction input [%s]", tx.Input)
+	}
+	payloadPath := "/transaction/" + url.PathEscape(base64.StdEncoding.EncodeToString(tmHash))
+
+	var problems []string
+	for i, node := range t.nodes {
+		participant := i < 2
+		stored, err := smokeStoredValue(node, t.namespace, address)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", node.Name, err))
+			continue
+		}
+		_, payloadErr := tmQ2TGet(node.Pod, t.namespace, payloadPath)
+		switch {
+		case participant && stored != value:
+			problems = append(problems, fmt.Sprintf("%s: value [%d] expected [%d]", node.Name, stored, value))
+		case participant && payloadErr != nil:
+			problems = append(problems, fmt.Sprintf("%s: private payload not found in tessera", node.Name))
+		case !participant && stored != 0:
+			problems = append(problems, fmt.Sprintf("%s: non participant sees the private value [%d]", node.Name, stored))
+		case !participant && payloadErr == nil:
+			problems = append(problems, fmt.Sprintf("%s: non participant has the private payload in tessera", node.Name))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	t.privateContract, t.privateBlock = address, block
+	return nil
+}
+
+// smokePrivateStateRoot compares the private storage root of the private contract on the participants.
+func smokePrivateStateRoot(t *smokeTest) error {
+	if t.privateContract == "" {
+		return smokeSkip("no private contract was deployed")
+	}
+	roots := map[string]string{}
+	for _, node := range t.nodes[:2] {
+		var root string
+		if err := nodeRpcInto(node.Pod, t.namespace, &root, "eth_storageRoot", t.privateContract, uint64ToHex(t.privateBlock)); err != nil {
+			return fmt.Errorf("%s: %v", node.Name, err)
+		}
+		roots[node.Name] = root
+	}
+	if roots[t.nodes[0].Name] != roots[t.nodes[1].Name] {
+		return fmt.Errorf("private storage root mismatch at block %d: %v", t.privateBlock, roots)
+	}
+	return nil
+}
+
+// smokeContractExtension extends the private contract to the third node, which accepts it and must then see the value.
+func smokeContractExtension(t *smokeTest) error {
+	if t.privateContract == "" {
+		return smokeSkip("no private contract was deployed")
+	}
+	if len(t.nodes) < 3 {
+		return smokeSkip("contract extension needs at least 3 nodes")
+	}
+	sender, participant, newParticipant := t.nodes[0], t.nodes[1], t.nodes[2]
+	expected, err := smokeStoredValue(sender, t.namespace, t.privateContract)
+	if err != nil {
+		return err
+	}
+	var extensionTx string
+	err = nodeRpcInto(sender.Pod, t.namespace, &extensionTx, "quorumExtension_extendContract", t.privateContract,
+		newParticipant.TmKey, newParticipant.Account,
+		rpcTx{From: sender.Account, PrivateFor: []string{participant.TmKey, newParticipant.TmKey}})
+	if err != nil {
+		if strings.Contains(err.Error(), "does not exist") {
+			return smokeSkip(fmt.Sprintf("contract extension is not supported by quorum [%s]", t.quorumVersion))
+		}
+		return err
+	}
+	if _, err := waitForReceipt(sender.Pod, t.namespace, extensionTx, t.timeout); err != nil {
+		return err
+	}
+	// the new participant votes on the management contract of the extension.
+	var managementContract string
+	deadline := time.Now().Add(t.timeout)
+	for managementContract == "" {
+		var extensions []struct {
+			ManagementContractAddress string `json:"managementContractAddress"`
+			ContractExtended          string `json:"contractExtended"`
+		}
+		if err := nodeRpcInto(newParticipant.Pod, t.namespace, &extensions, "quorumExtension_activeExtensionContracts"); err != nil {
+			return err
+		}
+		for _, extension := range extensions {
+			if strings.EqualFold(extension.ContractExtended, t.privateContract) {
+				managementContract = extension.ManagementContractAddress
+			}
+		}
+		if managementContract == "" {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("the extension is not visible on %s", newParticipant.Name)
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	var approveTx string
+	if err := nodeRpcInto(newParticipant.Pod, t.namespace, &approveTx, "quorumExtension_approveExtension", managementContract, true,
+		rpcTx{From: newParticipant.Account, PrivateFor: []string{sender.TmKey, participant.TmKey}}); err != nil {
+		return err
+	}
+	if _, err := waitForReceipt(newParticipant.Pod, t.namespace, approveTx, t.timeout); err != nil {
+		return err
+	}
+	// the state is shared by the extension, after the approval is mined.
+	for {
+		stored, err := smokeStoredValue(newParticipant, t.namespace, t.privateContract)
+		if err == nil && stored == expected {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s: value [%d] expected [%d] after the extension %v", newParticipant.Name, stored, expected, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// deploy deploys the smoke contract and returns its address and block.
+func (t *smokeTest) deploy(sender smokeNode, privateFor []string) (string, uint64, error) {
+	txHash, err := sendTransaction(sender.Pod, t.namespace, rpcTx{From: sender.Account, Data: smokeContractBytecode,
+		Gas: smokeTxGas, PrivateFor: privateFor})
+	if err != nil {
+		return "", 0, fmt.Errorf("deploying the contract from %s failed: %v", sender.Name, err)
+	}
+	receipt, err := waitForReceipt(sender.Pod, t.namespace, txHash, t.timeout)
+	if err != nil {
+		return "", 0, fmt.Errorf("deploying the contract from %s failed: %v", sender.Name, err)
+	}
+	block, _ := hexToUint64(receipt.BlockNumber)
+	return receipt.ContractAddress, block, nil
+}
+
+// set stores the value in the contract and returns the transaction and the block it was mined in.
+func (t *smokeTest) set(sender smokeNode, address string, value uint64, privateFor []string) (string, uint64, error) {
+	txHash, err := sendTransaction(sender.Pod, t.namespace, rpcTx{From: sender.Account, To: address,
+		Data: "0x" + abiEncodeUint(new(big.Int).SetUint64(value)), Gas: smokeTxGas, PrivateFor: privateFor})
+	if err != nil {
+		return "", 0, err
+	}
+	receipt, err := waitForReceipt(sender.Pod, t.namespace, txHash, t.timeout)
+	if err != nil {
+		return txHash, 0, err
+	}
+	block, err := hexToUint64(receipt.BlockNumber)
+	return txHash, block, err
+}
+
+// waitForHeight waits for all the nodes to reach the height.
+func (t *smokeTest) waitForHeight(nodes []smokeNode, height uint64) error {
+	deadline := time.Now().Add(t.timeout)
+	for _, node := range nodes {
+		for {
+			blockNumber, err := nodeBlockNumber(node.Pod, t.namespace)
+			if err == nil && blockNumber >= height {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s did not reach block %d, at block %d %v", node.Name, height, blockNumber, err)
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	return nil
+}
+
+// smokeStoredValue reads slot 0 of the contract, quorum returns the private state for a private contract.
+func smokeStoredValue(node smokeNode, namespace, address string) (uint64, error) {
+	var stored string
+	if err := nodeRpcInto(node.Pod, namespace, &stored, "eth_getStorageAt", address, "0x0", "latest"); err != nil {
+		return 0, err
+	}
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(stored, "0x"), 16)
+	if !ok {
+		if stored == "0x" {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("invalid storage value [%s]", stored)
+	}
+	return value.Uint64(), nil
+}
+
+func smokeNodeNames(nodes []smokeNode) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSmokeReports(t *testing.T) {
+	checks := []smokeCheck{
+		{"passes", func(*smokeTest) error { return nil }},
+		{"fails", func(*smokeTest) error { return errors.New("quorum-node2: value [0] expected [42]") }},
+		{"skipped", func(*smokeTest) error { return smokeSkip("contract extension needs at least 3 nodes") }},
+	}
+	var tap bytes.Buffer
+	results := runSmokeChecks(&smokeTest{}, checks, "tap", &tap)
+	assertGolden(t, "smoke-tap", tap.String())
+
+	for i := range results {
+		results[i].Duration = time.Duration(i) * 1500 * time.Millisecond
+	}
+	var junit bytes.Buffer
+	if err := writeJUnitReport(&junit, "qctl smoke", results); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "smoke-junit", junit.String())
+}
@@ -76,7 +76,7 @@ var (
 			configFile := c.String("config")
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 
 			// if no --node flags were set, display all quorum services known from the config.
@@ -102,18 +102,26 @@ var (
 				if nodeNamesFlags.Contains("monitor") || len(nodeNames) == 0 {
 					allQuorumOtherK8sServices.Add("monitor")
 				}
+				if configFileYaml.Prometheus.Grafana && (nodeNamesFlags.Contains("grafana") || len(nodeNames) == 0) {
+					allQuorumOtherK8sServices.Add("grafana")
+				}
+				if len(configFileYaml.Prometheus.Alerting.Receivers) > 0 && (nodeNamesFlags.Contains("alertmanager") || len(nodeNames) == 0) {
+					allQuorumOtherK8sServices.Add("alertmanager")
+				}
 			}
 			if !isBare {
 				fmt.Println()
 			}
 			// TODO: optimize this so we get all the services with one kubectl call then filter through the results.
 			// display other quorum service first.
-			for _, service := range allQuorumOtherK8sServices.ToSlice() {
-				// need a string because ToSlice returns an []interface
-				serviceName := fmt.Sprintf("%v", service)
+			for _, serviceName := range sortedSetStrings(allQuorumOtherK8sServices) {
 				nodeServiceInfo := serviceInfoByPrefix(serviceName, urlType, namespace)
 				if strings.Contains(serviceName, "monitor") { // monitor only support nodeport
 					fmt.Println("prometheus server - " + nodeIp + ":" + nodeServiceInfo.NodePortPrometheus)
+				} else if strings.Contains(serviceName, "grafana") { // grafana only support nodeport
+					fmt.Println("grafana server    - " + nodeIp + ":" + nodeServiceInfo.NodePortGrafana)
+				} else if strings.Contains(serviceName, "alertmanager") { // alertmanager only support nodeport
+					fmt.Println("alertmanager      - " + nodeIp + ":" + nodeServiceInfo.NodePortAlertmanager)
 				} else if strings.Contains(serviceName, "cakeshop") { // cakeshop only support nodeport
 					fmt.Println("cakeshop server - " + nodeIp + ":" + nodeServiceInfo.NodePortCakeshop)
 				}
@@ -123,9 +131,7 @@ var (
 			}
 
 			// display all quorum node services.
-			for _, service := range allQuorumNodeK8sServices.ToSlice() {
-				// need a string because ToSlice returns an []interface
-				serviceName := fmt.Sprintf("%v", service)
+			for _, serviceName := range sortedSetStrings(allQuorumNodeK8sServices) {
 				nodeServiceInfo := serviceInfoByPrefix(serviceName, urlType, namespace)
 				if isBare {
 					if urlType == "nodeport" {
@@ -180,11 +186,13 @@ type NodeServiceInfo struct {
 	ClusterIPTmURL   string
 	//ClusterIPCakeshopURL string
 
-	NodePortGeth       string
-	NodePortTm         string
-	NodePortP2P        string
-	NodePortCakeshop   string
-	NodePortPrometheus string
+	NodePortGeth         string
+	NodePortTm           string
+	NodePortP2P          string
+	NodePortCakeshop     string
+	NodePortPrometheus   string
+	NodePortGrafana      string
+	NodePortAlertmanager string
 }
 
 func serviceInfoByPrefix(prefix, urlType, namespace string) NodeServiceInfo {
@@ -197,6 +205,10 @@ func serviceInfoByPrefix(prefix, urlType, namespace string) NodeServiceInfo {
 		if strings.Contains(serviceName, "monitor") { // only support nodeport
 			nodePortProm := nodePortFormClusterPort(srvOut, DefaultPrometheusClusterPort)
 			nodeServiceInfo.NodePortPrometheus = nodePortProm
+		} else if strings.Contains(serviceName, "grafana") { // only support nodeport
+			nodeServiceInfo.NodePortGrafana = nodePortFormClusterPort(srvOut, DefaultGrafanaClusterPort)
+		} else if strings.Contains(serviceName, "alertmanager") { // only support nodeport
+			nodeServiceInfo.NodePortAlertmanager = nodePortFormClusterPort(srvOut, DefaultAlertmanagerPort)
 		} else if strings.Contains(serviceName, "cakeshop") { // only support nodePort for now
 			nodePort := nodePortForService(srvOut)
 			nodeServiceInfo.NodePortCakeshop = nodePort
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the status of a single node, pod state from K8s, chain state from geth and the transaction manager state.
+type nodeStatus struct {
+	Name          string            `json:"name"`
+	Pod           string            `json:"pod"`
+	Phase         string            `json:"phase"`
+	Ready         string            `json:"ready"`
+	PodReady      bool              `json:"podReady"`
+	Restarts      int               `json:"restarts"`
+	Images        map[string]string `json:"images"`
+	BlockNumber   uint64            `json:"blockNumber"`
+	Peers         uint64            `json:"peers"`
+	Syncing       bool              `json:"syncing"`
+	ConsensusRole string            `json:"consensusRole"`
+	TmUp          bool              `json:"tmUp"`
+	TmPeers       int               `json:"tmPeers"`
+	GethReachable bool              `json:"gethReachable"`
+	Problems      []string          `json:"problems,omitempty"`
+}
+
+var (
+	// qctl status
+	// qctl status --output=json | jq '.[] | select(.syncing)'
+	networkStatusCommand = cli.Command{
+		Name:  "status",
+		Usage: "show the status of every node, pod, chain, consensus and transaction manager.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "output, o",
+				Usage: "output format: table | json",
+				Value: "table",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			output := c.String("output")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			statuses := getNodeStatuses(getNodeNames(configFileYaml), namespace, configFileYaml.Genesis.Consensus)
+			switch output {
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(statuses)
+			case "table":
+				displayNodeStatuses(statuses)
+			default:
+				return cli.Exit(fmt.Sprintf("invalid output [%s], must be table or json", output), 2)
+			}
+			return nil
+		},
+	}
+)
+
+// getNodeStatuses gets the status of all the nodes in parallel.
+func getNodeStatuses(nodeNames []string, namespace, consensus string) []nodeStatus {
+	statuses := make([]nodeStatus, len(nodeNames))
+	var wg sync.WaitGroup
+	for i, nodeName := range nodeNames {
+		wg.Add(1)
+		go func(i int, nodeName string) {
+			defer wg.Done()
+			statuses[i] = getNodeStatus(nodeName, namespace, consensus)
+		}(i, nodeName)
+	}
+	wg.Wait()
+	return statuses
+}
+
+func getNodeStatus(nodeName, namespace, consensus string) nodeStatus {
+	status := nodeStatus{Name: nodeName, Phase: "NotDeployed", Ready: "0/0", Images: map[string]string{}}
+	podName := podNameFromPrefix(nodeName, namespace)
+	pod, err := getPod(podName, namespace)
+	if err != nil {
+		status.Problems = append(status.Problems, "pod not found")
+		return status
+	}
+	status.Pod = pod.Metadata.Name
+	status.Phase = pod.Status.Phase
+	ready, total := pod.readyContainers()
+	status.Ready = fmt.Sprintf("%d/%d", ready, total)
+	for _, container := range pod.Spec.Containers {
+		status.Images[container.Name] = container.Image
+	}
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		status.Restarts += containerStatus.RestartCount
+	}
+	status.PodReady = pod.isReady()
+	if !status.PodReady {
+		status.Problems = append(status.Problems, "pod not ready")
+	}
+
+	status.BlockNumber, err = nodeBlockNumber(podName, namespace)
+	if err != nil {
+		status.Problems = append(status.Problems, "geth unreachable")
+	} else {
+		status.GethReachable = true
+		var peerCountHex string
+		if err := nodeRpcInto(podName, namespace, &peerCountHex, "net_peerCount"); err == nil {
+			status.Peers, _ = hexToUint64(peerCountHex)
+		}
+		// eth_syncing returns false, or the sync progress object while syncing.
+		if syncing, err := nodeRpc(podName, namespace, "eth_syncing"); err == nil {
+			status.Syncing = string(syncing) != "false"
+		}
+		status.ConsensusRole = nodeConsensusRole(podName, namespace, consensus)
+	}
+
+	status.TmUp = tmUpcheck(podName, namespace)
+	if status.TmUp {
+		if partyInfo, err := tmGetPartyInfo(podName, namespace); err == nil {
+			status.TmPeers = len(partyInfo.Peers)
+		}
+	} else {
+		status.Problems = append(status.Problems, "tm down")
+	}
+	return status
+}
+
+// nodeConsensusRole returns the raft role (minter, verifier, learner) of a raft node, or whether the node is
+// a validator / signer for istanbul and clique networks.
+func nodeConsensusRole(podName, namespace, consensus string) string {
+	switch consensus {
+	case RaftConsensus:
+		var role string
+		if err := nodeRpcInto(podName, namespace, &role, "raft_role"); err != nil {
+			return "unknown"
+		}
+		return role
+	case IstanbulConsensus, QibftConsensus:
+		var address string
+		var validators []string
+		if err := nodeRpcInto(podName, namespace, &address, "istanbul_nodeAddress"); err != nil {
+			return "unknown"
+		}
+		if err := nodeRpcInto(podName, namespace, &validators, "istanbul_getValidators"); err != nil {
+			return "unknown"
+		}
+		return validatorRole(address, validators)
+	case "clique":
+		var coinbase string
+		var signers []string
+		if err := nodeRpcInto(podName, namespace, &coinbase, "eth_coinbase"); err != nil {
+			return "unknown"
+		}
+		if err := nodeRpcInto(podName, namespace, &signers, "clique_getSigners"); err != nil {
+			return "unknown"
+		}
+		return validatorRole(coinbase, signers)
+	}
+	return "unknown"
+}
+
+func validatorRole(address string, validators []string) string {
+	for _, validator := range validators {
+		if strings.EqualFold(validator, address) {
+			return "validator"
+		}
+	}
+	return "non-validator"
+}
+
+func displayNodeStatuses(statuses []nodeStatus) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  NODE\tPHASE\tREADY\tRESTARTS\tBLOCK\tPEERS\tSYNCING\tROLE\tTM\tTM PEERS\tIMAGES")
+	for _, s := range statuses {
+		block, peers, syncing, role := "-", "-", "-", "-"
+		if s.GethReachable {
+			block = fmt.Sprintf("%d", s.BlockNumber)
+			peers = fmt.Sprintf("%d", s.Peers)
+			syncing = fmt.Sprintf("%t", s.Syncing)
+			role = s.ConsensusRole
+		}
+		tm, tmPeers := "down", "-"
+		if s.TmUp {
+			tm = "up"
+			tmPeers = fmt.Sprintf("%d", s.TmPeers)
+		}
+		var images []string
+		for _, name := range []string{"quorum", DefaultTmName} {
+			if image, ok := s.Images[name]; ok {
+				images = append(images, image)
+			}
+		}
+		fmt.Fprintln(w, fmt.Sprintf("  %s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s", s.Name, s.Phase, s.Ready,
+			s.Restarts, block, peers, syncing, role, tm, tmPeers, strings.Join(images, ", ")))
+	}
+	w.Flush()
+	fmt.Println()
+	for _, s := range statuses {
+		if len(s.Problems) > 0 {
+			red.Println(fmt.Sprintf("  [%s] %s", s.Name, strings.Join(s.Problems, ", ")))
+		}
+	}
+	fmt.Println()
+}
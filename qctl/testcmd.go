@@ -3,8 +3,11 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -78,7 +81,7 @@ var (
 		Name:    "accepttest",
 		Usage:   "output the acceptance test config file",
 		Aliases: []string{"ac"},
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:     "config, c",
 				Usage:    "Load configuration from `FULL_PATH_FILE`",
@@ -96,7 +99,7 @@ var (
 				Usage: "the IP of the K8s node, e.g. minikube ip ",
 				Value: "K8S_NODE_IP",
 			},
-		},
+		}, acceptanceTestFlags()...),
 		Action: func(c *cli.Context) error {
 
 			k8sNodeIp := c.String("node-ip")
@@ -140,10 +143,22 @@ var (
 
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			options := acceptanceTestOptionsFromFlags(c, k8sNodeIp)
+			acceptanceTestConfig, err := createAcceptanceTestConfig(configFileYaml, options)
+			if err != nil {
+				return cli.Exit(err.Error(), 3)
 			}
-			acceptanceTestYaml := createAcceptanceTestConfigString(configFileYaml, k8sNodeIp)
+			acceptanceTestYaml := acceptanceTestConfig.ToString()
 			fmt.Println(acceptanceTestYaml)
+			if options.UrlType == AcceptanceUrlPortForward {
+				nodeNames, _ := acceptanceTestNodes(configFileYaml, options.Nodes)
+				green.Println("  the nodes are reached through the port-forwards:")
+				for _, cmd := range acceptanceTestPortForwards(nodeNames, options) {
+					fmt.Println("  " + cmd.String())
+				}
+			}
 			//fmt.Println(config.ToString())
 			configBytes := []byte(acceptanceTestYaml)
 			acceptanceTestYamlFile := k8sdir + "/config/application-qctl-generated.yml"
@@ -151,7 +166,7 @@ var (
 			// TODO: it might be best to store in K8s itself
 			err = ioutil.WriteFile(acceptanceTestYamlFile, configBytes, 0644)
 			if err != nil {
-				log.Fatal("error writing acceptanceTestYamlFil to [%v]. err: [%v]", acceptanceTestYamlFile, err)
+				log.Fatalf("error writing acceptanceTestYamlFil to [%v]. err: [%v]", acceptanceTestYamlFile, err)
 			}
 			return nil
 		},
@@ -164,7 +179,7 @@ var (
 		Usage:   "run the acceptance tests.",
 		// TODO: pass in tags, and the config file, will also need the k8s ip...unless
 		// additionally pass in the file, and the tags, then no additional config needed
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:     "config, c",
 				Usage:    "Load configuration from `FULL_PATH_FILE`",
@@ -178,17 +193,24 @@ var (
 				Required: true,
 			},
 			&cli.StringFlag{
-				Name:     "node-ip",
-				Usage:    "the IP of the K8s node, e.g. minikube ip ",
-				Value:    "K8S_NODE_IP",
-				Required: true,
+				Name:  "node-ip",
+				Usage: "the IP of the K8s node, e.g. minikube ip, required for the nodeport and ingress url types.",
 			},
 			&cli.StringFlag{
 				Name:    "tags",
 				Aliases: []string{"t", "tag"},
 				Usage:   "tags indicating which test to run, if not set, defaults to: (basic || basic-{CONSENSUS} || networks/typical::{CONSENSUS}) && !extension ",
 			},
-		},
+			&cli.StringFlag{
+				Name:  "reports",
+				Usage: "the local dir the surefire / JUnit reports are copied to from the test container, defaults to k8sdir/acceptance-test-reports",
+			},
+			&cli.StringFlag{
+				Name:  "container-reports",
+				Usage: "the dir of the reports in the test container.",
+				Value: "/workspace/target/surefire-reports",
+			},
+		}, acceptanceTestFlags()...),
 		Action: func(c *cli.Context) error {
 
 			k8sdir := c.String("k8sdir")
@@ -214,20 +236,27 @@ var (
 			// end V1
 			configFileYaml, err := LoadYamlConfig(configFile)
 			if err != nil {
-				log.Fatal("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
 			}
 
 			// acceptance test file must be prefixed with application, e.g. `application-$MYNAME.yaml`
 
 			// if an acceptance test config file wasn't provided, create one against the running network now.
-			acceptanceTestYaml := createAcceptanceTestConfigString(configFileYaml, k8sNodeIp)
-			configBytes := []byte(acceptanceTestYaml)
+			options := acceptanceTestOptionsFromFlags(c, k8sNodeIp)
+			if k8sNodeIp == "" && (options.UrlType == AcceptanceUrlNodePort || options.UrlType == AcceptanceUrlIngress) {
+				return cli.Exit(fmt.Sprintf("--node-ip must be set for the [%s] url type", options.UrlType), 2)
+			}
+			acceptanceTestConfig, err := createAcceptanceTestConfig(configFileYaml, options)
+			if err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			configBytes := []byte(acceptanceTestConfig.ToString())
 			// try writing the generated file out to disk this file will be used to initialize the network.
 			// TODO: it might be best to store in K8s itself
 			acceptanceTestYamlFile := k8sdir + "/config/application-qctl-generated.yml"
 			err = ioutil.WriteFile(acceptanceTestYamlFile, configBytes, 0644)
 			if err != nil {
-				log.Fatal("error writing acceptanceTestYamlFil to [%v]. err: [%v]", acceptanceTestYamlFile, err)
+				log.Fatalf("error writing acceptanceTestYamlFil to [%v]. err: [%v]", acceptanceTestYamlFile, err)
 			}
 
 			acceptanceTestProfile := "qctl-generated"
@@ -241,18 +270,48 @@ var (
 				}
 			}
 			green.Println("using profile file: " + acceptanceTestYamlFile)
+			dockerArgs := []string{"run", "--name", acceptanceTestContainer, "-v", k8sdir + "/config:/tmp/config"}
+			if options.UrlType == AcceptanceUrlPortForward {
+				// the port-forwards listen on the host's localhost.
+				dockerArgs = append(dockerArgs, "--network=host")
+				nodeNames, _ := acceptanceTestNodes(configFileYaml, options.Nodes)
+				var localPorts []int
+				for i := range nodeNames {
+					gethPort, tmPort := acceptanceTestLocalPorts(options.LocalPort, i)
+					localPorts = append(localPorts, gethPort, tmPort)
+				}
+				stopPortForwards, err := startPortForwards(acceptanceTestPortForwards(nodeNames, options), localPorts)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("unable to port-forward to the nodes: %v", err), 3)
+				}
+				defer stopPortForwards()
+			}
+			// the container is removed after the reports are copied out of it.
+			runner.Run(exec.Command("docker", "rm", "-f", acceptanceTestContainer))
+			dockerArgs = append(dockerArgs, "-e", "SPRING_CONFIG_ADDITIONALLOCATION=file:/tmp/config/", "-e", "SPRING_PROFILES_ACTIVE="+acceptanceTestProfile, "quorumengineering/acctests:latest", "test", "-Dtags="+tags)
 			// if debugging include -X for mvn output
-			cmd := exec.Command("docker", "run", "--rm", "-v", k8sdir+"/config:/tmp/config", "-e", "SPRING_CONFIG_ADDITIONALLOCATION=file:/tmp/config/", "-e", "SPRING_PROFILES_ACTIVE="+acceptanceTestProfile, "quorumengineering/acctests:latest", "test", "-Dtags="+tags)
+			cmd := exec.Command("docker", dockerArgs...)
 			// e.g. docker run --rm -v /Users/libby/Workspace.Quorum/qctl-config/out/config:/tmp/config -e SPRING_CONFIG_ADDITIONALLOCATION=file:/tmp/config/ -e SPRING_PROFILES_ACTIVE=qctl-generated quorumengineering/acctests:latest test -Dtags='(basic || basic-istanbul || networks/typical::istanbul) && !extension'
 			fmt.Println(cmd)
 			err = dropIntoCmd(cmd)
+
+			reportsDir := c.String("reports")
+			if reportsDir == "" {
+				reportsDir = k8sdir + "/acceptance-test-reports"
+			}
+			if reportErr := copyAcceptanceTestReports(c.String("container-reports"), reportsDir); reportErr != nil {
+				red.Println(fmt.Sprintf("  unable to copy the test reports from the container: %v", reportErr))
+			} else {
+				green.Println("  test reports copied to: " + reportsDir)
+			}
+			runner.Run(exec.Command("docker", "rm", "-f", acceptanceTestContainer))
 			if err != nil {
 				fmt.Println()
 				red.Println(fmt.Sprintf("Error running trying to run acceptance test via the quorumengineering/acctests container ."))
 				red.Println(fmt.Sprintf("Command that failed:"))
 				red.Println(fmt.Sprintf(cmd.String()))
 
-				red.Println(fmt.Sprintf("Is Docker running on your machine? [%v]", err))
+				red.Println(fmt.Sprintf("Is Docker running on your machine, did the tests fail? [%v]", err))
 				fmt.Println()
 				return cli.Exit(fmt.Sprintf("Docker must be running on host, cmd failed \n %v", cmd.String()), 3)
 			}
@@ -261,61 +320,191 @@ var (
 	}
 )
 
-// acceptance test expects a config file with node names labelled in sequential order, Node1, Node2:
-//quorum:
-//nodes:
-//Node1:
-//	privacy-address: WC6yWjDXG9uFQTTfV+bkTr5GbqjmH7DotcOYqeSajgs=
-//		url: http://192.168.64.49:32507
-//	third-party-url: http://192.168.64.49:31375
-//Node2:
-//	privacy-address: t+lu+T9VoTWfQMyF7wiFsiEKARaQqgvOJynNfrsSbAk=
-//		url: http://192.168.64.49:32106
-//	third-party-url: http://192.168.64.49:30968
+// the name of the acceptance test container, it is kept after the run to copy the reports.
+const acceptanceTestContainer = "qctl-acceptance-tests"
+
+// the flags selecting how the acceptance tests reach the nodes.
+func acceptanceTestFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "the urls the tests use to reach the nodes: nodeport | clusterip | port-forward | ingress",
+			Value: AcceptanceUrlNodePort,
+		},
+		&cli.StringSliceFlag{
+			Name:  "nodes",
+			Usage: "the config nodes used as Node1..NodeN in the tests, in order, comma separated or repeated, defaults to all the nodes.",
+		},
+		&cli.IntFlag{
+			Name:  "local-port",
+			Usage: "the first local port of the port-forwards, each node uses two ports (geth, tessera).",
+			Value: 22000,
+		},
+	}
+}
+
+func acceptanceTestOptionsFromFlags(c *cli.Context, k8sNodeIp string) acceptanceTestOptions {
+	return acceptanceTestOptions{Namespace: c.String("namespace"), UrlType: strings.ToLower(c.String("type")),
+		NodeIp: k8sNodeIp, Nodes: splitFlagValues(c.StringSlice("nodes")), LocalPort: c.Int("local-port")}
+}
+
+// startPortForwards starts the port-forwards in the background and waits for their local ports to answer. The
+// returned func stops them.
+func startPortForwards(cmds []*exec.Cmd, localPorts []int) (func(), error) {
+	var stops []func()
+	stopAll := func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+	for _, cmd := range cmds {
+		fmt.Println(cmd.String())
+		stop, err := runner.Start(cmd)
+		if err != nil {
+			stopAll()
+			return nil, err
+		}
+		stops = append(stops, stop)
+	}
+	if err := waitForLocalPorts(localPorts, portForwardTimeout); err != nil {
+		stopAll()
+		return nil, err
+	}
+	return stopAll, nil
+}
+
+// how long to wait for the port-forwards to listen.
+const portForwardTimeout = 30 * time.Second
+
+// waitForLocalPorts waits for the local ports to accept connections, kubectl port-forward listens on localhost.
+func waitForLocalPorts(ports []int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, port := range ports {
+		address := fmt.Sprintf("localhost:%d", port)
+		for {
+			conn, err := net.DialTimeout("tcp", address, time.Second)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("[%s] did not answer within [%v]: %v", address, timeout, err)
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// copyAcceptanceTestReports copies the surefire / JUnit reports out of the acceptance test container.
+func copyAcceptanceTestReports(containerDir, reportsDir string) error {
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return err
+	}
+	// docker cp dir/. copies the content of the dir, not the dir itself.
+	cmd := exec.Command("docker", "cp", acceptanceTestContainer+":"+strings.TrimSuffix(containerDir, "/")+"/.", reportsDir)
+	fmt.Println(cmd.String())
+	return runCmdQuiet(cmd)
+}
+
+// acceptanceTestOptions select how the acceptance tests reach the nodes.
+type acceptanceTestOptions struct {
+	Namespace string
+	UrlType   string   // nodeport | clusterip | port-forward | ingress
+	NodeIp    string   // the K8s node IP for nodeport, and the ingress host if the ingress has no host set.
+	Nodes     []string // the config nodes used as Node1..NodeN, all the config nodes if empty.
+	LocalPort int      // the first local port of the port-forwards, two ports (geth, tm) per node.
+}
+
+const (
+	AcceptanceUrlNodePort    = "nodeport"
+	AcceptanceUrlClusterIp   = "clusterip"
+	AcceptanceUrlPortForward = "port-forward"
+	AcceptanceUrlIngress     = "ingress"
+)
+
+// the acceptance test config maps the nodes to Node1..NodeN, see ATNodes:
 //
-// TODO: can we update the format to use unique names, and yaml lists.
-//quorum:
-//  nodes:
-//  - name: quorum-node1
-//    privacy-address: WC6yWjDXG9uFQTTfV+bkTr5GbqjmH7DotcOYqeSajgs=
-//    url: http://192.168.64.49:32507
-//    third-party-url: http://192.168.64.49:31375
-//  - name: quorum-node2
-//    privacy-address: t+lu+T9VoTWfQMyF7wiFsiEKARaQqgvOJynNfrsSbAk=
-//    url: http://192.168.64.49:32106
-//    third-party-url: http://192.168.64.49:30968
-func createAcceptanceTestConfigString(configFileYaml QConfig, k8sNodeIp string) string {
+//	quorum:
+//	  nodes:
+//	    Node1:
+//	      privacy-address: WC6yWjDXG9uFQTTfV+bkTr5GbqjmH7DotcOYqeSajgs=
+//	      url: http://192.168.64.49:32507
+//	      third-party-url: http://192.168.64.49:31375
+func createAcceptanceTestConfig(configFileYaml QConfig, options acceptanceTestOptions) (AcceptTestConfig, error) {
 	acceptanceTestYaml := AcceptTestConfig{}
-	for _, node := range configFileYaml.Nodes {
-		// get nodes from config, and set the necessary tm public key, geth and tessera urls, using NodePort.
-		serviceNodePort := serviceInfoByPrefix(node.NodeUserIdent, ServiceTypeNodePort, "")
-		tmPublicKey := getTmPublicKey(node.NodeUserIdent)
-		nodeEntry := ATNodeEntry{}
-		nodeEntry.GethURL = "http://" + k8sNodeIp + ":" + serviceNodePort.NodePortGeth
-		nodeEntry.TmPublicKey = tmPublicKey
-		nodeEntry.TmURL = "http://" + k8sNodeIp + ":" + serviceNodePort.NodePortTm
-
+	nodeNames, err := acceptanceTestNodes(configFileYaml, options.Nodes)
+	if err != nil {
+		return acceptanceTestYaml, err
+	}
+	ingress := configFileYaml.K8s.Service.Ingress
+	for i, nodeName := range nodeNames {
+		nodeEntry := ATNodeEntry{Name: fmt.Sprintf("Node%d", i+1)}
+		nodeEntry.TmPublicKey, err = getTmPublicKey(nodeName, options.Namespace)
+		if err != nil || nodeEntry.TmPublicKey == "" {
+			return acceptanceTestYaml, fmt.Errorf("unable to get the tessera public key of node [%s]: %v", nodeName, err)
+		}
+		switch options.UrlType {
+		case AcceptanceUrlNodePort:
+			serviceInfo := serviceInfoByPrefix(nodeName, ServiceTypeNodePort, options.Namespace)
+			nodeEntry.GethURL = "http://" + options.NodeIp + ":" + serviceInfo.NodePortGeth
+			nodeEntry.TmURL = "http://" + options.NodeIp + ":" + serviceInfo.NodePortTm
+		case AcceptanceUrlClusterIp:
+			serviceInfo := serviceInfoByPrefix(nodeName, ServiceTypeClusterIP, options.Namespace)
+			nodeEntry.GethURL = "http://" + serviceInfo.ClusterIPGethURL
+			nodeEntry.TmURL = "http://" + serviceInfo.ClusterIPTmURL
+		case AcceptanceUrlPortForward:
+			gethPort, tmPort := acceptanceTestLocalPorts(options.LocalPort, i)
+			nodeEntry.GethURL = fmt.Sprintf("http://localhost:%d", gethPort)
+			nodeEntry.TmURL = fmt.Sprintf("http://localhost:%d", tmPort)
+		case AcceptanceUrlIngress:
+			// the ingress only routes geth (templates/k8s/quorum-ingress.yaml.erb), tessera is reached on its NodePort.
+			switch {
+			case ingress.Strategy == "OneToMany" && ingress.Host != "":
+				nodeEntry.GethURL = "https://" + ingress.Host + "/" + nodeName + "/quorum-rpc"
+			case ingress.Strategy == "OneToMany":
+				nodeEntry.GethURL = "http://" + options.NodeIp + "/" + nodeName + "/quorum-rpc"
+			case ingress.Strategy == "OneToOne" && ingress.Host != "":
+				nodeEntry.GethURL = "https://" + nodeName + "." + ingress.Host + "/quorum-rpc"
+			default:
+				return acceptanceTestYaml, fmt.Errorf("the ingress [%s] has no per node url, set K8s.service.Ingress.Host or use Strategy OneToMany", ingress.Strategy)
+			}
+			serviceInfo := serviceInfoByPrefix(nodeName, ServiceTypeNodePort, options.Namespace)
+			nodeEntry.TmURL = "http://" + options.NodeIp + ":" + serviceInfo.NodePortTm
+		default:
+			return acceptanceTestYaml, fmt.Errorf("invalid url type [%s], must be nodeport, clusterip, port-forward or ingress", options.UrlType)
+		}
 		acceptanceTestYaml.Quorum.Nodes = append(acceptanceTestYaml.Quorum.Nodes, nodeEntry)
 	}
-	// TODO this is really hideous, better to change the acceptance test to take a yaml file that has a list of node with a name attributes:
-	//quorum:
-	//  nodes:
-	//  - name: node1
-	//    privacy-address: WC6yWjDXG9uFQTTfV+bkTr5GbqjmH7DotcOYqeSajgs=
-	//    url: http://192.168.64.49:32507
-	//    third-party-url: http://192.168.64.49:31375
-	//  - name: node2
-	//    privacy-address: t+lu+T9VoTWfQMyF7wiFsiEKARaQqgvOJynNfrsSbAk=
-	//    url: http://192.168.64.49:32106
-	//    third-party-url: http://192.168.64.49:30968
-	acceptanceTestUpdatedYaml := acceptanceTestYaml.ToString()
-	// since node name must be in the for form Node(i+1)
-	for i := 0; i < len(configFileYaml.Nodes); i++ {
-		//acceptanceTestUpdatedYaml = strings.Replace(acceptanceTestUpdatedYaml, "- privacy-address:", "  " + node.NodeUserIdent + ": \n      privacy-address:", 1)
-		nodeName := fmt.Sprintf("Node%d", i+1)
-		acceptanceTestUpdatedYaml = strings.Replace(acceptanceTestUpdatedYaml, "- privacy-address:", "  "+nodeName+": \n      privacy-address:", 1)
+	return acceptanceTestYaml, nil
+}
+
+// acceptanceTestNodes returns the config nodes to use as Node1..NodeN, in the order given.
+func acceptanceTestNodes(configFileYaml QConfig, selected []string) ([]string, error) {
+	nodeNames := getNodeNames(configFileYaml)
+	if len(selected) == 0 {
+		return nodeNames, nil
+	}
+	for _, nodeName := range selected {
+		if !containsString(nodeNames, nodeName) {
+			return nil, fmt.Errorf("node [%s] is not in the config file", nodeName)
+		}
+	}
+	return selected, nil
+}
+
+// acceptanceTestLocalPorts returns the local geth and tm ports of the i'th node's port-forward.
+func acceptanceTestLocalPorts(firstPort, i int) (int, int) {
+	return firstPort + 2*i, firstPort + 2*i + 1
+}
+
+// acceptanceTestPortForwards returns the kubectl port-forward commands for the acceptance test nodes.
+func acceptanceTestPortForwards(nodeNames []string, options acceptanceTestOptions) []*exec.Cmd {
+	var cmds []*exec.Cmd
+	for i, nodeName := range nodeNames {
+		gethPort, tmPort := acceptanceTestLocalPorts(options.LocalPort, i)
+		cmds = append(cmds, exec.Command("kubectl", "--namespace="+options.Namespace, "port-forward", "service/"+nodeName,
+			fmt.Sprintf("%d:%s", gethPort, DefaultGethPort), fmt.Sprintf("%d:%s", tmPort, DefaultTesseraPort)))
 	}
-	acceptanceTestUpdatedYaml = strings.ReplaceAll(acceptanceTestUpdatedYaml, " url:", "   url:")
-	acceptanceTestUpdatedYaml = strings.ReplaceAll(acceptanceTestUpdatedYaml, "third-party-url:", "  third-party-url:")
-	return acceptanceTestUpdatedYaml
+	return cmds
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// helpers for talking to the transaction manager (tessera) running next to the quorum container.
+// Like the JSON-RPC helpers (see rpc.go) the requests are run from inside the quorum container, the containers
+// share the pod network so tessera's p2p port is reachable on localhost.
+
+// the result of GET /partyinfo
+type tmPartyInfo struct {
+	Url   string `json:"url"`
+	Peers []struct {
+		Url         string `json:"url"`
+		LastContact string `json:"lastContact"`
+	} `json:"peers"`
+	Keys []struct {
+		Key string `json:"key"`
+		Url string `json:"url"`
+	} `json:"keys"`
+}
+
+// tmGet runs a GET request against tessera's p2p API on the given pod.
+func tmGet(podName, namespace, path string) ([]byte, error) {
+	if podName == "" {
+		return nil, errors.New("no running pod to send the request to")
+	}
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "exec", podName, "-c", "quorum", "--",
+		"curl", "-s", "-f", "http://localhost:"+DefaultTesseraPort+path)
+	out, err := runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("tessera [%s] on pod [%s] failed: %v", path, podName, err)
+	}
+	return out.Bytes(), nil
+}
+
+// tmQ2TGet runs a GET request against tessera's Q2T API (the API quorum uses) over the unix socket shared with the
+// quorum container, see PRIVATE_CONFIG in templates/k8s/quorum-deployment.yaml.erb
+func tmQ2TGet(podName, namespace, path string) ([]byte, error) {
+	if podName == "" {
+		return nil, errors.New("no running pod to send the request to")
+	}
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "exec", podName, "-c", "quorum", "--",
+		"sh", "-c", "curl -s -f --unix-socket $PRIVATE_CONFIG 'http://localhost"+path+"'")
+	out, err := runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("tessera [%s] on pod [%s] failed: %v", path, podName, err)
+	}
+	return out.Bytes(), nil
+}
+
+// tmUpcheck returns true if tessera on the pod responds to the upcheck.
+func tmUpcheck(podName, namespace string) bool {
+	res, err := tmGet(podName, namespace, "/upcheck")
+	return err == nil && strings.Contains(string(res), "up")
+}
+
+func tmGetPartyInfo(podName, namespace string) (tmPartyInfo, error) {
+	var partyInfo tmPartyInfo
+	res, err := tmGet(podName, namespace, "/partyinfo")
+	if err != nil {
+		return partyInfo, err
+	}
+	err = json.Unmarshal(res, &partyInfo)
+	return partyInfo, err
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// what a transaction manager node reports, and what the other nodes expect it to be reachable on.
+type tmNodeView struct {
+	Name      string
+	Tm        string // tessera | constellation, empty for external nodes.
+	External  bool
+	Url       string
+	ConfigUrl string // the url the generated tessera config gives the node, see quorum-deployment.yaml.erb
+	PublicKey string
+	Up        bool
+	PartyInfo *tmPartyInfo
+	Err       error
+}
+
+var (
+	// qctl tm check
+	// qctl tm check --stale=10m
+	tmCheckCommand = cli.Command{
+		Name:  "check",
+		Usage: "check every transaction manager knows the url and public key of every other node, exits non-zero on problems.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.DurationFlag{
+				Name:  "stale",
+				Usage: "a peer that has not been contacted for longer than this is reported as stale.",
+				Value: 5 * time.Minute,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			views := tmNodeViews(configFileYaml, namespace)
+			displayTmNodeViews(views)
+			problems := checkTmNodeViews(views, c.Duration("stale"), time.Now())
+			if len(problems) > 0 {
+				for _, problem := range problems {
+					red.Println("  " + problem)
+				}
+				fmt.Println()
+				return cli.Exit(fmt.Sprintf("%d transaction manager problem(s) found.", len(problems)), 1)
+			}
+			green.Println("  All transaction managers are up and know every other node.")
+			fmt.Println()
+			return nil
+		},
+	}
+)
+
+// tmNodeViews gets the upcheck, partyinfo and public key of every node, external nodes only have the url from the config.
+func tmNodeViews(configFileYaml QConfig, namespace string) []tmNodeView {
+	var views []tmNodeView
+	for _, node := range configFileYaml.Nodes {
+		view := tmNodeView{Name: node.NodeUserIdent, Tm: node.QuorumEntry.Tm.Name}
+		if view.Tm == DefaultTmName {
+			view.ConfigUrl = "http://" + node.NodeUserIdent + ":" + DefaultTesseraPort
+		}
+		podName := podNameFromPrefix(node.NodeUserIdent, namespace)
+		view.PublicKey, _ = getTmPublicKey(node.NodeUserIdent, namespace)
+		view.Up = tmUpcheck(podName, namespace)
+		// constellation only serves its partyinfo in its own binary format.
+		if view.Up && view.Tm != "constellation" {
+			partyInfo, err := tmGetPartyInfo(podName, namespace)
+			if err != nil {
+				view.Err = err
+			} else {
+				view.PartyInfo = &partyInfo
+				view.Url = partyInfo.Url
+			}
+		}
+		views = append(views, view)
+	}
+	for _, externalNode := range configFileYaml.ExternalNodes {
+		views = append(views, tmNodeView{Name: externalNode.NodeUserIdent, External: true, Url: externalNode.TmUrl})
+	}
+	return views
+}
+
+// checkTmNodeViews returns the problems found, each tessera node should have every other node's url in its peers
+// and every other node's public key mapped to that url.
+func checkTmNodeViews(views []tmNodeView, stale time.Duration, now time.Time) []string {
+	var problems []string
+	var tesseraNodes, constellationNodes []string
+	nameByUrl := map[string]string{}
+	// the nodes that are down are reported once as down, not as a stale peer of every other node.
+	downByUrl := map[string]string{}
+	for _, view := range views {
+		if view.Url != "" {
+			nameByUrl[normalizeTmUrl(view.Url)] = view.Name
+		}
+		if !view.External && !view.Up && view.ConfigUrl != "" {
+			downByUrl[normalizeTmUrl(view.ConfigUrl)] = view.Name
+		}
+		switch view.Tm {
+		case "constellation":
+			constellationNodes = append(constellationNodes, view.Name)
+		case "tessera":
+			tesseraNodes = append(tesseraNodes, view.Name)
+		}
+	}
+	if len(constellationNodes) > 0 && len(tesseraNodes) > 0 {
+		problems = append(problems, fmt.Sprintf("constellation nodes %v and tessera nodes %v cannot exchange private transactions.",
+			constellationNodes, tesseraNodes))
+	}
+
+	// knows[a][b] is true if a has b's url in its peers.
+	knows := map[string]map[string]bool{}
+	for _, view := range views {
+		if view.External {
+			continue
+		}
+		if !view.Up {
+			problems = append(problems, fmt.Sprintf("[%s] %s is down, the upcheck failed.", view.Name, view.Tm))
+			continue
+		}
+		if view.Err != nil {
+			problems = append(problems, fmt.Sprintf("[%s] unable to get the partyinfo: %v", view.Name, view.Err))
+			continue
+		}
+		if view.PartyInfo == nil {
+			continue
+		}
+		knows[view.Name] = map[string]bool{}
+		for _, peer := range view.PartyInfo.Peers {
+			peerUrl := normalizeTmUrl(peer.Url)
+			if peerUrl == normalizeTmUrl(view.Url) {
+				continue
+			}
+			if _, down := downByUrl[peerUrl]; down {
+				continue
+			}
+			peerName, found := nameByUrl[peerUrl]
+			if !found {
+				problems = append(problems, fmt.Sprintf("[%s] has a stale peer [%s] that is not a node in the config.", view.Name, peer.Url))
+				continue
+			}
+			knows[view.Name][peerName] = true
+			if lastContact, err := time.Parse(time.RFC3339Nano, peer.LastContact); err == nil && now.Sub(lastContact) > stale {
+				problems = append(problems, fmt.Sprintf("[%s] has not contacted [%s] since [%s].", view.Name, peerName, peer.LastContact))
+			}
+		}
+		keyUrls := map[string]string{}
+		for _, key := range view.PartyInfo.Keys {
+			keyUrls[key.Key] = normalizeTmUrl(key.Url)
+		}
+		for _, other := range views {
+			if other.Name == view.Name || other.PublicKey == "" || other.Url == "" {
+				continue
+			}
+			keyUrl, found := keyUrls[other.PublicKey]
+			if !found {
+				problems = append(problems, fmt.Sprintf("[%s] does not know the public key of [%s].", view.Name, other.Name))
+			} else if keyUrl != normalizeTmUrl(other.Url) {
+				problems = append(problems, fmt.Sprintf("[%s] has a stale url [%s] for the public key of [%s], expected [%s].",
+					view.Name, keyUrl, other.Name, other.Url))
+			}
+		}
+	}
+
+	for i, a := range views {
+		for _, b := range views[i+1:] {
+			if a.Url == "" || b.Url == "" || (a.External && b.External) {
+				continue
+			}
+			aKnowsB, bKnowsA := knows[a.Name][b.Name], knows[b.Name][a.Name]
+			_, aChecked := knows[a.Name]
+			_, bChecked := knows[b.Name]
+			switch {
+			case aChecked && bChecked && !aKnowsB && !bKnowsA:
+				problems = append(problems, fmt.Sprintf("[%s] and [%s] do not know each other.", a.Name, b.Name))
+			case aChecked && bChecked && aKnowsB != bKnowsA:
+				knower, other := a.Name, b.Name
+				if bKnowsA {
+					knower, other = b.Name, a.Name
+				}
+				problems = append(problems, fmt.Sprintf("asymmetric peers: [%s] knows [%s] but [%s] does not know [%s].",
+					knower, other, other, knower))
+			case aChecked && !aKnowsB:
+				problems = append(problems, fmt.Sprintf("[%s] does not know [%s] at [%s].", a.Name, b.Name, b.Url))
+			case bChecked && !bKnowsA:
+				problems = append(problems, fmt.Sprintf("[%s] does not know [%s] at [%s].", b.Name, a.Name, a.Url))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func displayTmNodeViews(views []tmNodeView) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  NODE\tTM\tUP\tURL\tPEERS\tKEYS")
+	for _, view := range views {
+		tm, up, peers, keys := view.Tm, fmt.Sprintf("%t", view.Up), "-", "-"
+		if view.External {
+			tm, up = "external", "-"
+		}
+		if view.PartyInfo != nil {
+			peers = fmt.Sprintf("%d", len(view.PartyInfo.Peers))
+			keys = fmt.Sprintf("%d", len(view.PartyInfo.Keys))
+		}
+		tmUrl := view.Url
+		if tmUrl == "" {
+			tmUrl = "-"
+		}
+		fmt.Fprintln(w, fmt.Sprintf("  %s\t%s\t%s\t%s\t%s\t%s", view.Name, tm, up, strings.TrimSuffix(tmUrl, "/"), peers, keys))
+	}
+	w.Flush()
+	fmt.Println()
+}
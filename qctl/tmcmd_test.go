@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckTmNodeViewsNodeDown(t *testing.T) {
+	var partyInfo tmPartyInfo
+	err := json.Unmarshal([]byte(`{"url": "http://quorum-node1:9001/",
+		"peers": [{"url": "http://quorum-node1:9001/"}, {"url": "http://quorum-node2:9001/"}, {"url": "http://10.0.0.9:9001/"}]}`),
+		&partyInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	views := []tmNodeView{
+		{Name: "quorum-node1", Tm: "tessera", Url: partyInfo.Url, ConfigUrl: "http://quorum-node1:9001", Up: true, PartyInfo: &partyInfo},
+		{Name: "quorum-node2", Tm: "tessera", ConfigUrl: "http://quorum-node2:9001"},
+	}
+	// quorum-node2 is reported as down, not as a stale peer of quorum-node1.
+	expected := []string{
+		"[quorum-node1] has a stale peer [http://10.0.0.9:9001/] that is not a node in the config.",
+		"[quorum-node2] tessera is down, the upcheck failed.",
+	}
+	if problems := checkTmNodeViews(views, 5*time.Minute, time.Now()); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("checkTmNodeViews = %q, expected %q", problems, expected)
+	}
+}
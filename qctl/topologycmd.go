@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// a node in the topology, internal nodes are the nodes in the config running in K8s.
+type topologyNode struct {
+	Name     string `json:"name"`
+	Enode    string `json:"enode,omitempty"` // the node id (public key) part of the enode url.
+	TmUrl    string `json:"tmUrl,omitempty"`
+	External bool   `json:"external"`
+	Unknown  bool   `json:"unknown,omitempty"` // a peer that is not in the config.
+}
+
+// an edge between two nodes on the p2p (geth) or tessera layer. p2p edges point from the node that dialed the
+// connection, tessera edges point from the node that knows the other node through its partyinfo.
+type topologyEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Layer     string `json:"layer"`     // p2p | tessera
+	Direction string `json:"direction"` // outbound | known
+	Missing   bool   `json:"missing"`   // expected from the permissioned nodes / config but not connected.
+}
+
+type networkTopology struct {
+	Nodes []topologyNode `json:"nodes"`
+	Edges []topologyEdge `json:"edges"`
+}
+
+// the subset of admin_peers used to build the p2p graph.
+type rpcPeer struct {
+	Enode   string `json:"enode"`
+	Id      string `json:"id"`
+	Network struct {
+		Inbound bool `json:"inbound"`
+	} `json:"network"`
+}
+
+var (
+	// qctl topology --format=dot | dot -Tpng > topology.png
+	// qctl topology --format=mermaid --layer=p2p
+	topologyCommand = cli.Command{
+		Name:  "topology",
+		Usage: "export the p2p and tessera peer graph of the network, marking expected connections that are missing.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "dot | json | mermaid",
+				Value: "dot",
+			},
+			&cli.StringFlag{
+				Name:  "layer",
+				Usage: "p2p | tessera | all",
+				Value: "all",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "write the graph to the file instead of stdout.",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			format := c.String("format")
+			layer := c.String("layer")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			if layer != "p2p" && layer != "tessera" && layer != "all" {
+				return cli.Exit(fmt.Sprintf("invalid layer [%s], must be p2p, tessera or all", layer), 2)
+			}
+			topology := buildTopology(configFileYaml, namespace, layer)
+			var out []byte
+			switch format {
+			case "json":
+				out, err = json.MarshalIndent(topology, "", "  ")
+				out = append(out, '\n')
+			case "dot":
+				out = topology.dot()
+			case "mermaid":
+				out = topology.mermaid()
+			default:
+				return cli.Exit(fmt.Sprintf("invalid format [%s], must be dot, json or mermaid", format), 2)
+			}
+			if err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if c.String("output") != "" {
+				return ioutil.WriteFile(c.String("output"), out, 0644)
+			}
+			os.Stdout.Write(out)
+			return nil
+		},
+	}
+)
+
+// buildTopology queries admin_nodeInfo, admin_peers and the tessera partyinfo of every internal node.
+func buildTopology(configFileYaml QConfig, namespace, layer string) networkTopology {
+	var topology networkTopology
+	nodesByName := map[string]topologyNode{}
+	nameByEnode := map[string]string{}
+	nameByTmUrl := map[string]string{}
+	addNode := func(node topologyNode) {
+		if _, exists := nodesByName[node.Name]; exists {
+			return
+		}
+		topology.Nodes = append(topology.Nodes, node)
+		nodesByName[node.Name] = node
+		if node.Enode != "" {
+			nameByEnode[node.Enode] = node.Name
+		}
+		if node.TmUrl != "" {
+			nameByTmUrl[normalizeTmUrl(node.TmUrl)] = node.Name
+		}
+	}
+
+	partyInfos := map[string]tmPartyInfo{}
+	peers := map[string][]rpcPeer{}
+	for _, nodeName := range getNodeNames(configFileYaml) {
+		podName := podNameFromPrefix(nodeName, namespace)
+		node := topologyNode{Name: nodeName}
+		var nodeInfo struct {
+			Enode string `json:"enode"`
+		}
+		if err := nodeRpcInto(podName, namespace, &nodeInfo, "admin_nodeInfo"); err == nil {
+			node.Enode = enodePubKey(nodeInfo.Enode)
+		}
+		if layer != "tessera" {
+			var nodePeers []rpcPeer
+			if err := nodeRpcInto(podName, namespace, &nodePeers, "admin_peers"); err == nil {
+				peers[nodeName] = nodePeers
+			}
+		}
+		if layer != "p2p" {
+			if partyInfo, err := tmGetPartyInfo(podName, namespace); err == nil {
+				partyInfos[nodeName] = partyInfo
+				node.TmUrl = partyInfo.Url
+			}
+		}
+		addNode(node)
+	}
+	for _, externalNode := range configFileYaml.ExternalNodes {
+		addNode(topologyNode{Name: externalNode.NodeUserIdent, Enode: enodePubKey(externalNode.EnodeUrl),
+			TmUrl: externalNode.TmUrl, External: true})
+	}
+
+	edges := map[string]bool{}
+	addEdge := func(edge topologyEdge) {
+		key := edge.From + "|" + edge.To + "|" + edge.Layer
+		if !edges[key] {
+			edges[key] = true
+			topology.Edges = append(topology.Edges, edge)
+		}
+	}
+	hasEdge := func(from, to, layer string) bool {
+		return edges[from+"|"+to+"|"+layer]
+	}
+
+	if layer != "tessera" {
+		for nodeName, nodePeers := range peers {
+			for _, peer := range nodePeers {
+				peerEnode := enodePubKey(peer.Enode)
+				peerName, found := nameByEnode[peerEnode]
+				if !found {
+					peerName = "unknown-" + shortId(peerEnode)
+					addNode(topologyNode{Name: peerName, Enode: peerEnode, Unknown: true})
+				}
+				if peer.Network.Inbound {
+					addEdge(topologyEdge{From: peerName, To: nodeName, Layer: "p2p", Direction: "outbound"})
+				} else {
+					addEdge(topologyEdge{From: nodeName, To: peerName, Layer: "p2p", Direction: "outbound"})
+				}
+			}
+		}
+		// every permissioned node is expected to be connected to every other permissioned node, only the connections
+		// of internal nodes can be seen.
+		var permissioned []string
+		if enodes, err := getPermissionedNodes(namespace); err == nil {
+			for _, enode := range enodes {
+				// the enode of a node whose geth is down is not known, fall back to its host name.
+				name, found := nameByEnode[enodePubKey(enode)]
+				if !found {
+					name = enodeName(enode, configFileYaml)
+				}
+				if _, exists := nodesByName[name]; exists {
+					permissioned = append(permissioned, name)
+				}
+			}
+		}
+		sort.Strings(permissioned)
+		for i, from := range permissioned {
+			for _, to := range permissioned[i+1:] {
+				if nodesByName[from].External && nodesByName[to].External {
+					continue
+				}
+				if !hasEdge(from, to, "p2p") && !hasEdge(to, from, "p2p") {
+					addEdge(topologyEdge{From: from, To: to, Layer: "p2p", Direction: "outbound", Missing: true})
+				}
+			}
+		}
+	}
+
+	if layer != "p2p" {
+		for nodeName, partyInfo := range partyInfos {
+			known := map[string]bool{}
+			for _, peer := range partyInfo.Peers {
+				peerUrl := normalizeTmUrl(peer.Url)
+				if peerUrl == normalizeTmUrl(partyInfo.Url) {
+					continue
+				}
+				known[peerUrl] = true
+				peerName, found := nameByTmUrl[peerUrl]
+				if !found {
+					peerName = peer.Url
+					addNode(topologyNode{Name: peerName, TmUrl: peer.Url, Unknown: true})
+				}
+				addEdge(topologyEdge{From: nodeName, To: peerName, Layer: "tessera", Direction: "known"})
+			}
+			// every tessera node should know every other (internal and external) tessera node.
+			for _, node := range topology.Nodes {
+				if node.Name == nodeName || node.Unknown || node.TmUrl == "" || known[normalizeTmUrl(node.TmUrl)] {
+					continue
+				}
+				addEdge(topologyEdge{From: nodeName, To: node.Name, Layer: "tessera", Direction: "known", Missing: true})
+			}
+		}
+	}
+	sort.SliceStable(topology.Edges, func(i, j int) bool {
+		a, b := topology.Edges[i], topology.Edges[j]
+		if a.Layer != b.Layer {
+			return a.Layer < b.Layer
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return topology
+}
+
+func (t networkTopology) dot() []byte {
+	var out bytes.Buffer
+	out.WriteString("digraph qubernetes {\n")
+	out.WriteString("  rankdir=LR;\n")
+	out.WriteString("  node [shape=ellipse];\n")
+	for _, node := range t.Nodes {
+		attrs := ""
+		if node.External {
+			attrs = ", shape=box"
+		} else if node.Unknown {
+			attrs = ", shape=box, style=dashed"
+		}
+		fmt.Fprintf(&out, "  %q [label=%q%s];\n", node.Name, node.Name, attrs)
+	}
+	for _, edge := range t.Edges {
+		var attrs []string
+		label := edge.Layer
+		if edge.Layer == "tessera" {
+			attrs = append(attrs, "style=dashed", "color=blue")
+		}
+		if edge.Missing {
+			label = "missing " + edge.Layer
+			attrs = append(attrs, "color=red", "fontcolor=red", "style=dotted")
+			if edge.Layer == "p2p" {
+				attrs = append(attrs, "dir=none")
+			}
+		}
+		attrs = append(attrs, fmt.Sprintf("label=%q", label))
+		fmt.Fprintf(&out, "  %q -> %q [%s];\n", edge.From, edge.To, strings.Join(attrs, ", "))
+	}
+	out.WriteString("}\n")
+	return out.Bytes()
+}
+
+var mermaidIdChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func (t networkTopology) mermaid() []byte {
+	var out bytes.Buffer
+	out.WriteString("graph LR\n")
+	id := func(name string) string {
+		return mermaidIdChars.ReplaceAllString(name, "_")
+	}
+	for _, node := range t.Nodes {
+		if node.External || node.Unknown {
+			fmt.Fprintf(&out, "  %s[\"%s\"]\n", id(node.Name), node.Name)
+		} else {
+			fmt.Fprintf(&out, "  %s([\"%s\"])\n", id(node.Name), node.Name)
+		}
+	}
+	var missing []string
+	for i, edge := range t.Edges {
+		switch {
+		case edge.Missing:
+			fmt.Fprintf(&out, "  %s -. missing %s .- %s\n", id(edge.From), edge.Layer, id(edge.To))
+			missing = append(missing, fmt.Sprintf("%d", i))
+		case edge.Layer == "tessera":
+			fmt.Fprintf(&out, "  %s -. tessera .-> %s\n", id(edge.From), id(edge.To))
+		default:
+			fmt.Fprintf(&out, "  %s -->|p2p| %s\n", id(edge.From), id(edge.To))
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(&out, "  linkStyle %s stroke:red,color:red\n", strings.Join(missing, ","))
+	}
+	return out.Bytes()
+}
+
+// tessera urls are compared without the trailing slash, e.g. http://10.0.0.1:9001/
+func normalizeTmUrl(tmUrl string) string {
+	return strings.ToLower(strings.TrimSuffix(tmUrl, "/"))
+}
+
+func shortId(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the subset of the transaction fields (eth_getTransactionByHash) qctl cares about.
+type rpcTransaction struct {
+	Hash        string `json:"hash"`
+	BlockNumber string `json:"blockNumber"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Input       string `json:"input"`
+	V           string `json:"v"`
+}
+
+// quorum marks private transactions with v = 37 or 38, the input is then the hash of the payload held by tessera.
+func (tx rpcTransaction) isPrivate() bool {
+	return tx.V == "0x25" || tx.V == "0x26"
+}
+
+// what a single node knows about the transaction.
+type nodeTxView struct {
+	Name          string
+	Found         bool
+	Block         string
+	ReceiptStatus string
+	HoldsPayload  bool
+	Err           error
+}
+
+var (
+	// qctl tx show 0xabc...
+	txShowCommand = cli.Command{
+		Name:      "show",
+		Usage:     "show which nodes have the transaction, its receipt, and for private transactions which tessera nodes hold the payload.",
+		ArgsUsage: "[tx hash]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 1 {
+				c.App.Run([]string{"qctl", "help", "tx", "show"})
+				return cli.Exit("wrong number of arguments, the transaction hash is required.", 2)
+			}
+			txHash := c.Args().First()
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			nodeNames := getNodeNames(configFileYaml)
+
+			var tx *rpcTransaction
+			var views []nodeTxView
+			for _, nodeName := range nodeNames {
+				podName := podNameFromPrefix(nodeName, namespace)
+				view := nodeTxView{Name: nodeName}
+				raw, err := nodeRpc(podName, namespace, "eth_getTransactionByHash", txHash)
+				if err != nil {
+					view.Err = err
+					views = append(views, view)
+					continue
+				}
+				if string(raw) != "null" {
+					var nodeTx rpcTransaction
+					if err := json.Unmarshal(raw, &nodeTx); err == nil {
+						view.Found = true
+						view.Block = "pending"
+						if nodeTx.BlockNumber != "" {
+							blockNumber, _ := hexToUint64(nodeTx.BlockNumber)
+							view.Block = fmt.Sprintf("%d", blockNumber)
+						}
+						if tx == nil {
+							tx = &nodeTx
+						}
+					}
+				}
+				var receipt rpcReceipt
+				if raw, err := nodeRpc(podName, namespace, "eth_getTransactionReceipt", txHash); err == nil && string(raw) != "null" {
+					if json.Unmarshal(raw, &receipt) == nil {
+						view.ReceiptStatus = receipt.Status
+					}
+				}
+				views = append(views, view)
+			}
+			if tx == nil {
+				displayTxViews(views, false)
+				return cli.Exit(fmt.Sprintf("transaction [%s] was not found on any node.", txHash), 1)
+			}
+
+			fmt.Println()
+			green.Println(fmt.Sprintf("  transaction [%s]", tx.Hash))
+			fmt.Println(fmt.Sprintf("    from: %s", tx.From))
+			to := tx.To
+			if to == "" {
+				to = "contract creation"
+			}
+			fmt.Println(fmt.Sprintf("    to:   %s", to))
+			if !tx.isPrivate() {
+				fmt.Println("    type: public")
+				displayTxViews(views, false)
+				return nil
+			}
+			fmt.Println("    type: private")
+			payloadHash, err := tmPayloadHash(tx.Input)
+			if err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			fmt.Println(fmt.Sprintf("    payload hash: %s", payloadHash))
+
+			// which tessera nodes hold the payload, and the participants as seen by the tessera nodes that hold it.
+			tmKeyNames := map[string]string{}
+			for _, nodeName := range nodeNames {
+				if tmKey, err := getTmPublicKey(nodeName, namespace); err == nil {
+					tmKeyNames[tmKey] = nodeName
+				}
+			}
+			participants := map[string]bool{}
+			escapedHash := url.PathEscape(payloadHash)
+			for i, nodeName := range nodeNames {
+				podName := podNameFromPrefix(nodeName, namespace)
+				if _, err := tmQ2TGet(podName, namespace, "/transaction/"+escapedHash); err != nil {
+					continue
+				}
+				views[i].HoldsPayload = true
+				if res, err := tmQ2TGet(podName, namespace, "/transaction/"+escapedHash+"/participants"); err == nil {
+					for _, key := range strings.Split(strings.TrimSpace(string(res)), ",") {
+						if key != "" {
+							participants[key] = true
+						}
+					}
+				}
+			}
+			displayTxViews(views, true)
+			if len(participants) > 0 {
+				green.Println("  participants (privateFor + sender):")
+				for key := range participants {
+					name, found := tmKeyNames[key]
+					if !found {
+						name = "unknown / external"
+					}
+					fmt.Println(fmt.Sprintf("    %s  %s", key, name))
+				}
+				fmt.Println()
+			}
+			for _, view := range views {
+				if !view.HoldsPayload {
+					continue
+				}
+				if view.ReceiptStatus == "" || !view.Found {
+					red.Println(fmt.Sprintf("  [%s] holds the payload but has not processed the transaction.", view.Name))
+				}
+			}
+			return nil
+		},
+	}
+)
+
+// tmPayloadHash converts the private transaction input (the hex encoded hash of the tessera payload) to the
+// base64 encoding tessera uses.
+func tmPayloadHash(input string) (string, error) {
+	hashBytes, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("invalid private transaction input [%s]: %v", input, err)
+	}
+	return base64.StdEncoding.EncodeToString(hashBytes), nil
+}
+
+func displayTxViews(views []nodeTxView, isPrivate bool) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	header := "  NODE\tFOUND\tBLOCK\tRECEIPT"
+	if isPrivate {
+		header += "\tPAYLOAD"
+	}
+	fmt.Fprintln(w, header)
+	for _, view := range views {
+		if view.Err != nil {
+			fmt.Fprintln(w, fmt.Sprintf("  %s\t-\t-\t-\t%v", view.Name, view.Err))
+			continue
+		}
+		block, receipt := "-", "-"
+		if view.Found {
+			block = view.Block
+		}
+		switch view.ReceiptStatus {
+		case "0x1":
+			receipt = "success"
+		case "0x0":
+			receipt = "failed"
+		}
+		line := fmt.Sprintf("  %s\t%t\t%s\t%s", view.Name, view.Found, block, receipt)
+		if isPrivate {
+			payload := "no"
+			if view.HoldsPayload {
+				payload = "yes"
+			}
+			line += "\t" + payload
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+	fmt.Println()
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// a step of the upgrade test, deploying the old network, the baseline checks, rolling a node, the checks after the
+// upgrade.
+type upgradeStep struct {
+	Name       string        `json:"name"`
+	Node       string        `json:"node,omitempty"`
+	Versions   string        `json:"versions,omitempty"` // the quorum / tm versions of the node after the step.
+	Duration   time.Duration `json:"duration"`
+	Height     uint64        `json:"height"`
+	LoadSent   int           `json:"loadSent"`
+	LoadFailed int           `json:"loadFailed"`
+	Problems   []string      `json:"problems,omitempty"`
+}
+
+func (s upgradeStep) passed() bool {
+	return len(s.Problems) == 0
+}
+
+// the compatibility report of the upgrade test.
+type upgradeReport struct {
+	From       string        `json:"from"`
+	To         string        `json:"to"`
+	TmFrom     string        `json:"tmFrom"`
+	TmTo       string        `json:"tmTo"`
+	Consensus  string        `json:"consensus"`
+	Nodes      int           `json:"nodes"`
+	Namespace  string        `json:"namespace"`
+	Start      time.Time     `json:"start"`
+	Duration   time.Duration `json:"duration"`
+	Steps      []upgradeStep `json:"steps"`
+	Compatible bool          `json:"compatible"`
+}
+
+var (
+	// qctl test upgrade --from 2.6.0 --to 2.7.0
+	// qctl test upgrade --from 2.6.0 --to 2.7.0 --tm-from 0.10.4 --tm-to 0.10.5 --report upgrade.json
+	upgradeTestCmd = cli.Command{
+		Name:  "upgrade",
+		Usage: "deploy the network at the old versions in a scratch namespace, roll the nodes one by one to the new versions under load, and report if the chain, private state and consensus survived.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`, the nodes and consensus of the scratch network.",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "from",
+				Usage:    "the quorum version the network is deployed at.",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "to",
+				Usage: "the quorum version the nodes are upgraded to, if not set, only tessera is upgraded.",
+			},
+			&cli.StringFlag{
+				Name:  "tm-from",
+				Usage: "the tessera version the network is deployed at, defaults to the Tm_Version of the config.",
+			},
+			&cli.StringFlag{
+				Name:  "tm-to",
+				Usage: "the tessera version the nodes are upgraded to, if not set, tessera is not upgraded.",
+			},
+			&cli.StringFlag{
+				Name:  "scratch-namespace",
+				Usage: "the namespace the scratch network is deployed to, must not exist, defaults to qctl-upgrade-{TIMESTAMP}.",
+			},
+			&cli.BoolFlag{
+				Name:  "keep",
+				Usage: "keep the scratch namespace and the generated resources after the test.",
+			},
+			&cli.StringFlag{
+				Name:  "version",
+				Usage: "Which version of qubernetes to use.",
+				Value: "latest",
+			},
+			&cli.StringFlag{
+				Name:    "qubecontainer",
+				Usage:   "use a differnt qubernetes build container, default quorumengineering/qubernetes.",
+				Aliases: []string{"qcontainer", "container"},
+				Value:   QubernetesContainer,
+			},
+			&cli.Float64Flag{
+				Name:  "rate",
+				Usage: "the transactions per second sent while the nodes are rolled, half of them private, 0 for no load.",
+				Value: 2,
+			},
+			&cli.Uint64Flag{
+				Name:  "blocks",
+				Usage: "the number of blocks the network must make after each node is rolled.",
+				Value: 3,
+			},
+			&cli.DurationFlag{
+				Name:  "rollout-timeout",
+				Usage: "how long to wait for a node to be deployed.",
+				Value: 5 * time.Minute,
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "how long to wait for the blocks and receipts after each step.",
+				Value: 3 * time.Minute,
+			},
+			&cli.StringFlag{
+				Name:  "report",
+				Usage: "write the compatibility report as json to the file.",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			configFile := c.String("config")
+			if c.String("to") == "" && c.String("tm-to") == "" {
+				return cli.Exit("--to or --tm-to must be set.", 2)
+			}
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			if len(configFileYaml.Nodes) < 2 {
+				return cli.Exit("the upgrade test needs at least 2 nodes to check the private state.", 2)
+			}
+			namespace := c.String("scratch-namespace")
+			if namespace == "" {
+				namespace = fmt.Sprintf("qctl-upgrade-%d", time.Now().Unix())
+			}
+			tmFrom := c.String("tm-from")
+			if tmFrom == "" {
+				tmFrom = configFileYaml.Genesis.TmVersion
+			}
+			report := upgradeReport{From: c.String("from"), To: c.String("to"), TmFrom: tmFrom, TmTo: c.String("tm-to"),
+				Consensus: configFileYaml.Genesis.Consensus, Nodes: len(configFileYaml.Nodes), Namespace: namespace,
+				Start: time.Now()}
+			if report.To == "" {
+				report.To = report.From
+			}
+			if report.TmTo == "" {
+				report.TmTo = report.TmFrom
+			}
+
+			// the scratch namespace is deleted after the test, so it must be one qctl creates, never an existing one.
+			if runCmdQuiet(exec.Command("kubectl", "get", "namespace", namespace)) == nil {
+				return cli.Exit(fmt.Sprintf("the scratch namespace [%s] already exists, use a new --scratch-namespace.", namespace), 2)
+			}
+			// the scratch network is generated from a copy of the config, the original config is not changed.
+			scratchDir, err := ioutil.TempDir("", "qctl-upgrade")
+			if err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if err := runCmdQuiet(exec.Command("kubectl", "create", "namespace", namespace)); err != nil {
+				os.RemoveAll(scratchDir)
+				return cli.Exit(fmt.Sprintf("unable to create the scratch namespace [%s]: %v", namespace, err), 3)
+			}
+			scratch := &upgradeNetwork{namespace: namespace, configFile: scratchDir + "/qubernetes.yaml",
+				k8sdir: scratchDir + "/out", qubeContainer: c.String("qubecontainer") + ":" + c.String("version"),
+				rolloutTimeout: c.Duration("rollout-timeout"), config: configFileYaml}
+			// the resources are applied to the scratch namespace, never to the k8s.namespace of the config.
+			scratch.config.K8s.Namespace = ""
+			scratch.setVersions("", report.From, report.TmFrom)
+			defer func() {
+				if c.Bool("keep") {
+					green.Println(fmt.Sprintf("  keeping the scratch namespace [%s] and the resources in [%s]", namespace, scratchDir))
+					return
+				}
+				green.Println(fmt.Sprintf("  deleting the scratch namespace [%s]", namespace))
+				runCmdQuiet(exec.Command("kubectl", "delete", "namespace", namespace, "--wait=false"))
+				os.RemoveAll(scratchDir)
+			}()
+
+			runUpgradeTest(scratch, &report, c.Uint64("blocks"), c.Float64("rate"), c.Duration("timeout"))
+			report.Duration = time.Since(report.Start)
+
+			if c.String("report") != "" {
+				out, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return cli.Exit(err.Error(), 3)
+				}
+				if err := ioutil.WriteFile(c.String("report"), append(out, '\n'), 0644); err != nil {
+					return cli.Exit(err.Error(), 3)
+				}
+			}
+			displayUpgradeReport(report)
+			if !report.Compatible {
+				return cli.Exit(fmt.Sprintf("the upgrade from [%s / %s] to [%s / %s] is not compatible.", report.From,
+					report.TmFrom, report.To, report.TmTo), 1)
+			}
+			return nil
+		},
+	}
+)
+
+// the scratch network the upgrade test deploys and rolls.
+type upgradeNetwork struct {
+	namespace      string
+	configFile     string
+	k8sdir         string
+	qubeContainer  string
+	rolloutTimeout time.Duration
+	config         QConfig
+}
+
+// setVersions sets the versions of the node, or of all the nodes if nodeName is empty.
+func (n *upgradeNetwork) setVersions(nodeName, quorumVersion, tmVersion string) {
+	if nodeName == "" {
+		n.config.Genesis.QuorumVersion = quorumVersion
+		n.config.Genesis.TmVersion = tmVersion
+	}
+	for i := range n.config.Nodes {
+		if nodeName == "" || n.config.Nodes[i].NodeUserIdent == nodeName {
+			n.config.Nodes[i].QuorumEntry.Quorum.QuorumVersion = quorumVersion
+			n.config.Nodes[i].QuorumEntry.Tm.TmVersion = tmVersion
+		}
+	}
+}
+
+// generate runs the qubernetes container on the scratch config, see generateNetworkCommand.
+func (n *upgradeNetwork) generate(action string) error {
+	if _, err := WriteYamlConfig(n.config, n.configFile); err != nil {
+		return err
+	}
+	cmd := exec.Command("docker", "run", "--rm", "-v", n.configFile+":/qubernetes/qubes.yaml", "-v", n.k8sdir+":/qubernetes/out",
+		n.qubeContainer, "./qube-init", "--action="+action, "qubes.yaml")
+	fmt.Println(cmd.String())
+	if err := runCmdQuiet(cmd); err != nil {
+		return fmt.Errorf("generating the resources with the qubernetes container failed: %v", err)
+	}
+	return nil
+}
+
+// apply applies the deployment of the node, or all the resources if nodeName is empty, and waits for the rollout.
+func (n *upgradeNetwork) apply(nodeName string) error {
+	var cmd *exec.Cmd
+	deploymentFile := n.k8sdir + "/deployments/" + nodeName + "-quorum-deployment.yaml"
+	if nodeName != "" && fileExists(deploymentFile) {
+		cmd = exec.Command("kubectl", "--namespace="+n.namespace, "apply", "-f", deploymentFile)
+	} else if fileExists(n.k8sdir + "/deployments") {
+		cmd = exec.Command("kubectl", "--namespace="+n.namespace, "apply", "-f", n.k8sdir, "-f", n.k8sdir+"/deployments")
+	} else {
+		cmd = exec.Command("kubectl", "--namespace="+n.namespace, "apply", "-f", n.k8sdir)
+	}
+	fmt.Println(cmd.String())
+	if err := runCmdQuiet(cmd); err != nil {
+		return fmt.Errorf("kubectl apply failed: %v", err)
+	}
+	nodeNames := []string{nodeName}
+	if nodeName == "" {
+		nodeNames = getNodeNames(n.config)
+	}
+	for _, name := range nodeNames {
+		cmd := exec.Command("kubectl", "--namespace="+n.namespace, "rollout", "status", "deployment/"+name+"-deployment",
+			fmt.Sprintf("--timeout=%v", n.rolloutTimeout))
+		if err := runCmdQuiet(cmd); err != nil {
+			return fmt.Errorf("node [%s] was not deployed within [%v]", name, n.rolloutTimeout)
+		}
+	}
+	return nil
+}
+
+// runUpgradeTest deploys the network at the old versions, checks the private state before and after every node is
+// rolled to the new versions while the load is sent, and finally runs the smoke checks on the upgraded network.
+func runUpgradeTest(scratch *upgradeNetwork, report *upgradeReport, blocks uint64, rate float64, timeout time.Duration) {
+	namespace := scratch.namespace
+	nodeNames := getNodeNames(scratch.config)
+	test := &smokeTest{namespace: namespace, consensus: report.Consensus, quorumVersion: report.From, timeout: timeout}
+	runStep := func(step upgradeStep, run func(step *upgradeStep)) bool {
+		green.Println(fmt.Sprintf("  %s", step.Name))
+		start := time.Now()
+		run(&step)
+		step.Duration = time.Since(start)
+		step.Height = maxBlockNumber(nodeNames, namespace)
+		report.Steps = append(report.Steps, step)
+		for _, problem := range step.Problems {
+			red.Println("    " + problem)
+		}
+		return step.passed()
+	}
+
+	deployed := runStep(upgradeStep{Name: fmt.Sprintf("deploy the network in [%s]", namespace),
+		Versions: report.From + " / " + report.TmFrom}, func(step *upgradeStep) {
+		if err := scratch.generate("create"); err != nil {
+			step.Problems = append(step.Problems, err.Error())
+			return
+		}
+		if err := scratch.apply(""); err != nil {
+			step.Problems = append(step.Problems, err.Error())
+			return
+		}
+		if !waitForBlock(nodeNames, namespace, report.Consensus, blocks, timeout) {
+			step.Problems = append(step.Problems, fmt.Sprintf("the network did not reach block [%d]", blocks))
+		}
+	})
+	if !deployed {
+		return
+	}
+	var expected uint64
+	baseline := runStep(upgradeStep{Name: "private state before the upgrade"}, func(step *upgradeStep) {
+		for _, nodeName := range nodeNames {
+			node := smokeNode{Name: nodeName, Pod: podNameFromPrefix(nodeName, namespace)}
+			node.Account, _ = nodeAccount(node.Pod, namespace)
+			node.TmKey, _ = getTmPublicKey(nodeName, namespace)
+			test.nodes = append(test.nodes, node)
+		}
+		if err := smokePrivateTx(test); err != nil {
+			step.Problems = append(step.Problems, err.Error())
+			return
+		}
+		expected, _ = smokeStoredValue(test.nodes[0], namespace, test.privateContract)
+	})
+	if !baseline {
+		return
+	}
+
+	load := &upgradeLoad{namespace: namespace, nodes: test.nodes}
+	stop := make(chan struct{})
+	if rate > 0 {
+		go load.run(rate, stop)
+	}
+	for _, nodeName := range nodeNames {
+		sent, failed := load.counts()
+		quorumVersion, tmVersion := report.To, report.TmTo
+		runStep(upgradeStep{Name: fmt.Sprintf("upgrade [%s]", nodeName), Node: nodeName,
+			Versions: quorumVersion + " / " + tmVersion}, func(step *upgradeStep) {
+			startHeight := maxBlockNumber(nodeNames, namespace)
+			scratch.setVersions(nodeName, quorumVersion, tmVersion)
+			if err := scratch.generate("update"); err != nil {
+				step.Problems = append(step.Problems, err.Error())
+				return
+			}
+			if err := scratch.apply(nodeName); err != nil {
+				step.Problems = append(step.Problems, err.Error())
+				return
+			}
+			// the pod of the rolled node has a new name.
+			for i := range test.nodes {
+				test.nodes[i].Pod = podNameFromPrefix(test.nodes[i].Name, namespace)
+			}
+			step.Problems = append(step.Problems, checkUpgradeStep(test, nodeNames, startHeight+blocks, expected)...)
+		})
+		step := &report.Steps[len(report.Steps)-1]
+		step.LoadSent, step.LoadFailed = load.counts()
+		step.LoadSent, step.LoadFailed = step.LoadSent-sent, step.LoadFailed-failed
+	}
+	close(stop)
+
+	// the smoke checks send new public and private transactions through the upgraded nodes.
+	for _, check := range []smokeCheck{
+		{"public transaction after the upgrade", smokePublicTx},
+		{"private transaction after the upgrade", smokePrivateTx},
+		{"private state root after the upgrade", smokePrivateStateRoot},
+	} {
+		runStep(upgradeStep{Name: check.Name}, func(step *upgradeStep) {
+			if err := check.Run(test); err != nil {
+				step.Problems = append(step.Problems, err.Error())
+			}
+		})
+	}
+	report.Compatible = true
+	for _, step := range report.Steps {
+		if !step.passed() {
+			report.Compatible = false
+		}
+	}
+}
+
+// checkUpgradeStep checks the chain continues without a fork, and the private contract of the baseline still has the
+// same value and storage root on the participants, and no value on the other nodes.
+func checkUpgradeStep(test *smokeTest, nodeNames []string, height, expected uint64) []string {
+	var problems []string
+	if !waitForBlock(nodeNames, test.namespace, test.consensus, height, test.timeout) {
+		return append(problems, fmt.Sprintf("the network did not reach block [%d] within [%v]", height, test.timeout))
+	}
+	forked, commonHeight, err := checkChain(nodeNames, test.namespace, nil)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+	if forked > 0 {
+		problems = append(problems, fmt.Sprintf("%d node(s) are not on the same chain at block [%d]", forked, commonHeight))
+	}
+	for i, node := range test.nodes {
+		stored, err := smokeStoredValue(node, test.namespace, test.privateContract)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: %v", node.Name, err))
+		case i < 2 && stored != expected:
+			problems = append(problems, fmt.Sprintf("%s: private value [%d] expected [%d]", node.Name, stored, expected))
+		case i >= 2 && stored != 0:
+			problems = append(problems, fmt.Sprintf("%s: non participant sees the private value [%d]", node.Name, stored))
+		}
+	}
+	if err := smokePrivateStateRoot(test); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// upgradeLoad sends transactions round robin from the nodes while they are rolled, every other transaction is private
+// for the next node. Transactions sent to the node being rolled are expected to fail.
+type upgradeLoad struct {
+	namespace string
+	nodes     []smokeNode
+	mu        sync.Mutex
+	sent      int
+	failed    int
+}
+
+func (l *upgradeLoad) run(rate float64, stop chan struct{}) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		sender := l.nodes[i%len(l.nodes)]
+		tx := rpcTx{From: sender.Account, Data: benchTxData, Gas: benchTxGas}
+		if i%2 == 1 {
+			tx.PrivateFor = []string{l.nodes[(i+1)%len(l.nodes)].TmKey}
+		}
+		_, err := sendTransaction(podNameFromPrefix(sender.Name, l.namespace), l.namespace, tx)
+		l.mu.Lock()
+		l.sent++
+		if err != nil {
+			l.failed++
+		}
+		l.mu.Unlock()
+	}
+}
+
+func (l *upgradeLoad) counts() (int, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sent, l.failed
+}
+
+func displayUpgradeReport(report upgradeReport) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, fmt.Sprintf("  network\t%s, %d nodes, namespace %s", report.Consensus, report.Nodes, report.Namespace))
+	fmt.Fprintln(w, fmt.Sprintf("  quorum\t%s -> %s", report.From, report.To))
+	fmt.Fprintln(w, fmt.Sprintf("  tessera\t%s -> %s", report.TmFrom, report.TmTo))
+	fmt.Fprintln(w, fmt.Sprintf("  duration\t%v", report.Duration.Round(time.Second)))
+	w.Flush()
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  STEP\tVERSIONS\tBLOCK\tLOAD (FAILED)\tDURATION\tRESULT")
+	for _, step := range report.Steps {
+		result := "ok"
+		if !step.passed() {
+			result = "FAILED"
+		}
+		fmt.Fprintln(w, fmt.Sprintf("  %s\t%s\t%d\t%d (%d)\t%v\t%s", step.Name, step.Versions, step.Height, step.LoadSent,
+			step.LoadFailed, step.Duration.Round(time.Second), result))
+	}
+	w.Flush()
+	fmt.Println()
+	for _, step := range report.Steps {
+		if !step.passed() {
+			red.Println(fmt.Sprintf("  %s: %s", step.Name, strings.Join(step.Problems, ", ")))
+		}
+	}
+	if report.Compatible {
+		green.Println(fmt.Sprintf("  compatible: the network upgraded from [%s / %s] to [%s / %s].", report.From, report.TmFrom,
+			report.To, report.TmTo))
+	} else {
+		red.Println(fmt.Sprintf("  not compatible: the upgrade from [%s / %s] to [%s / %s] failed.", report.From, report.TmFrom,
+			report.To, report.TmTo))
+	}
+	fmt.Println()
+}
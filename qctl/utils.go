@@ -2,13 +2,15 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"time"
 
+	mapset "github.com/deckarep/golang-set"
 	"github.com/fatih/color"
 )
 
@@ -27,15 +29,22 @@ var (
 	DefaultGethPort    = "8545"
 	DefaultTesseraPort = "9001"
 	DefaultP2PPort     = "30303"
+	DefaultRaftPort    = "50401"
 
 	DefaultPrometheusClusterPort = "9090"
 	DefaultPrometheusNodePort    = "31323"
+	DefaultGrafanaClusterPort    = "3000"
+	DefaultAlertmanagerPort      = "9093"
 
 	ServiceTypeNodePort  = "NodePort"
 	ServiceTypeClusterIP = "ClusterIP"
 
+	// the node's persistent volume is mounted here, see templates/k8s/quorum-deployment.yaml.erb
+	DefaultNodeDataDir = "/etc/quorum/qdata"
+
 	RaftConsensus     = "raft"
 	IstanbulConsensus = "istanbul"
+	QibftConsensus    = "qibft"
 
 	QubernetesContainer = "quorumengineering/qubernetes" // "username/qubernetes-local"
 )
@@ -46,18 +55,7 @@ func podNameFromPrefix(prefix string, namespace string) string {
 	c1 := exec.Command("kubectl", "--namespace="+namespace, "get", "pods")
 	//fmt.Println(c1.String())
 
-	c2 := exec.Command("grep", prefix)
-	r, w := io.Pipe()
-	c1.Stdout = w
-	c2.Stdin = r
-
-	var b2 bytes.Buffer
-	c2.Stdout = &b2
-	c1.Start()
-	c2.Start()
-	c1.Wait()
-	w.Close()
-	c2.Wait()
+	b1, _ := runCmd(c1)
 
 	//var out bytes.Buffer
 	//cmd.Stdout = &out
@@ -65,7 +63,7 @@ func podNameFromPrefix(prefix string, namespace string) string {
 	//if err != nil {
 	//	log.Fatal(err)
 	//}
-	podOutput := b2.String()
+	podOutput := grepLines(b1.String(), prefix)
 	//io.Copy(os.Stdout, &b2)
 	//fmt.Printf(podOutput)
 	podName := strings.Split(podOutput, " ")[0]
@@ -79,20 +77,8 @@ func serviceNamesFromPrefix(prefix string, namespace string, info bool) []string
 	if info {
 		fmt.Println(c1.String())
 	}
-	c2 := exec.Command("grep", prefix)
-	r, w := io.Pipe()
-	c1.Stdout = w
-	c2.Stdin = r
-
-	var b2 bytes.Buffer
-	c2.Stdout = &b2
-	c1.Start()
-	c2.Start()
-	c1.Wait()
-	w.Close()
-	c2.Wait()
-
-	srvOutput := b2.String()
+	b1, _ := runCmd(c1)
+	srvOutput := grepLines(b1.String(), prefix)
 	//fmt.Println("srvOutput", srvOutput)
 	// split output on new line, this will add an extra empty entry in the array, e.g. if 1 item is returned, there
 	// will be 2 items in the array.
@@ -118,47 +104,15 @@ func serviceForPrefix(prefix string, namespace string, info bool) string {
 	if info {
 		fmt.Println(c1.String())
 	}
-	c2 := exec.Command("grep", prefix)
-	r, w := io.Pipe()
-	c1.Stdout = w
-	c2.Stdin = r
-
-	var b2 bytes.Buffer
-	c2.Stdout = &b2
-	c1.Start()
-	c2.Start()
-	c1.Wait()
-	w.Close()
-	c2.Wait()
-
-	srvOutput := b2.String()
+	b1, _ := runCmd(c1)
+	srvOutput := grepLines(b1.String(), prefix)
 	return srvOutput
 }
 
 // get the clusterIP for the given service
 // serviceOutput is the output of kubectl get service for a single service.
 func clusterIpForService(serviceOutputStr string) string {
-	c1 := exec.Command("echo", serviceOutputStr)
-	c2 := exec.Command("awk", `{print $3}`)
-	//fmt.Println(c1.String())
-	//fmt.Println(c2.String())
-
-	r, w := io.Pipe()
-	c1.Stdout = w
-	c2.Stdin = r
-
-	var out bytes.Buffer
-	c2.Stdout = &out
-	c1.Start()
-	c2.Start()
-	c1.Wait()
-	w.Close()
-	c2.Wait()
-	clusterIp := out.String()
-	//fmt.Println("" + clusterIp)
-	//  if err := c2.Run(); err != nil {
-	//	log.Fatal(err)
-	//  }
+	clusterIp := awkField(serviceOutputStr, 3)
 	return strings.TrimSpace(clusterIp)
 }
 
@@ -166,24 +120,8 @@ func clusterIpForService(serviceOutputStr string) string {
 // serviceOutput is the output of kubectl get service for a single service.
 // TODO slice awk output on ','
 func nodePortFormClusterPort(serviceOutputStr string, clusterPort string) string {
-	c1 := exec.Command("echo", serviceOutputStr)
-	c2 := exec.Command("awk", `{print $5}`)
-	//fmt.Println(c1.String())
-	//fmt.Println(c2.String())
-
-	r, w := io.Pipe()
-	c1.Stdout = w
-	c2.Stdin = r
-
-	var out bytes.Buffer
-	c2.Stdout = &out
-	c1.Start()
-	c2.Start()
-	c1.Wait()
-	w.Close()
-	c2.Wait()
 	// out contains  all nodeportsi, e.g. 9001:30589/TCP,9080:30151/TCP,8545:32119/TCP,8546:30510/TCP,30303:32238/TCP
-	nodePortOutput := strings.TrimSpace(out.String())
+	nodePortOutput := strings.TrimSpace(awkField(serviceOutputStr, 5))
 	// example nodePort output: 9080:31973/TCP,8545:32734/TCP
 	nodePorts := strings.Split(nodePortOutput, ",")
 	//fmt.Println(fmt.Sprintf("nodePorts [%v]", nodePorts))
@@ -214,8 +152,7 @@ func nodePortForService(serviceOutputStr string) string {
 
 func showPods(namespace string) {
 	cmd := exec.Command("kubectl", "--namespace="+namespace, "get", "pods")
-	var b bytes.Buffer
-	cmd.Stdout = &b
+	b, _ := runCmd(cmd)
 	fmt.Print(b.String())
 }
 
@@ -226,20 +163,20 @@ func dropIntoCmd(cmd *exec.Cmd) error {
 	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
 	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	err := runner.Run(cmd)
 	return err
 }
 func dropIntoCmdQuiet(cmd *exec.Cmd) error {
 	//	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
 	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	err := runner.Run(cmd)
 	return err
 }
 
 // do not display the command output
 func runCmdQuiet(cmd *exec.Cmd) error {
-	err := cmd.Run()
+	err := runner.Run(cmd)
 	if err != nil {
 		return err
 	}
@@ -249,10 +186,69 @@ func runCmdQuiet(cmd *exec.Cmd) error {
 func runCmd(cmd *exec.Cmd) (bytes.Buffer, error) {
 	var out bytes.Buffer
 	cmd.Stdout = &out
-	err := cmd.Run()
+	err := runner.Run(cmd)
 	return out, err
 }
 
+// sortedSetStrings returns the set entries sorted, so the output does not depend on the set's iteration order.
+func sortedSetStrings(set mapset.Set) []string {
+	var entries []string
+	for _, entry := range set.ToSlice() {
+		entries = append(entries, fmt.Sprintf("%v", entry))
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+// grepLines returns the lines of the output containing the pattern, like `grep pattern`.
+func grepLines(output, pattern string) string {
+	var matched strings.Builder
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if strings.Contains(line, pattern) {
+			matched.WriteString(strings.TrimSuffix(line, "\n") + "\n")
+		}
+	}
+	return matched.String()
+}
+
+// awkField returns the nth (1 based) whitespace separated field of every line, like `awk '{print $n}'`.
+func awkField(output string, n int) string {
+	var fields strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		lineFields := strings.Fields(line)
+		if len(lineFields) >= n {
+			fields.WriteString(lineFields[n-1])
+		}
+		fields.WriteString("\n")
+	}
+	return fields.String()
+}
+
+// splitFlagValues splits the values of a StringSliceFlag on the commas, urfave/cli only splits the env var, so both
+// --node=a,b and --node=a --node=b work. The commas inside brackets or double quotes are kept, e.g. --args='[1,2]'.
+func splitFlagValues(values []string) []string {
+	var split []string
+	for _, value := range values {
+		depth, quoted, start := 0, false, 0
+		for i, r := range value {
+			switch {
+			case r == '"' && (i == 0 || value[i-1] != '\\'):
+				quoted = !quoted
+			case quoted:
+			case r == '[':
+				depth++
+			case r == ']':
+				depth--
+			case r == ',' && depth == 0:
+				split = append(split, strings.TrimSpace(value[start:i]))
+				start = i + 1
+			}
+		}
+		split = append(split, strings.TrimSpace(value[start:]))
+	}
+	return split
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
@@ -290,19 +286,8 @@ func waitForPodsReadyState(qconfigYaml QConfig, namespace string) {
 			c1 := exec.Command("kubectl", "--namespace="+namespace, "get", "pod", podName)
 			// get the pod status part only ignoring the header, e.g.:
 			// node5-deployment-54d7d99575-ztgbv   2/2     Running   0          9h
-			c2 := exec.Command("grep", podName)
-			r, w := io.Pipe()
-			c1.Stdout = w
-			c2.Stdin = r
-			var b2 bytes.Buffer
-			c2.Stdout = &b2
-			c1.Start()
-			c2.Start()
-			c1.Wait()
-			w.Close()
-			c2.Wait()
-			b2.String()
-			podOutput := b2.String()
+			b1, _ := runCmd(c1)
+			podOutput := grepLines(b1.String(), podName)
 
 			podParts := strings.Fields(podOutput)
 			isRunning := false
@@ -365,3 +350,92 @@ func getNodeNames(configFileYaml QConfig) []string {
 	}
 	return names
 }
+
+// the subset of the K8s pod resource (kubectl get pod -o json) used by qctl.
+type k8sPod struct {
+	Metadata struct {
+		Name              string `json:"name"`
+		CreationTimestamp string `json:"creationTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers []struct {
+			Name  string `json:"name"`
+			Image string `json:"image"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase             string `json:"phase"`
+		ContainerStatuses []struct {
+			Name         string `json:"name"`
+			Ready        bool   `json:"ready"`
+			RestartCount int    `json:"restartCount"`
+			Image        string `json:"image"`
+			LastState    struct {
+				Terminated *struct {
+					Reason     string `json:"reason"`
+					ExitCode   int    `json:"exitCode"`
+					FinishedAt string `json:"finishedAt"`
+				} `json:"terminated"`
+			} `json:"lastState"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+// number of ready containers and the total number of containers in the pod.
+func (p k8sPod) readyContainers() (int, int) {
+	ready := 0
+	for _, containerStatus := range p.Status.ContainerStatuses {
+		if containerStatus.Ready {
+			ready++
+		}
+	}
+	return ready, len(p.Spec.Containers)
+}
+
+func (p k8sPod) isReady() bool {
+	ready, total := p.readyContainers()
+	return strings.ToUpper(p.Status.Phase) == "RUNNING" && total > 0 && ready == total
+}
+
+func getPod(podName, namespace string) (k8sPod, error) {
+	var pod k8sPod
+	if podName == "" {
+		return pod, fmt.Errorf("no pod found")
+	}
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "get", "pod", podName, "-o", "json")
+	out, err := runCmd(cmd)
+	if err != nil {
+		return pod, fmt.Errorf("unable to get pod [%s]: %v", podName, err)
+	}
+	err = json.Unmarshal(out.Bytes(), &pod)
+	return pod, err
+}
+
+// the subset of the K8s event resource (kubectl get events -o json) used by qctl.
+type k8sEvent struct {
+	InvolvedObject struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	} `json:"involvedObject"`
+	Reason         string `json:"reason"`
+	Message        string `json:"message"`
+	Type           string `json:"type"`
+	Count          int    `json:"count"`
+	FirstTimestamp string `json:"firstTimestamp"`
+	LastTimestamp  string `json:"lastTimestamp"`
+	EventTime      string `json:"eventTime"` // set instead of the timestamps by some newer components.
+}
+
+// getEvents returns the K8s events in the namespace, oldest first.
+func getEvents(namespace string) ([]k8sEvent, error) {
+	var events struct {
+		Items []k8sEvent `json:"items"`
+	}
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "get", "events", "--sort-by=.lastTimestamp", "-o", "json")
+	out, err := runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the events: %v", err)
+	}
+	err = json.Unmarshal(out.Bytes(), &events)
+	return events.Items, err
+}
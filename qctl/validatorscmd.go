@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the result of istanbul_status(startBlock, endBlock).
+type istanbulStatus struct {
+	NumBlocks      uint64         `json:"numBlocks"`
+	SealerActivity map[string]int `json:"sealerActivity"`
+}
+
+// the health of a single validator, either a node in the config, an external node, or a validator address
+// that could not be matched to anything in the config.
+type validatorHealth struct {
+	Name     string
+	Address  string
+	External bool
+	PodState string
+	Height   uint64
+	Lag      uint64
+	Proposed int
+	Problems []string
+}
+
+func (v validatorHealth) isHealthy() bool {
+	return len(v.Problems) == 0
+}
+
+var (
+	// qctl validators status --blocks=200
+	validatorsStatusCommand = cli.Command{
+		Name:  "status",
+		Usage: "validator and proposer health report for istanbul networks.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.Uint64Flag{
+				Name:  "blocks",
+				Usage: "number of recent blocks to count proposers over.",
+				Value: 100,
+			},
+			&cli.Uint64Flag{
+				Name:  "lag",
+				Usage: "number of blocks a node can be behind the highest node before it is reported as lagging.",
+				Value: 5,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			numBlocks := c.Uint64("blocks")
+			maxLag := c.Uint64("lag")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			if configFileYaml.Genesis.Consensus != IstanbulConsensus && configFileYaml.Genesis.Consensus != QibftConsensus {
+				return cli.Exit(fmt.Sprintf("the network consensus is [%s], validator status is only supported for istanbul networks", configFileYaml.Genesis.Consensus), 3)
+			}
+			validators, err := validatorsHealth(configFileYaml, namespace, numBlocks, maxLag)
+			if err != nil {
+				red.Println(fmt.Sprintf("  %v", err))
+				return cli.Exit("unable to get the validator status from any node in the network.", 3)
+			}
+			displayValidatorsHealth(validators)
+			return nil
+		},
+	}
+)
+
+// validatorsHealth combines the validator set, the proposer activity over the last numBlocks and the pod state of
+// every node (internal and external) in the config.
+func validatorsHealth(configFileYaml QConfig, namespace string, numBlocks, maxLag uint64) ([]validatorHealth, error) {
+	// address (lowercase) -> health of the nodes in the config, the validators are matched against this.
+	nodesByAddress := map[string]*validatorHealth{}
+	heights := map[string]uint64{}
+	var queryPod string
+	maxHeight := uint64(0)
+	for _, node := range configFileYaml.Nodes {
+		nodeName := node.NodeUserIdent
+		health := validatorHealth{Name: nodeName, PodState: "not deployed"}
+		podName := podNameFromPrefix(nodeName, namespace)
+		if pod, err := getPod(podName, namespace); err == nil {
+			ready, total := pod.readyContainers()
+			health.PodState = fmt.Sprintf("%s %d/%d", pod.Status.Phase, ready, total)
+		}
+		var address string
+		if err := nodeRpcInto(podName, namespace, &address, "istanbul_nodeAddress"); err != nil {
+			address = nodekeyAddressFromConfigMap(nodeName, namespace)
+			health.Problems = append(health.Problems, "offline")
+		} else if height, err := nodeBlockNumber(podName, namespace); err == nil {
+			heights[nodeName] = height
+			health.Height = height
+			if height >= maxHeight {
+				maxHeight = height
+				queryPod = podName
+			}
+		}
+		health.Address = strings.ToLower(address)
+		if health.Address == "" {
+			health.Address = "unknown (" + nodeName + ")"
+		}
+		nodesByAddress[health.Address] = &health
+	}
+	for _, externalNode := range configFileYaml.ExternalNodes {
+		if externalNode.NodekeyAddress == "" {
+			continue
+		}
+		address := strings.ToLower(strings.Trim(externalNode.NodekeyAddress, "\""))
+		if !strings.HasPrefix(address, "0x") {
+			address = "0x" + address
+		}
+		nodesByAddress[address] = &validatorHealth{Name: externalNode.NodeUserIdent, Address: address, External: true, PodState: "external"}
+	}
+	if queryPod == "" {
+		return nil, fmt.Errorf("no running node found in the network")
+	}
+
+	var validatorAddresses []string
+	if err := nodeRpcInto(queryPod, namespace, &validatorAddresses, "istanbul_getValidators"); err != nil {
+		return nil, err
+	}
+	from := uint64(1)
+	if maxHeight > numBlocks {
+		from = maxHeight - numBlocks + 1
+	}
+	var status istanbulStatus
+	if err := nodeRpcInto(queryPod, namespace, &status, "istanbul_status", uint64ToHex(from), uint64ToHex(maxHeight)); err != nil {
+		return nil, err
+	}
+	sealerActivity := map[string]int{}
+	for address, count := range status.SealerActivity {
+		sealerActivity[strings.ToLower(address)] = count
+	}
+
+	var validators []validatorHealth
+	for _, validatorAddress := range validatorAddresses {
+		address := strings.ToLower(validatorAddress)
+		health, found := nodesByAddress[address]
+		if !found {
+			health = &validatorHealth{Name: "unknown", Address: address, PodState: "not in config"}
+		}
+		health.Proposed = sealerActivity[address]
+		if _, isRunning := heights[health.Name]; isRunning {
+			health.Lag = maxHeight - health.Height
+			if health.Lag > maxLag {
+				health.Problems = append(health.Problems, fmt.Sprintf("lagging %d blocks", health.Lag))
+			}
+		}
+		// only report a validator as not proposing if the range was long enough for every validator to get a turn.
+		if health.Proposed == 0 && status.NumBlocks >= uint64(len(validatorAddresses)) {
+			health.Problems = append(health.Problems, "not proposing")
+		}
+		validators = append(validators, *health)
+	}
+	sort.Slice(validators, func(i, j int) bool { return validators[i].Name < validators[j].Name })
+	return validators, nil
+}
+
+// the nodekey address is stored in a configmap when the network is generated, e.g.
+// kc get configMap quorum-node1-nodekey-address-config -o jsonpath='{.data.nodekey}'
+func nodekeyAddressFromConfigMap(nodeName, namespace string) string {
+	cmd := exec.Command("kubectl", "--namespace="+namespace, "get", "configMap",
+		nodeName+"-nodekey-address-config", "-o=jsonpath={.data.nodekey}")
+	res, err := runCmd(cmd)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(res.String())
+}
+
+// istanbulQuorumSize is the number of validators needed to commit a block, ceil(2N/3).
+func istanbulQuorumSize(numValidators int) int {
+	return (2*numValidators + 2) / 3
+}
+
+func displayValidatorsHealth(validators []validatorHealth) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  VALIDATOR\tADDRESS\tPOD\tHEIGHT\tLAG\tPROPOSED\tSTATUS")
+	healthy := 0
+	for _, v := range validators {
+		status := "ok"
+		if v.isHealthy() {
+			healthy++
+		} else {
+			status = strings.Join(v.Problems, ", ")
+		}
+		height, lag := "-", "-"
+		if !v.External && v.Height > 0 {
+			height = fmt.Sprintf("%d", v.Height)
+			lag = fmt.Sprintf("%d", v.Lag)
+		}
+		fmt.Fprintln(w, fmt.Sprintf("  %s\t%s\t%s\t%s\t%s\t%d\t%s", v.Name, v.Address, v.PodState, height, lag, v.Proposed, status))
+	}
+	w.Flush()
+	fmt.Println()
+
+	numValidators := len(validators)
+	quorumSize := istanbulQuorumSize(numValidators)
+	maxFaulty := (numValidators - 1) / 3
+	fmt.Println(fmt.Sprintf("  validators: %d, healthy: %d, required for consensus (2F+1): %d, F: %d", numValidators, healthy, quorumSize, maxFaulty))
+	fmt.Println()
+	remaining := healthy - quorumSize
+	if remaining < 0 {
+		red.Println(fmt.Sprintf("  The network has lost 2F+1, %d more validator(s) must recover before blocks can be produced.", -remaining))
+	} else if remaining == 0 {
+		red.Println("  The network can not tolerate any more validator failures.")
+	} else {
+		green.Println(fmt.Sprintf("  The network can tolerate %d more validator failure(s).", remaining))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// the key codes read from the terminal in raw mode.
+const (
+	keyEnter     = 13
+	keyEscape    = 27
+	keyBackspace = 127
+	keyCtrlC     = 3
+)
+
+// the state of the watch screen.
+type watchView struct {
+	namespace string
+	consensus string
+	nodeNames []string
+	statuses  []nodeStatus
+	events    []k8sEvent
+	selected  int
+	message   string
+	refreshed time.Time
+}
+
+var (
+	// qctl watch
+	// qctl watch --interval=10s
+	watchCommand = cli.Command{
+		Name:  "watch",
+		Usage: "full screen view of the network, select a node to tail its logs or exec geth commands.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "how often to refresh the status of the nodes.",
+				Value: 5 * time.Second,
+			},
+			&cli.IntFlag{
+				Name:  "events",
+				Usage: "number of recent K8s events to show.",
+				Value: 8,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			view := &watchView{
+				namespace: namespace,
+				consensus: configFileYaml.Genesis.Consensus,
+				nodeNames: getNodeNames(configFileYaml),
+			}
+			if len(view.nodeNames) == 0 {
+				return cli.Exit("no nodes in the config.", 2)
+			}
+			terminalState, err := enterRawMode()
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to put the terminal in raw mode: %v", err), 3)
+			}
+			defer restoreTerminal(terminalState)
+
+			reader := newKeyReader()
+			keys := reader.keys
+			ticker := time.NewTicker(c.Duration("interval"))
+			defer ticker.Stop()
+			view.refresh(c.Int("events"))
+			view.render()
+			for {
+				select {
+				case <-ticker.C:
+					view.refresh(c.Int("events"))
+					view.render()
+				case key, ok := <-keys:
+					if !ok { // stdin was closed
+						return nil
+					}
+					switch key {
+					case 'q', keyCtrlC:
+						fmt.Print("\033[2J\033[H")
+						return nil
+					case 'k':
+						view.move(-1)
+					case 'j':
+						view.move(1)
+					case keyEscape: // arrow keys are sent as ESC [ A (up) / ESC [ B (down)
+						if nextKey(keys) == '[' {
+							switch nextKey(keys) {
+							case 'A':
+								view.move(-1)
+							case 'B':
+								view.move(1)
+							}
+						}
+					case 'l', 't':
+						container := "quorum"
+						if key == 't' {
+							container = DefaultTmName
+						}
+						// the key reader is paused while the child process owns the terminal.
+						resume := reader.pause()
+						restoreTerminal(terminalState)
+						err := tailLogs(view.selectedNode(), container, namespace)
+						enterRawMode()
+						resume()
+						if err != nil {
+							view.message = err.Error()
+						}
+					case 'g':
+						gethCmd := readLine(keys, fmt.Sprintf("geth exec on [%s] > ", view.selectedNode()))
+						if gethCmd != "" {
+							resume := reader.pause()
+							restoreTerminal(terminalState)
+							fmt.Println()
+							if podName := podNameFromPrefix(view.selectedNode(), namespace); podName == "" {
+								red.Println(fmt.Sprintf("  no pod found for node [%s]", view.selectedNode()))
+							} else if err := gethExec(podName, namespace, gethCmd); err != nil {
+								red.Println(fmt.Sprintf("  geth exec failed: %v", err))
+							}
+							fmt.Print("\npress any key to return to the watch.")
+							enterRawMode()
+							resume()
+							<-keys
+						}
+					case 'r':
+						view.refresh(c.Int("events"))
+					}
+					view.render()
+				}
+			}
+		},
+	}
+)
+
+func (v *watchView) move(delta int) {
+	v.selected = (v.selected + delta + len(v.nodeNames)) % len(v.nodeNames)
+}
+
+func (v *watchView) selectedNode() string {
+	return v.nodeNames[v.selected]
+}
+
+// refresh gets the status of all the nodes in parallel, and the most recent K8s events.
+func (v *watchView) refresh(numEvents int) {
+	v.statuses = getNodeStatuses(v.nodeNames, v.namespace, v.consensus)
+	v.message = ""
+	events, err := getEvents(v.namespace)
+	if err != nil {
+		v.message = err.Error()
+	}
+	if len(events) > numEvents {
+		events = events[len(events)-numEvents:]
+	}
+	v.events = events
+	v.refreshed = time.Now()
+}
+
+func (v *watchView) render() {
+	var screen bytes.Buffer
+	screen.WriteString("\033[2J\033[H")
+	screen.WriteString(green.Sprintf("  qctl watch [%s]  %s", v.namespace, v.refreshed.Format("15:04:05")))
+	screen.WriteString("\n\n")
+	w := tabwriter.NewWriter(&screen, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  \tNODE\tPHASE\tREADY\tRESTARTS\tBLOCK\tPEERS\tSYNCING\tROLE\tTM\tTM PEERS")
+	for i, s := range v.statuses {
+		marker := " "
+		if i == v.selected {
+			marker = ">"
+		}
+		block, peers, syncing, role := "-", "-", "-", "-"
+		if s.GethReachable {
+			block = fmt.Sprintf("%d", s.BlockNumber)
+			peers = fmt.Sprintf("%d", s.Peers)
+			syncing = fmt.Sprintf("%t", s.Syncing)
+			role = s.ConsensusRole
+		}
+		tm, tmPeers := "down", "-"
+		if s.TmUp {
+			tm = "up"
+			tmPeers = fmt.Sprintf("%d", s.TmPeers)
+		}
+		fmt.Fprintln(w, fmt.Sprintf("  %s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s", marker, s.Name, s.Phase, s.Ready,
+			s.Restarts, block, peers, syncing, role, tm, tmPeers))
+	}
+	w.Flush()
+	screen.WriteString("\n")
+	for _, s := range v.statuses {
+		if len(s.Problems) > 0 {
+			screen.WriteString(red.Sprintf("  [%s] %s", s.Name, strings.Join(s.Problems, ", ")))
+			screen.WriteString("\n")
+		}
+	}
+	screen.WriteString("\n  EVENTS\n")
+	for _, event := range v.events {
+		line := fmt.Sprintf("  %s  %-8s %-20s %s: %s", event.LastTimestamp, event.Type, event.Reason,
+			event.InvolvedObject.Name, event.Message)
+		if event.Type == "Warning" {
+			line = red.Sprint(line)
+		}
+		screen.WriteString(line + "\n")
+	}
+	if v.message != "" {
+		screen.WriteString("\n  " + red.Sprint(v.message) + "\n")
+	}
+	screen.WriteString("\n  ↑/↓ j/k select node   l quorum logs   t tessera logs   g geth exec   r refresh   q quit\n")
+	// the terminal is in raw mode, so new lines need a carriage return.
+	fmt.Print(strings.ReplaceAll(screen.String(), "\n", "\r\n"))
+}
+
+// tailLogs follows the logs of the container, until ctrl-c is pressed.
+func tailLogs(nodeName, container, namespace string) error {
+	podName := podNameFromPrefix(nodeName, namespace)
+	if podName == "" {
+		return fmt.Errorf("no pod found for node [%s]", nodeName)
+	}
+	// ctrl-c stops the kubectl logs process, not the watch.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	fmt.Print("\033[2J\033[H")
+	green.Println(fmt.Sprintf("  %s logs for [%s], ctrl-c to return to the watch.", container, nodeName))
+	fmt.Println()
+	err := containerLogs(podName, namespace, container, true)
+	select {
+	case <-interrupts: // kubectl exits with an error when it is interrupted.
+		return nil
+	default:
+		return err
+	}
+}
+
+// reads the terminal input one byte at a time, and can be paused so the input goes to a child process.
+type keyReader struct {
+	keys   chan byte
+	pauses chan chan struct{}
+}
+
+func newKeyReader() *keyReader {
+	reader := &keyReader{keys: make(chan byte), pauses: make(chan chan struct{})}
+	go reader.read()
+	return reader
+}
+
+func (r *keyReader) read() {
+	buf := make([]byte, 1)
+	for {
+		select {
+		case resume := <-r.pauses:
+			<-resume
+			continue
+		default:
+		}
+		// in raw mode the read returns without input after 100ms (stty min 0 time 1), so the pauses are checked. Go
+		// returns the empty read as io.EOF, an EOF returned right away is the end of the input.
+		start := time.Now()
+		n, err := os.Stdin.Read(buf)
+		if err == io.EOF && time.Since(start) >= 50*time.Millisecond {
+			continue
+		}
+		if err != nil {
+			close(r.keys)
+			return
+		}
+		if n == 1 {
+			select {
+			case r.keys <- buf[0]:
+			case resume := <-r.pauses: // the key pressed before the pause is dropped.
+				<-resume
+			}
+		}
+	}
+}
+
+// pause stops reading the terminal, until the returned resume func is called.
+func (r *keyReader) pause() func() {
+	resume := make(chan struct{})
+	r.pauses <- resume
+	return func() { close(resume) }
+}
+
+// nextKey waits briefly for the next byte of an escape sequence.
+func nextKey(keys <-chan byte) byte {
+	select {
+	case key := <-keys:
+		return key
+	case <-time.After(50 * time.Millisecond):
+		return 0
+	}
+}
+
+// readLine reads a line of input while the terminal is in raw mode, returns an empty string if escape is pressed.
+func readLine(keys <-chan byte, prompt string) string {
+	var line []byte
+	fmt.Print("\r\n  " + prompt)
+	for key := range keys {
+		switch key {
+		case keyEnter:
+			return string(line)
+		case keyEscape, keyCtrlC:
+			return ""
+		case keyBackspace:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				fmt.Print("\b \b")
+			}
+		default:
+			line = append(line, key)
+			fmt.Print(string(key))
+		}
+	}
+	return ""
+}
+
+// enterRawMode puts the terminal in raw mode (no line buffering or echo) returning the previous state so it can be
+// restored, stty is used so no terminal libraries are needed.
+func enterRawMode() (string, error) {
+	stateCmd := exec.Command("stty", "-g")
+	stateCmd.Stdin = os.Stdin
+	state, err := runCmd(stateCmd)
+	if err != nil {
+		return "", err
+	}
+	rawCmd := exec.Command("stty", "raw", "-echo", "min", "0", "time", "1")
+	rawCmd.Stdin = os.Stdin
+	if err := runner.Run(rawCmd); err != nil {
+		return "", err
+	}
+	// hide the cursor while watching.
+	fmt.Print("\033[?25l")
+	return strings.TrimSpace(state.String()), nil
+}
+
+func restoreTerminal(state string) {
+	cmd := exec.Command("stty", state)
+	cmd.Stdin = os.Stdin
+	runner.Run(cmd)
+	fmt.Print("\033[?25h")
+}
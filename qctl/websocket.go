@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// a minimal websocket (RFC 6455) client for the JSON-RPC subscriptions of geth, e.g. through the ingress
+// (Ingress.ws: true, see examples/config/ingress-ws.yaml), text messages only.
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+
+	// the GUID the server appends to the key in the handshake.
+	wsAcceptGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// the largest message read, the same as the limit of the geth websocket server (15MB).
+	wsMaxMessageSize = 15 * 1024 * 1024
+)
+
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// frames sent by the client must be masked, the server frames are not.
+	mask bool
+}
+
+// dialWebsocket connects to the ws:// or wss:// url and completes the opening handshake.
+func dialWebsocket(wsUrl string) (*wsConn, error) {
+	u, err := url.Parse(wsUrl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host += ":80"
+		}
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		if u.Port() == "" {
+			host += ":443"
+		}
+		conn, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("invalid websocket url [%s], must start with ws:// or wss://", wsUrl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyBytes := make([]byte, 16)
+	rand.Read(keyBytes)
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+	request, _ := http.NewRequest("GET", u.String(), nil)
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake with [%s] failed: %s", wsUrl, response.Status)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake with [%s] failed: invalid Sec-WebSocket-Accept", wsUrl)
+	}
+	return &wsConn{conn: conn, reader: reader, mask: true}, nil
+}
+
+func wsAcceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + wsAcceptGuid))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// writeText sends the message as a single text frame.
+func (c *wsConn) writeText(message []byte) error {
+	return c.writeFrame(wsOpText, message)
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode} // FIN
+	maskBit := byte(0)
+	if c.mask {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	if c.mask {
+		maskKey := make([]byte, 4)
+		rand.Read(maskKey)
+		frame = append(frame, maskKey...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ maskKey[i%4]
+		}
+		payload = masked
+	}
+	_, err := c.conn.Write(append(frame, payload...))
+	return err
+}
+
+// readMessage returns the next text or binary message, joining the fragments, and answers the pings. io.EOF is returned
+// when the server closes the connection.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, header); err != nil {
+			return nil, err
+		}
+		fin, opcode := header[0]&0x80 != 0, header[0]&0x0f
+		masked, length := header[1]&0x80 != 0, uint64(header[1]&0x7f)
+		switch length {
+		case 126:
+			extended := make([]byte, 2)
+			if _, err := io.ReadFull(c.reader, extended); err != nil {
+				return nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(extended))
+		case 127:
+			extended := make([]byte, 8)
+			if _, err := io.ReadFull(c.reader, extended); err != nil {
+				return nil, err
+			}
+			length = binary.BigEndian.Uint64(extended)
+		}
+		// the length is checked before the payload is allocated, it is sent by the server.
+		if length > uint64(wsMaxMessageSize-len(message)) {
+			return nil, fmt.Errorf("websocket message too large, %d bytes, the limit is %d bytes", uint64(len(message))+length, wsMaxMessageSize)
+		}
+		var maskKey []byte
+		if masked {
+			maskKey = make([]byte, 4)
+			if _, err := io.ReadFull(c.reader, maskKey); err != nil {
+				return nil, err
+			}
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return nil, err
+		}
+		for i := range maskKey {
+			for j := i; j < len(payload); j += 4 {
+				payload[j] ^= maskKey[i]
+			}
+		}
+		switch opcode {
+		case wsOpClose:
+			c.writeFrame(wsOpClose, nil)
+			return nil, io.EOF
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+		if opcode != wsOpContinuation && len(message) != len(payload) {
+			return nil, fmt.Errorf("unexpected websocket opcode [%d] in a fragmented message", opcode)
+		}
+	}
+}
+
+func (c *wsConn) Close() error {
+	c.writeFrame(wsOpClose, nil)
+	return c.conn.Close()
+}
+
+// isWebsocketUrl is true for the ws:// and wss:// urls.
+func isWebsocketUrl(rawUrl string) bool {
+	return strings.HasPrefix(rawUrl, "ws://") || strings.HasPrefix(rawUrl, "wss://")
+}
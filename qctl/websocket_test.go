@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+// the client frames are masked, the server frames are not, with the 7 bit, 16 bit and 64 bit payload lengths.
+func TestWebsocketFrames(t *testing.T) {
+	for _, size := range []int{5, 200, 70000} {
+		for _, mask := range []bool{true, false} {
+			clientConn, serverConn := net.Pipe()
+			writer := &wsConn{conn: clientConn, mask: mask}
+			reader := &wsConn{conn: serverConn, reader: bufio.NewReader(serverConn)}
+			message := bytes.Repeat([]byte("a"), size)
+			go writer.writeText(message)
+			received, err := reader.readMessage()
+			if err != nil {
+				t.Fatalf("size %d, mask %v: %v", size, mask, err)
+			}
+			if !bytes.Equal(received, message) {
+				t.Errorf("size %d, mask %v: received %d bytes", size, mask, len(received))
+			}
+			clientConn.Close()
+			serverConn.Close()
+		}
+	}
+}
+
+// a ping is answered with a pong and skipped, a close ends the stream.
+func TestWebsocketControlFrames(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	client := &wsConn{conn: clientConn, reader: bufio.NewReader(clientConn), mask: true}
+	server := &wsConn{conn: serverConn, reader: bufio.NewReader(serverConn)}
+	pong := make(chan byte, 1)
+	go func() {
+		server.writeFrame(wsOpPing, []byte("ping"))
+		// the masked pong: header, mask key, payload.
+		frame := make([]byte, 2+4+4)
+		io.ReadFull(serverConn, frame)
+		pong <- frame[0] & 0x0f
+		server.writeText([]byte("after ping"))
+		server.writeFrame(wsOpClose, nil)
+		io.Copy(ioutil.Discard, serverConn)
+	}()
+	message, err := client.readMessage()
+	if err != nil || string(message) != "after ping" {
+		t.Fatalf("message [%s] %v", message, err)
+	}
+	if opcode := <-pong; opcode != wsOpPong {
+		t.Errorf("expected a pong, got opcode %d", opcode)
+	}
+	if _, err := client.readMessage(); err != io.EOF {
+		t.Errorf("expected io.EOF after the close, got %v", err)
+	}
+}
+
+// a frame longer than the limit is rejected before its payload is allocated.
+func TestWebsocketMessageTooLarge(t *testing.T) {
+	frame := []byte{0x80 | wsOpText, 127, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	reader := &wsConn{reader: bufio.NewReader(bytes.NewReader(frame))}
+	if _, err := reader.readMessage(); err == nil || err == io.EOF {
+		t.Errorf("expected an error for the frame too large, got %v", err)
+	}
+}
+
+func TestWebsocketAcceptKey(t *testing.T) {
+	// the example of RFC 6455 section 1.3
+	if accept := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("accept key [%s]", accept)
+	}
+}
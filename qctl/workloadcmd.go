@@ -0,0 +1,659 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// a workload recorded from the blocks of a network, replayed against another network by mapping the nodes, their
+// accounts and tessera keys, and the contracts created by the workload.
+type workload struct {
+	Consensus    string         `json:"consensus"`
+	ChainId      string         `json:"chainId"`
+	Recorded     time.Time      `json:"recorded"`
+	FromBlock    uint64         `json:"fromBlock"`
+	ToBlock      uint64         `json:"toBlock"`
+	Nodes        []workloadNode `json:"nodes"`
+	Transactions []workloadTx   `json:"transactions"`
+}
+
+type workloadNode struct {
+	Name    string `json:"name"`
+	Account string `json:"account"`
+	TmKey   string `json:"tmKey"`
+}
+
+type workloadTx struct {
+	Hash     string `json:"hash"`
+	Block    uint64 `json:"block"`
+	OffsetMs int64  `json:"offsetMs"` // since the block of the first recorded transaction.
+	// the node whose account sent the transaction (eth_sendTransaction), empty if it was signed outside of the network.
+	Node  string `json:"node,omitempty"`
+	From  string `json:"from"`
+	To    string `json:"to,omitempty"`
+	Data  string `json:"data"` // the decrypted payload of a private transaction.
+	Value string `json:"value"`
+	Gas   string `json:"gas"`
+	// the node names the private transaction is private for.
+	Private    bool     `json:"private,omitempty"`
+	PrivateFor []string `json:"privateFor,omitempty"`
+	// the signed transaction, for the public transactions that were not sent by a node account.
+	Raw             string `json:"raw,omitempty"`
+	Status          string `json:"status"`
+	ContractAddress string `json:"contractAddress,omitempty"`
+}
+
+// the transaction fields of eth_getBlockByNumber with the full transactions.
+type workloadRpcBlock struct {
+	Number       string `json:"number"`
+	Timestamp    string `json:"timestamp"`
+	Transactions []struct {
+		Hash  string `json:"hash"`
+		From  string `json:"from"`
+		To    string `json:"to"`
+		Input string `json:"input"`
+		Value string `json:"value"`
+		Gas   string `json:"gas"`
+		V     string `json:"v"`
+	} `json:"transactions"`
+}
+
+type workloadReplayResult struct {
+	Transactions int            `json:"transactions"`
+	Sent         int            `json:"sent"`
+	Skipped      int            `json:"skipped"`
+	Errors       int            `json:"errors"`
+	Mined        int            `json:"mined"`
+	Pending      int            `json:"pending"`
+	StatusMatch  int            `json:"statusMatch"` // mined with the status of the recorded transaction.
+	NodeMapping  []string       `json:"nodeMapping"`
+	Duration     time.Duration  `json:"duration"`
+	Messages     map[string]int `json:"messages,omitempty"`
+}
+
+var (
+	// qctl workload record --duration=10m --out=workload.json
+	// qctl workload record --from-block=100 --to-block=200 --out=workload.json
+	workloadRecordCommand = cli.Command{
+		Name:  "record",
+		Usage: "record the transactions sent to the network from its blocks, including the payloads of the private transactions.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "out",
+				Aliases:  []string{"o"},
+				Usage:    "the file the workload is written to.",
+				Required: true,
+			},
+			&cli.Uint64Flag{
+				Name:  "from-block",
+				Usage: "the first block to record, defaults to the next block.",
+			},
+			&cli.Uint64Flag{
+				Name:  "to-block",
+				Usage: "the last block to record, if not set the new blocks are recorded for --duration or until interrupted.",
+			},
+			&cli.DurationFlag{
+				Name:  "duration",
+				Usage: "how long to record the new blocks for, 0 to record until interrupted (ctrl-c).",
+			},
+			&cli.StringFlag{
+				Name:  "node",
+				Usage: "the node the blocks are read from, defaults to the first node.",
+			},
+			&cli.DurationFlag{
+				Name:  "poll",
+				Usage: "how often to poll for new blocks.",
+				Value: 2 * time.Second,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			nodeName := c.String("node")
+			if nodeName == "" {
+				nodeName = getNodeNames(configFileYaml)[0]
+			}
+			podName := podNameFromPrefix(nodeName, namespace)
+			head, err := nodeBlockNumber(podName, namespace)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to reach node [%s]: %v", nodeName, err), 3)
+			}
+			recorder := &workloadRecorder{namespace: namespace, podName: podName,
+				workload: workload{Consensus: configFileYaml.Genesis.Consensus, ChainId: configFileYaml.Genesis.Chain_Id,
+					Recorded: time.Now()}}
+			recorder.workload.Nodes, recorder.pods = workloadNodes(getNodeNames(configFileYaml), namespace)
+
+			fromBlock := head + 1
+			if c.IsSet("from-block") {
+				fromBlock = c.Uint64("from-block")
+			}
+			recorder.workload.FromBlock = fromBlock
+			if c.IsSet("to-block") {
+				if err := recorder.record(fromBlock, c.Uint64("to-block")); err != nil {
+					return cli.Exit(err.Error(), 3)
+				}
+			} else {
+				// record the new blocks until the duration is over or qctl is interrupted, the workload is written either way.
+				green.Println(fmt.Sprintf("  recording the transactions from block [%d], ctrl-c to stop.", fromBlock))
+				interrupted := make(chan os.Signal, 1)
+				signal.Notify(interrupted, os.Interrupt)
+				defer signal.Stop(interrupted)
+				var deadline <-chan time.Time
+				if c.Duration("duration") > 0 {
+					deadline = time.After(c.Duration("duration"))
+				}
+				next := fromBlock
+			recording:
+				for {
+					select {
+					case <-interrupted:
+						break recording
+					case <-deadline:
+						break recording
+					case <-time.After(c.Duration("poll")):
+					}
+					head, err := nodeBlockNumber(podName, namespace)
+					if err != nil || head < next {
+						continue
+					}
+					if err := recorder.record(next, head); err != nil {
+						red.Println(fmt.Sprintf("  stopped recording: %v", err))
+						break recording
+					}
+					next = head + 1
+				}
+			}
+
+			out, err := json.MarshalIndent(recorder.workload, "", "  ")
+			if err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			if err := ioutil.WriteFile(c.String("out"), append(out, '\n'), 0644); err != nil {
+				return cli.Exit(err.Error(), 3)
+			}
+			green.Println(fmt.Sprintf("  recorded [%d] transactions from blocks [%d - %d] to [%s]",
+				len(recorder.workload.Transactions), recorder.workload.FromBlock, recorder.workload.ToBlock, c.String("out")))
+			if recorder.skipped > 0 {
+				red.Println(fmt.Sprintf("  skipped [%d] transactions that could not be recorded, see the warnings above.", recorder.skipped))
+			}
+			return nil
+		},
+	}
+
+	// qctl workload replay --in=workload.json
+	// qctl workload replay --in=workload.json --speed=0 --map=quorum-node1=quorum-node3
+	workloadReplayCommand = cli.Command{
+		Name:  "replay",
+		Usage: "replay a recorded workload against the network, mapping the nodes, accounts, tessera keys and contract addresses.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config, c",
+				Usage:    "Load configuration from `FULL_PATH_FILE`, the network the workload is replayed against.",
+				EnvVars:  []string{"QUBE_CONFIG"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "in",
+				Aliases:  []string{"i"},
+				Usage:    "the recorded workload file.",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:  "map",
+				Usage: "map recorded nodes to nodes of the network (comma separated or repeated), e.g. quorum-node1=quorum-node3, by default the nodes are mapped by name, then by position.",
+			},
+			&cli.Float64Flag{
+				Name:  "speed",
+				Usage: "the replay speed relative to the recording, e.g. 2 is twice as fast, 0 sends the transactions as fast as possible.",
+				Value: 1,
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "how long to wait for the receipts after the last transaction was sent.",
+				Value: DefaultReceiptTimeout,
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "output format: table | json",
+				Value: "table",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			namespace := c.String("namespace")
+			configFile := c.String("config")
+			configFileYaml, err := LoadYamlConfig(configFile)
+			if err != nil {
+				log.Fatalf("config file [%v] could not be loaded into the valid qubernetes yaml. err: [%v]", configFile, err)
+			}
+			workloadBytes, err := ioutil.ReadFile(c.String("in"))
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			var recorded workload
+			if err := json.Unmarshal(workloadBytes, &recorded); err != nil {
+				return cli.Exit(fmt.Sprintf("invalid workload [%s]: %v", c.String("in"), err), 2)
+			}
+			var recordedNames []string
+			for _, node := range recorded.Nodes {
+				recordedNames = append(recordedNames, node.Name)
+			}
+			nodeMapping, err := workloadNodeMapping(recordedNames, getNodeNames(configFileYaml), splitFlagValues(c.StringSlice("map")))
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+			targetNodes, targetPods := workloadNodes(getNodeNames(configFileYaml), namespace)
+			replayer := newWorkloadReplayer(recorded, nodeMapping, targetNodes, targetPods, namespace)
+			if c.String("output") != "json" {
+				green.Println(fmt.Sprintf("  replaying [%d] transactions, nodes %v", len(recorded.Transactions),
+					replayer.result.NodeMapping))
+			}
+			replayer.replay(c.Float64("speed"))
+			replayer.waitForReceipts(c.Duration("timeout"))
+
+			if c.String("output") == "json" {
+				out, err := json.MarshalIndent(replayer.result, "", "  ")
+				if err != nil {
+					return cli.Exit(err.Error(), 3)
+				}
+				fmt.Println(string(out))
+			} else {
+				displayWorkloadReplayResult(replayer.result)
+			}
+			if replayer.result.StatusMatch < replayer.result.Transactions {
+				return cli.Exit(fmt.Sprintf("%d of %d transactions did not replay with the recorded status.",
+					replayer.result.Transactions-replayer.result.StatusMatch, replayer.result.Transactions), 1)
+			}
+			return nil
+		},
+	}
+)
+
+// workloadNodes returns the account and tessera key of every node, and the node pods.
+func workloadNodes(nodeNames []string, namespace string) ([]workloadNode, map[string]string) {
+	var nodes []workloadNode
+	pods := map[string]string{}
+	for _, nodeName := range nodeNames {
+		pods[nodeName] = podNameFromPrefix(nodeName, namespace)
+		node := workloadNode{Name: nodeName}
+		node.Account, _ = nodeAccount(pods[nodeName], namespace)
+		node.TmKey, _ = getTmPublicKey(nodeName, namespace)
+		nodes = append(nodes, node)
+	}
+	return nodes, pods
+}
+
+// workloadNodeMapping maps the recorded nodes to the target nodes, with the explicit mappings (recorded=target) first,
+// then the nodes with the same name, then the remaining nodes by position.
+func workloadNodeMapping(recorded, target, mappings []string) (map[string]string, error) {
+	mapping := map[string]string{}
+	used := map[string]bool{}
+	for _, m := range mappings {
+		parts := strings.SplitN(m, "=", 2)
+		if len(parts) != 2 || !containsString(recorded, parts[0]) || !containsString(target, parts[1]) {
+			return nil, fmt.Errorf("invalid node mapping [%s], must be recorded-node=node", m)
+		}
+		mapping[parts[0]] = parts[1]
+		used[parts[1]] = true
+	}
+	for _, name := range recorded {
+		if _, mapped := mapping[name]; !mapped && containsString(target, name) && !used[name] {
+			mapping[name] = name
+			used[name] = true
+		}
+	}
+	var remaining []string
+	for _, name := range target {
+		if !used[name] {
+			remaining = append(remaining, name)
+		}
+	}
+	for _, name := range recorded {
+		if _, mapped := mapping[name]; mapped {
+			continue
+		}
+		if len(remaining) == 0 {
+			return nil, fmt.Errorf("no node left to map the recorded node [%s] to, the network has %d nodes", name, len(target))
+		}
+		mapping[name], remaining = remaining[0], remaining[1:]
+	}
+	return mapping, nil
+}
+
+// remapAddresses replaces the recorded addresses (lowercase hex without 0x) in the 32 byte ABI words of the hex data,
+// e.g. in the arguments of a call or the constructor arguments appended to the bytecode. The words are aligned to the
+// end of the data, only the words holding an address (the top 12 bytes zero) are replaced, the rest is kept as is.
+func remapAddresses(data string, addresses map[string]string) string {
+	if len(addresses) == 0 {
+		return data
+	}
+	const wordLen, paddingLen = 64, 24
+	start := 0
+	if strings.HasPrefix(data, "0x") || strings.HasPrefix(data, "0X") {
+		start = 2
+	}
+	padding := strings.Repeat("0", paddingLen)
+	// a single pass over the original data, so swapped addresses (a -> b, b -> a) are not replaced twice.
+	remapped := []byte(data)
+	for end := len(data); end-wordLen >= start; end -= wordLen {
+		word := data[end-wordLen : end]
+		if word[:paddingLen] != padding {
+			continue
+		}
+		if replayed, ok := addresses[strings.ToLower(word[paddingLen:])]; ok {
+			copy(remapped[end-wordLen+paddingLen:end], replayed)
+		}
+	}
+	return string(remapped)
+}
+
+// remapAddress gets the replayed address of a recorded address, or the address itself if it was not remapped.
+func remapAddress(address string, addresses map[string]string) string {
+	if replayed, ok := addresses[addressKey(address)]; ok {
+		return "0x" + replayed
+	}
+	return address
+}
+
+func addressKey(address string) string {
+	return strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+type workloadRecorder struct {
+	namespace string
+	podName   string
+	pods      map[string]string
+	workload  workload
+	firstTime time.Time
+	skipped   int
+}
+
+// record adds the transactions of the blocks to the workload.
+func (r *workloadRecorder) record(fromBlock, toBlock uint64) error {
+	accountNodes := map[string]string{}
+	for _, node := range r.workload.Nodes {
+		accountNodes[addressKey(node.Account)] = node.Name
+	}
+	for from := fromBlock; from <= toBlock; from += chainScanBatchSize {
+		to := from + chainScanBatchSize - 1
+		if to > toBlock {
+			to = toBlock
+		}
+		var requests []rpcRequest
+		for n := from; n <= to; n++ {
+			requests = append(requests, rpcRequest{Method: "eth_getBlockByNumber", Params: []interface{}{uint64ToHex(n), true}})
+		}
+		responses, err := nodeRpcBatch(r.podName, r.namespace, requests)
+		if err != nil {
+			return err
+		}
+		for i, response := range responses {
+			var block workloadRpcBlock
+			if response.Error != nil || json.Unmarshal(response.Result, &block) != nil || block.Number == "" {
+				return fmt.Errorf("unable to get block [%d] from pod [%s]", from+uint64(i), r.podName)
+			}
+			blockNumber, _ := hexToUint64(block.Number)
+			timestamp, _ := hexToUint64(block.Timestamp)
+			blockTime := time.Unix(int64(timestamp), 0)
+			if r.workload.Consensus == RaftConsensus { // raft block timestamps are in nanoseconds.
+				blockTime = time.Unix(0, int64(timestamp))
+			}
+			for _, blockTx := range block.Transactions {
+				if r.firstTime.IsZero() {
+					r.firstTime = blockTime
+				}
+				tx := workloadTx{Hash: blockTx.Hash, Block: blockNumber, OffsetMs: int64(blockTime.Sub(r.firstTime) / time.Millisecond),
+					Node: accountNodes[addressKey(blockTx.From)], From: blockTx.From, To: blockTx.To, Data: blockTx.Input,
+					Value: blockTx.Value, Gas: blockTx.Gas}
+				// quorum signs the private transactions with v 37 or 38.
+				tx.Private = blockTx.V == "0x25" || blockTx.V == "0x26"
+				if err := r.recordPayload(&tx); err != nil {
+					log.Warnf("skipping transaction [%s]: %v", tx.Hash, err)
+					r.skipped++
+					continue
+				}
+				r.workload.Transactions = append(r.workload.Transactions, tx)
+			}
+			r.workload.ToBlock = blockNumber
+		}
+	}
+	return nil
+}
+
+// recordPayload records the receipt, and the decrypted payload and participants of a private transaction, or the
+// signed transaction of a public transaction not sent by a node.
+func (r *workloadRecorder) recordPayload(tx *workloadTx) error {
+	senderPod := r.podName
+	if tx.Node != "" {
+		senderPod = r.pods[tx.Node]
+	}
+	var receipt rpcReceipt
+	if err := nodeRpcInto(senderPod, r.namespace, &receipt, "eth_getTransactionReceipt", tx.Hash); err != nil {
+		return err
+	}
+	tx.Status, tx.ContractAddress = receipt.Status, receipt.ContractAddress
+	if !tx.Private {
+		if tx.Node == "" {
+			return nodeRpcInto(senderPod, r.namespace, &tx.Raw, "eth_getRawTransactionByHash", tx.Hash)
+		}
+		return nil
+	}
+	if tx.Node == "" {
+		return fmt.Errorf("private transaction from [%s] was not sent by a node", tx.From)
+	}
+	tmHash := tx.Data
+	if err := nodeRpcInto(senderPod, r.namespace, &tx.Data, "eth_getQuorumPayload", tmHash); err != nil {
+		return err
+	}
+	// the participants are the nodes whose tessera has the payload.
+	hashBytes, err := hex.DecodeString(strings.TrimPrefix(tmHash, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid private transaction input [%s]", tmHash)
+	}
+	payloadPath := "/transaction/" + url.PathEscape(base64.StdEncoding.EncodeToString(hashBytes))
+	for _, node := range r.workload.Nodes {
+		if node.Name == tx.Node {
+			continue
+		}
+		if _, err := tmQ2TGet(r.pods[node.Name], r.namespace, payloadPath); err == nil {
+			tx.PrivateFor = append(tx.PrivateFor, node.Name)
+		}
+	}
+	return nil
+}
+
+type workloadReplayer struct {
+	workload    workload
+	namespace   string
+	nodeMapping map[string]string
+	nodes       map[string]workloadNode // by target node name
+	pods        map[string]string
+	addresses   map[string]string // recorded -> replayed, the node accounts and the contracts created by the workload.
+	pending     []workloadPendingTx
+	result      workloadReplayResult
+}
+
+type workloadPendingTx struct {
+	recorded workloadTx
+	hash     string
+	pod      string
+}
+
+func newWorkloadReplayer(recorded workload, nodeMapping map[string]string, targetNodes []workloadNode,
+	targetPods map[string]string, namespace string) *workloadReplayer {
+	r := &workloadReplayer{workload: recorded, namespace: namespace, nodeMapping: nodeMapping, nodes: map[string]workloadNode{},
+		pods: targetPods, addresses: map[string]string{},
+		result: workloadReplayResult{Transactions: len(recorded.Transactions), Messages: map[string]int{}}}
+	for _, node := range targetNodes {
+		r.nodes[node.Name] = node
+	}
+	for _, node := range recorded.Nodes {
+		target := r.nodes[nodeMapping[node.Name]]
+		if node.Account != "" && target.Account != "" {
+			r.addresses[addressKey(node.Account)] = addressKey(target.Account)
+		}
+		r.result.NodeMapping = append(r.result.NodeMapping, node.Name+"="+target.Name)
+	}
+	sort.Strings(r.result.NodeMapping)
+	return r
+}
+
+// replay sends the transactions in the recorded order and pacing. A contract creation is waited for before the next
+// transaction, so the later transactions can be sent to the address of the replayed contract.
+func (r *workloadReplayer) replay(speed float64) {
+	start := time.Now()
+	for _, tx := range r.workload.Transactions {
+		if speed > 0 {
+			if wait := time.Duration(float64(tx.OffsetMs)*float64(time.Millisecond)/speed) - time.Since(start); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		pod, hash, err := r.send(tx)
+		if err != nil {
+			r.result.Errors++
+			r.result.Messages[err.Error()]++
+			continue
+		}
+		if pod == "" {
+			r.result.Skipped++
+			continue
+		}
+		r.result.Sent++
+		if tx.To != "" || tx.ContractAddress == "" {
+			r.pending = append(r.pending, workloadPendingTx{recorded: tx, hash: hash, pod: pod})
+			continue
+		}
+		receipt, err := waitForReceipt(pod, r.namespace, hash, DefaultReceiptTimeout)
+		if err != nil {
+			r.result.Messages[fmt.Sprintf("contract creation not mined: %v", err)]++
+			continue
+		}
+		r.countReceipt(tx, receipt)
+		if receipt.ContractAddress != "" {
+			r.addresses[addressKey(tx.ContractAddress)] = addressKey(receipt.ContractAddress)
+		}
+	}
+	r.result.Duration = time.Since(start)
+}
+
+// send sends the transaction from the mapped node, returns an empty pod for a transaction that can not be replayed.
+func (r *workloadReplayer) send(tx workloadTx) (string, string, error) {
+	if tx.Raw != "" {
+		// signed outside the network, it is sent as is, it only replays if the chain id and the account nonce match.
+		pod := r.pods[r.nodeMapping[r.workload.Nodes[0].Name]]
+		var hash string
+		err := nodeRpcInto(pod, r.namespace, &hash, "eth_sendRawTransaction", tx.Raw)
+		return pod, hash, err
+	}
+	node, mapped := r.nodes[r.nodeMapping[tx.Node]]
+	if !mapped || node.Account == "" {
+		return "", "", nil
+	}
+	replayTx := rpcTx{From: node.Account, Data: remapAddresses(tx.Data, r.addresses), Value: tx.Value, Gas: tx.Gas}
+	if tx.To != "" {
+		replayTx.To = remapAddress(tx.To, r.addresses)
+	}
+	if tx.Private {
+		for _, participant := range tx.PrivateFor {
+			replayTx.PrivateFor = append(replayTx.PrivateFor, r.nodes[r.nodeMapping[participant]].TmKey)
+		}
+		if len(replayTx.PrivateFor) == 0 {
+			// a private transaction only for the sender.
+			replayTx.PrivateFor = []string{node.TmKey}
+		}
+	}
+	pod := r.pods[node.Name]
+	hash, err := sendTransaction(pod, r.namespace, replayTx)
+	return pod, hash, err
+}
+
+// waitForReceipts polls the receipts of the pending transactions in batches per node.
+func (r *workloadReplayer) waitForReceipts(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for len(r.pending) > 0 && time.Now().Before(deadline) {
+		byPod := map[string][]workloadPendingTx{}
+		for _, tx := range r.pending {
+			byPod[tx.pod] = append(byPod[tx.pod], tx)
+		}
+		var stillPending []workloadPendingTx
+		for pod, txs := range byPod {
+			var requests []rpcRequest
+			for _, tx := range txs {
+				requests = append(requests, rpcRequest{Method: "eth_getTransactionReceipt", Params: []interface{}{tx.hash}})
+			}
+			responses, err := nodeRpcBatch(pod, r.namespace, requests)
+			if err != nil {
+				stillPending = append(stillPending, txs...)
+				continue
+			}
+			for i, response := range responses {
+				var receipt rpcReceipt
+				if response.Error != nil || json.Unmarshal(response.Result, &receipt) != nil || receipt.BlockNumber == "" {
+					stillPending = append(stillPending, txs[i])
+					continue
+				}
+				r.countReceipt(txs[i].recorded, receipt)
+			}
+		}
+		r.pending = stillPending
+		if len(r.pending) > 0 {
+			time.Sleep(2 * time.Second)
+		}
+	}
+	r.result.Pending = len(r.pending)
+}
+
+// countReceipt compares the status with the recorded status, a receipt without a status (pre byzantium) matches any status.
+func (r *workloadReplayer) countReceipt(tx workloadTx, receipt rpcReceipt) {
+	r.result.Mined++
+	if receipt.Status == tx.Status || tx.Status == "" {
+		r.result.StatusMatch++
+	} else {
+		r.result.Messages[fmt.Sprintf("status %s, recorded %s", receipt.Status, tx.Status)]++
+	}
+}
+
+func displayWorkloadReplayResult(result workloadReplayResult) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, fmt.Sprintf("  nodes\t%s", strings.Join(result.NodeMapping, ", ")))
+	fmt.Fprintln(w, fmt.Sprintf("  transactions\t%d", result.Transactions))
+	fmt.Fprintln(w, fmt.Sprintf("  sent\t%d", result.Sent))
+	fmt.Fprintln(w, fmt.Sprintf("  skipped\t%d", result.Skipped))
+	fmt.Fprintln(w, fmt.Sprintf("  errors\t%d", result.Errors))
+	fmt.Fprintln(w, fmt.Sprintf("  mined\t%d", result.Mined))
+	fmt.Fprintln(w, fmt.Sprintf("  pending\t%d", result.Pending))
+	fmt.Fprintln(w, fmt.Sprintf("  recorded status\t%d", result.StatusMatch))
+	fmt.Fprintln(w, fmt.Sprintf("  duration\t%v", result.Duration.Round(time.Millisecond)))
+	w.Flush()
+	if len(result.Messages) > 0 {
+		fmt.Println()
+		red.Println("  errors:")
+		for message, count := range result.Messages {
+			red.Println(fmt.Sprintf("    %dx %s", count, message))
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorkloadNodeMapping(t *testing.T) {
+	tests := []struct {
+		recorded, target, mappings []string
+		expected                   map[string]string
+	}{
+		// by name, then the remaining nodes by position.
+		{[]string{"quorum-node1", "quorum-node2", "quorum-node3"}, []string{"node-a", "quorum-node2", "node-b"}, nil,
+			map[string]string{"quorum-node1": "node-a", "quorum-node2": "quorum-node2", "quorum-node3": "node-b"}},
+		// the explicit mapping takes the node before the mapping by name.
+		{[]string{"quorum-node1", "quorum-node2"}, []string{"quorum-node1", "quorum-node2"}, []string{"quorum-node1=quorum-node2"},
+			map[string]string{"quorum-node1": "quorum-node2", "quorum-node2": "quorum-node1"}},
+	}
+	for _, test := range tests {
+		mapping, err := workloadNodeMapping(test.recorded, test.target, test.mappings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mapping, test.expected) {
+			t.Errorf("workloadNodeMapping(%v, %v, %v) = %v, expected %v", test.recorded, test.target, test.mappings,
+				mapping, test.expected)
+		}
+	}
+	if _, err := workloadNodeMapping([]string{"quorum-node1", "quorum-node2"}, []string{"quorum-node1"}, nil); err == nil {
+		t.Error("expected an error when the network has less nodes than the workload")
+	}
+	if _, err := workloadNodeMapping([]string{"quorum-node1"}, []string{"quorum-node1"}, []string{"quorum-node1"}); err == nil {
+		t.Error("expected an error for an invalid mapping")
+	}
+}
+
+func TestRemapAddresses(t *testing.T) {
+	recorded, replayed := "ca35b7d915458ef540ade6068dfe2f44e8fa733c", "14723a09acff6d2a60dcdf7aa4aff308fddc160c"
+	tests := []struct {
+		data, expected string
+	}{
+		// transfer(address,uint256) to a recorded account.
+		{"0xa9059cbb000000000000000000000000CA35B7D915458EF540ADE6068DFE2F44E8FA733C" +
+			"00000000000000000000000000000000000000000000000000000000000003E8",
+			"0xa9059cbb00000000000000000000000014723a09acff6d2a60dcdf7aa4aff308fddc160c" +
+				"00000000000000000000000000000000000000000000000000000000000003E8"},
+		// the address bytes in a word that is not an address, or not aligned to a word, are kept.
+		{"0xa9059cbb100000000000000000000000ca35b7d915458ef540ade6068dfe2f44e8fa733c",
+			"0xa9059cbb100000000000000000000000ca35b7d915458ef540ade6068dfe2f44e8fa733c"},
+		{"0x6080ca35b7d915458ef540ade6068dfe2f44e8fa733c6040", "0x6080ca35b7d915458ef540ade6068dfe2f44e8fa733c6040"},
+		// the constructor arguments at the end of the bytecode.
+		{"0x608060405234801561001057600080fd5b50000000000000000000000000ca35b7d915458ef540ade6068dfe2f44e8fa733c",
+			"0x608060405234801561001057600080fd5b5000000000000000000000000014723a09acff6d2a60dcdf7aa4aff308fddc160c"},
+	}
+	for _, test := range tests {
+		if remapped := remapAddresses(test.data, map[string]string{recorded: replayed}); remapped != test.expected {
+			t.Errorf("remapAddresses = %s, expected %s", remapped, test.expected)
+		}
+	}
+
+	// swapped accounts, e.g. --map quorum-node1=quorum-node2.
+	swapped := map[string]string{recorded: replayed, replayed: recorded}
+	data := "0x000000000000000000000000" + recorded + "000000000000000000000000" + replayed
+	expected := "0x000000000000000000000000" + replayed + "000000000000000000000000" + recorded
+	for i := 0; i < 10; i++ { // the map order changes between the runs.
+		if remapped := remapAddresses(data, swapped); remapped != expected {
+			t.Fatalf("remapAddresses = %s, expected %s", remapped, expected)
+		}
+	}
+	if to := remapAddress("0xCA35B7D915458EF540ADE6068DFE2F44E8FA733C", swapped); to != "0x"+replayed {
+		t.Errorf("remapAddress = %s, expected 0x%s", to, replayed)
+	}
+}